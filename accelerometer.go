@@ -19,6 +19,12 @@ type Accelerometer struct {
 	addr        *uint16
 	range_      AccelerometerRange
 	mode        AccelerometerMode
+	// The ODR value it runs at, restored by Reset
+	dataRate    uint8
+	calibration *AccelerometerCalibration
+	remap       *AxisRemap
+	// Take the configuration from the chip instead of writing it
+	current     bool
 }
 
 // New accelerometer opens a handle to an LSM303 accelerometer sensor.
@@ -48,15 +54,26 @@ func NewAccelerometer(bus i2c.Bus, opts ...AccelerometerOption) (*Accelerometer,
 		Order: binary.BigEndian,
 	}
 
-	// Enable the accelerometer 100 Hz, 0x57 = 0b01010111
+	// Enable the accelerometer 100 Hz, 0x57 = 0b01010111 on the DLHC and AGR
 	// Bits 0-2 = X, Y, Z enable
 	// Bit 3 = low power mode
 	// Bits 4-7 = speed, 0 = power down, 1-7 = 1 10 25 50 100 200 400 Hz, 8 = low
 	//   power mode 1.62 khZ, 9 = normal 1.34 kHz / low power 5.376 kHz
+	// The C has a 3-bit ODR, 3 = 100 Hz, with HR and BDU around it.
 	// TODO: Allow the user to set the Hz and toggle axes
-	err := device.mmr.WriteUint8(device.datasheet.CTRL_REG1_A, 0x57)
-	if err != nil {
-		return nil, err
+	device.dataRate = accelerometerDriverFor(device.sensorType).rate100Hz
+	running := false
+	if device.current {
+		rate, err := readField(&device.mmr, device.fields().dataRate)
+		if err != nil {
+			return nil, err
+		}
+		running = rate != 0
+	}
+	if !running {
+		if err := device.enable(); err != nil {
+			return nil, err
+		}
 	}
 
 	// Validate sensor
@@ -66,14 +83,37 @@ func NewAccelerometer(bus i2c.Bus, opts ...AccelerometerOption) (*Accelerometer,
 		return nil, fmt.Errorf("no %s detected", device.sensorType)
 	}
 
+	if running {
+		if err := device.readConfiguration(); err != nil {
+			return nil, err
+		}
+		return device, nil
+	}
+
 	// Init accelerometer configuration
-	device.SetRange(device.range_)
-	device.SetMode(device.mode)
+	if err := device.SetRange(device.range_); err != nil {
+		return nil, err
+	}
+	if err := device.SetMode(device.mode); err != nil {
+		return nil, err
+	}
 
 	return device, nil
 }
 
+// SenseRaw returns the raw output counts, rotated into the body frame if
+// WithAccelerometerAxisRemap was given.
 func (a *Accelerometer) SenseRaw() (int16, int16, int16, error) {
+	xValue, yValue, zValue, err := a.readRaw()
+	if err != nil || a.remap == nil {
+		return xValue, yValue, zValue, err
+	}
+	xValue, yValue, zValue = a.remap.applyRaw(xValue, yValue, zValue)
+	return xValue, yValue, zValue, nil
+}
+
+// Reads the output counts in the sensor frame.
+func (a *Accelerometer) readRaw() (int16, int16, int16, error) {
 	xLow, err := a.mmr.ReadUint8(a.datasheet.OUT_X_L_A)
 	if err != nil {
 		return 0, 0, 0, err
@@ -116,58 +156,74 @@ func (a *Accelerometer) Sense() (physic.Force, physic.Force, physic.Force, error
 	yAcceleration := (physic.Force)(int64(yValue) * multiplier)
 	zAcceleration := (physic.Force)(int64(zValue) * multiplier)
 
+	if a.calibration != nil {
+		corrected := a.calibration.Apply(Vector{forceToG(xAcceleration), forceToG(yAcceleration), forceToG(zAcceleration)})
+		return gToForce(corrected.X), gToForce(corrected.Y), gToForce(corrected.Z), nil
+	}
+
 	return xAcceleration, yAcceleration, zAcceleration, nil
 }
 
-func (a *Accelerometer) GetMode() (AccelerometerMode, error) {
-	lowPowerU8, err := a.mmr.ReadUint8(a.datasheet.CTRL_REG1_A)
-	if err != nil {
-		return ACCELEROMETER_MODE_NORMAL, err
-	}
-	lowPowerBit := readBits(uint32(lowPowerU8), 1, 3)
+// SetCalibration replaces the calibration applied by Sense, nil disables it.
+func (a *Accelerometer) SetCalibration(calibration *AccelerometerCalibration) {
+	a.calibration = calibration
+}
 
-	highResolutionU8, err := a.mmr.ReadUint8(a.datasheet.CTRL_REG4_A)
+// Reads the acceleration in units of standard gravity, with the calibration
+// applied if there is one.
+func (a *Accelerometer) senseCalibratedVector() (Vector, error) {
+	acceleration, err := a.senseVector()
 	if err != nil {
-		return ACCELEROMETER_MODE_NORMAL, err
+		return Vector{}, err
 	}
-	highResolutionBit := readBits(uint32(highResolutionU8), 1, 3)
-
-	return AccelerometerMode((lowPowerBit << 1) | highResolutionBit), nil
+	if a.calibration != nil {
+		acceleration = a.calibration.Apply(acceleration)
+	}
+	return acceleration, nil
 }
 
-func (a *Accelerometer) SetMode(mode AccelerometerMode) error {
-	const bits = 1
-	const shift = 3
-
-	data := uint8((mode & 0x02) >> 1)
-	power, err := a.mmr.ReadUint8(a.datasheet.CTRL_REG1_A)
+// Reads the uncalibrated acceleration in units of standard gravity.
+func (a *Accelerometer) senseVector() (Vector, error) {
+	xValue, yValue, zValue, err := a.SenseRaw()
 	if err != nil {
-		return err
+		return Vector{}, err
 	}
+	multiplier := getMultiplier(a.mode, a.range_)
+	return Vector{
+		forceToG(physic.Force(int64(xValue) * multiplier)),
+		forceToG(physic.Force(int64(yValue) * multiplier)),
+		forceToG(physic.Force(int64(zValue) * multiplier)),
+	}, nil
+}
 
-	mask := uint8((1 << bits) - 1)
-	data &= mask
-	mask <<= shift
-	power &= (^mask)
-	power |= data << shift
-	err = a.mmr.WriteUint8(a.datasheet.CTRL_REG1_A, power)
+func (a *Accelerometer) GetMode() (AccelerometerMode, error) {
+	fields := a.fields()
+	var lowPower uint8
+	if fields.lowPower.Width != 0 {
+		var err error
+		if lowPower, err = readField(&a.mmr, fields.lowPower); err != nil {
+			return ACCELEROMETER_MODE_NORMAL, err
+		}
+	}
+	highResolution, err := readField(&a.mmr, fields.highResolution)
 	if err != nil {
-		return err
+		return ACCELEROMETER_MODE_NORMAL, err
 	}
-	time.Sleep(time.Millisecond * 20)
+	return AccelerometerMode((lowPower << 1) | highResolution), nil
+}
 
-	data = uint8(mode & 0x01)
-	resolution, err := a.mmr.ReadUint8(a.datasheet.CTRL_REG4_A)
-	if err != nil {
-		return err
+func (a *Accelerometer) SetMode(mode AccelerometerMode) error {
+	fields := a.fields()
+	if fields.lowPower.Width != 0 {
+		if err := writeField(&a.mmr, fields.lowPower, uint8(mode&0x02)>>1); err != nil {
+			return err
+		}
+		time.Sleep(time.Millisecond * 20)
+	} else if mode == ACCELEROMETER_MODE_LOW_POWER {
+		return unsupported(a.sensorType, "low power mode")
 	}
-	mask = uint8((1 << bits) - 1)
-	data &= mask
-	mask <<= shift
-	resolution &= (^mask)
-	resolution |= data << shift
-	err = a.mmr.WriteUint8(a.datasheet.CTRL_REG4_A, resolution)
-	if err != nil {
+
+	if err := writeField(&a.mmr, fields.highResolution, uint8(mode&0x01)); err != nil {
 		return err
 	}
 	time.Sleep(time.Millisecond * 20)
@@ -178,31 +234,24 @@ func (a *Accelerometer) SetMode(mode AccelerometerMode) error {
 }
 
 func (a *Accelerometer) GetRange() (AccelerometerRange, error) {
-	value, err := a.mmr.ReadUint8(a.datasheet.CTRL_REG4_A)
+	value, err := readField(&a.mmr, a.fields().fullScale)
 	if err != nil {
 		return ACCELEROMETER_RANGE_4G, err
 	}
-	range_ := ((uint32(value)) >> 4) & ((1 << 2) - 1)
-	return AccelerometerRange(range_), nil
+	range_, ok := accelerometerRangeOf(a.sensorType, value)
+	if !ok {
+		return ACCELEROMETER_RANGE_4G, fmt.Errorf("%s accelerometer has a reserved full scale %02b", a.sensorType, value)
+	}
+	return range_, nil
 }
 
+// SetRange sets the full scale. The LSM303C has no ±16G range.
 func (a *Accelerometer) SetRange(range_ AccelerometerRange) error {
-	const bits = 2
-	const shift = 4
-
-	data := uint8(range_)
-	currentRange, err := a.mmr.ReadUint8(a.datasheet.CTRL_REG4_A)
-	if err != nil {
-		return err
+	value, ok := accelerometerFullScale(a.sensorType, range_)
+	if !ok {
+		return unsupported(a.sensorType, range_.String()+" range")
 	}
-
-	mask := uint8((1 << bits) - 1)
-	data &= mask
-	mask <<= shift
-	currentRange &= (^mask)
-	currentRange |= data << shift
-	err = a.mmr.WriteUint8(a.datasheet.CTRL_REG4_A, currentRange)
-	if err != nil {
+	if err := writeField(&a.mmr, a.fields().fullScale, value); err != nil {
 		return err
 	}
 	time.Sleep(time.Millisecond * 20)
@@ -212,13 +261,15 @@ func (a *Accelerometer) SetRange(range_ AccelerometerRange) error {
 	return nil
 }
 
+// String names the accelerometer and where it is, e.g.
+// "LSM303DLHC accelerometer on I2C1 at 0x19".
 func (a *Accelerometer) String() string {
-	return "LSM303 accelerometer"
+	return describe(a.sensorType, "accelerometer", a.mmr.Conn)
 }
 
-func readBits(value uint32, bits uint32, shift uint8) uint32 {
-	value >>= shift
-	return value & ((1 << bits) - 1)
+// SensorType returns the variant the accelerometer was created for.
+func (a *Accelerometer) SensorType() SensorType {
+	return a.sensorType
 }
 
 // Gets the multiplier for the accelerometer mode and range
@@ -0,0 +1,171 @@
+package lsm303
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// AccelerometerCalibration corrects zero-g offset, sensitivity and optionally
+// cross-axis misalignment. The corrected reading is Scale * (raw - Bias), all
+// in units of standard gravity.
+type AccelerometerCalibration struct {
+	Bias  Vector        `json:"bias"`
+	Scale [3][3]float64 `json:"scale"`
+}
+
+// Apply corrects a reading in units of standard gravity.
+func (c AccelerometerCalibration) Apply(acceleration Vector) Vector {
+	return matrix3(c.Scale).mulVec(acceleration.Sub(c.Bias))
+}
+
+// AccelerometerPosition is one of the six orientations used for calibration,
+// named after the axis pointing up.
+type AccelerometerPosition int
+
+const (
+	ACCELEROMETER_POSITION_X_UP AccelerometerPosition = iota
+	ACCELEROMETER_POSITION_X_DOWN
+	ACCELEROMETER_POSITION_Y_UP
+	ACCELEROMETER_POSITION_Y_DOWN
+	ACCELEROMETER_POSITION_Z_UP
+	ACCELEROMETER_POSITION_Z_DOWN
+)
+
+func (position AccelerometerPosition) String() string {
+	return [...]string{"X up", "X down", "Y up", "Y down", "Z up", "Z down"}[position]
+}
+
+// The reading an ideal sensor gives in this position.
+func (position AccelerometerPosition) reference() Vector {
+	return [...]Vector{
+		{1, 0, 0}, {-1, 0, 0},
+		{0, 1, 0}, {0, -1, 0},
+		{0, 0, 1}, {0, 0, -1},
+	}[position]
+}
+
+// AccelerometerCalibrator captures averaged readings with the device resting
+// in each of the six positions and solves for the calibration.
+type AccelerometerCalibrator struct {
+	accelerometer *Accelerometer
+	measurements  map[AccelerometerPosition]Vector
+}
+
+// NewAccelerometerCalibrator creates a calibrator for the given accelerometer.
+// The accelerometer can be nil if readings are only added with AddMeasurement.
+func NewAccelerometerCalibrator(accelerometer *Accelerometer) *AccelerometerCalibrator {
+	return &AccelerometerCalibrator{
+		accelerometer: accelerometer,
+		measurements:  map[AccelerometerPosition]Vector{},
+	}
+}
+
+// Calibrate walks through all six positions. For each one it calls prompt,
+// which should ask the user to put the device in that position and return once
+// it is resting there, then captures the average of n readings.
+func (c *AccelerometerCalibrator) Calibrate(ctx context.Context, n int, prompt func(AccelerometerPosition) error) error {
+	for position := ACCELEROMETER_POSITION_X_UP; position <= ACCELEROMETER_POSITION_Z_DOWN; position++ {
+		if err := prompt(position); err != nil {
+			return err
+		}
+		if err := c.Capture(ctx, position, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Capture averages n fresh uncalibrated readings taken in the given position,
+// like SenseAveraged.
+func (c *AccelerometerCalibrator) Capture(ctx context.Context, position AccelerometerPosition, n int) error {
+	if c.accelerometer == nil {
+		return errors.New("calibrator has no accelerometer")
+	}
+	result, err := senseAveraged(ctx, n, nil, c.accelerometer.waitDataReady, c.accelerometer.senseVector)
+	if err != nil {
+		return err
+	}
+	return c.AddMeasurement(position, result.Mean)
+}
+
+// AddMeasurement records an averaged uncalibrated reading, in units of
+// standard gravity, for a position. It fails if the reading clearly doesn't
+// match the position.
+func (c *AccelerometerCalibrator) AddMeasurement(position AccelerometerPosition, acceleration Vector) error {
+	if acceleration.Normalize().Dot(position.reference()) < math.Cos(radians(30)) {
+		return fmt.Errorf("reading %+v doesn't look like %s, check the device orientation", acceleration, position)
+	}
+	c.measurements[position] = acceleration
+	return nil
+}
+
+// Missing returns the positions that haven't been captured yet.
+func (c *AccelerometerCalibrator) Missing() []AccelerometerPosition {
+	var missing []AccelerometerPosition
+	for position := ACCELEROMETER_POSITION_X_UP; position <= ACCELEROMETER_POSITION_Z_DOWN; position++ {
+		if _, ok := c.measurements[position]; !ok {
+			missing = append(missing, position)
+		}
+	}
+	return missing
+}
+
+// Solve computes the calibration. Without crossAxis only per-axis bias and
+// scale are solved for, otherwise the full 3x3 correction matrix is fitted
+// by least squares.
+func (c *AccelerometerCalibrator) Solve(crossAxis bool) (AccelerometerCalibration, error) {
+	if missing := c.Missing(); len(missing) > 0 {
+		return AccelerometerCalibration{}, fmt.Errorf("missing positions %v", missing)
+	}
+
+	if !crossAxis {
+		calibration := AccelerometerCalibration{}
+		up := [3]AccelerometerPosition{ACCELEROMETER_POSITION_X_UP, ACCELEROMETER_POSITION_Y_UP, ACCELEROMETER_POSITION_Z_UP}
+		down := [3]AccelerometerPosition{ACCELEROMETER_POSITION_X_DOWN, ACCELEROMETER_POSITION_Y_DOWN, ACCELEROMETER_POSITION_Z_DOWN}
+		bias := [3]float64{}
+		for axis := 0; axis < 3; axis++ {
+			high := component(c.measurements[up[axis]], axis)
+			low := component(c.measurements[down[axis]], axis)
+			bias[axis] = (high + low) / 2
+			calibration.Scale[axis][axis] = 2 / (high - low)
+		}
+		calibration.Bias = Vector{bias[0], bias[1], bias[2]}
+		return calibration, nil
+	}
+
+	// Fit reference = W * raw + offset, one row of W at a time
+	rows := make([][]float64, 0, 6)
+	for position := ACCELEROMETER_POSITION_X_UP; position <= ACCELEROMETER_POSITION_Z_DOWN; position++ {
+		raw := c.measurements[position]
+		rows = append(rows, []float64{raw.X, raw.Y, raw.Z, 1})
+	}
+	var w matrix3
+	var offset [3]float64
+	for axis := 0; axis < 3; axis++ {
+		rhs := make([]float64, 0, 6)
+		for position := ACCELEROMETER_POSITION_X_UP; position <= ACCELEROMETER_POSITION_Z_DOWN; position++ {
+			rhs = append(rhs, component(position.reference(), axis))
+		}
+		solution, err := leastSquares(rows, rhs)
+		if err != nil {
+			return AccelerometerCalibration{}, err
+		}
+		copy(w[axis][:], solution[:3])
+		offset[axis] = solution[3]
+	}
+
+	// W * raw + offset = W * (raw - bias)
+	inverse, err := w.inverse()
+	if err != nil {
+		return AccelerometerCalibration{}, err
+	}
+	bias := inverse.mulVec(Vector{offset[0], offset[1], offset[2]}).Scale(-1)
+
+	return AccelerometerCalibration{Bias: bias, Scale: w}, nil
+}
+
+func component(v Vector, axis int) float64 {
+	return [3]float64{v.X, v.Y, v.Z}[axis]
+}
@@ -0,0 +1,160 @@
+package lsm303
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/mmr"
+	"periph.io/x/periph/conn/physic"
+)
+
+// Simulates a sensor with the given error model in each of the six positions.
+func sixPositions(distortion matrix3, bias Vector) map[AccelerometerPosition]Vector {
+	readings := map[AccelerometerPosition]Vector{}
+	for position := ACCELEROMETER_POSITION_X_UP; position <= ACCELEROMETER_POSITION_Z_DOWN; position++ {
+		readings[position] = distortion.mulVec(position.reference()).Add(bias)
+	}
+	return readings
+}
+
+func TestAccelerometerCalibrationBiasAndScale(t *testing.T) {
+	bias := Vector{0.05, -0.03, 0.08}
+	distortion := matrix3{{1.04, 0, 0}, {0, 0.97, 0}, {0, 0, 1.02}}
+
+	calibrator := NewAccelerometerCalibrator(nil)
+	for position, reading := range sixPositions(distortion, bias) {
+		if err := calibrator.AddMeasurement(position, reading); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	calibration, err := calibrator.Solve(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calibration.Bias.Sub(bias).Norm() > 1e-9 {
+		t.Errorf("bad bias %+v", calibration.Bias)
+	}
+	for position, reading := range sixPositions(distortion, bias) {
+		if calibration.Apply(reading).Sub(position.reference()).Norm() > 1e-9 {
+			t.Errorf("%s not corrected: %+v", position, calibration.Apply(reading))
+		}
+	}
+}
+
+func TestAccelerometerCalibrationCrossAxis(t *testing.T) {
+	bias := Vector{0.02, 0.01, -0.04}
+	distortion := matrix3{{1.03, 0.02, -0.01}, {0.015, 0.98, 0.03}, {-0.02, 0.01, 1.01}}
+
+	calibrator := NewAccelerometerCalibrator(nil)
+	for position, reading := range sixPositions(distortion, bias) {
+		if err := calibrator.AddMeasurement(position, reading); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	simple, err := calibrator.Solve(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := calibrator.Solve(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Any orientation should come out as exactly 1g with the full model
+	tilted := Vector{0.3, -0.5, 0.81}.Normalize()
+	reading := distortion.mulVec(tilted).Add(bias)
+	if full.Apply(reading).Sub(tilted).Norm() > 1e-9 {
+		t.Errorf("cross-axis calibration failed: %+v", full.Apply(reading))
+	}
+	if simple.Apply(reading).Sub(tilted).Norm() < 1e-3 {
+		t.Errorf("expected the per-axis model to miss the misalignment")
+	}
+}
+
+func TestAccelerometerCalibrationOrientationCheck(t *testing.T) {
+	calibrator := NewAccelerometerCalibrator(nil)
+	if err := calibrator.AddMeasurement(ACCELEROMETER_POSITION_X_UP, Vector{0, 0, 1}); err == nil {
+		t.Fatal("expected error for a reading in the wrong orientation")
+	}
+	if _, err := calibrator.Solve(false); err == nil {
+		t.Fatal("expected error with missing positions")
+	}
+	if len(calibrator.Missing()) != 6 {
+		t.Fatalf("expected all positions missing, got %v", calibrator.Missing())
+	}
+}
+
+func TestAccelerometerCalibrate(t *testing.T) {
+	// Two readings per position, 16384 counts on the axis pointing up, which
+	// is roughly 1g at ±2g. Each one waits for the data-ready bit, which is
+	// only up the second time it is polled.
+	address := accelerometerDatasheet.ADDRESS
+	registers := [6]uint8{
+		accelerometerDatasheet.OUT_X_L_A, accelerometerDatasheet.OUT_X_H_A,
+		accelerometerDatasheet.OUT_Y_L_A, accelerometerDatasheet.OUT_Y_H_A,
+		accelerometerDatasheet.OUT_Z_L_A, accelerometerDatasheet.OUT_Z_H_A,
+	}
+	var ops, reading []i2ctest.IO
+	for position := ACCELEROMETER_POSITION_X_UP; position <= ACCELEROMETER_POSITION_Z_DOWN; position++ {
+		for i := 0; i < 2; i++ {
+			ops = append(ops,
+				i2ctest.IO{Addr: address, W: []byte{accelerometerDatasheet.STATUS_REG_A}, R: []byte{0x00}},
+				i2ctest.IO{Addr: address, W: []byte{accelerometerDatasheet.STATUS_REG_A}, R: []byte{0x08}})
+			raw := position.reference().Scale(16384)
+			reading = nil
+			for axis, value := range [3]float64{raw.X, raw.Y, raw.Z} {
+				v := uint16(int16(value))
+				reading = append(reading,
+					i2ctest.IO{Addr: address, W: []byte{registers[2*axis]}, R: []byte{uint8(v)}},
+					i2ctest.IO{Addr: address, W: []byte{registers[2*axis+1]}, R: []byte{uint8(v >> 8)}})
+			}
+			ops = append(ops, reading...)
+		}
+	}
+
+	accelerometer := &Accelerometer{
+		mmr: mmr.Dev8{
+			Conn:  &i2c.Dev{Bus: &i2ctest.Playback{Ops: ops}, Addr: address},
+			Order: binary.BigEndian,
+		},
+		datasheet: accelerometerDatasheet,
+		range_:    ACCELEROMETER_RANGE_2G,
+		mode:      ACCELEROMETER_MODE_NORMAL,
+	}
+
+	var prompted []AccelerometerPosition
+	calibrator := NewAccelerometerCalibrator(accelerometer)
+	err := calibrator.Calibrate(context.Background(), 2, func(position AccelerometerPosition) error {
+		prompted = append(prompted, position)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prompted) != 6 {
+		t.Fatalf("expected 6 prompts, got %v", prompted)
+	}
+
+	calibration, err := calibrator.Solve(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The nominal sensitivity is slightly off 1g per 16384 counts, which the
+	// calibration should absorb.
+	WithAccelerometerCalibration(calibration).Apply(accelerometer)
+	accelerometer.mmr.Conn = &i2c.Dev{Bus: &i2ctest.Playback{Ops: reading}, Addr: address}
+	x, y, z, err := accelerometer.Sense()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(float64(x)) > 1e3 || math.Abs(float64(y)) > 1e3 || math.Abs(float64(z+physic.EarthGravity)) > 1e3 {
+		t.Fatalf("expected exactly -1g on Z, got %v %v %v", x, y, z)
+	}
+}
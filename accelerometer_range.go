@@ -0,0 +1,17 @@
+package lsm303
+
+// Returns the FS value for the range, or false if the variant doesn't have it.
+func accelerometerFullScale(sensorType SensorType, range_ AccelerometerRange) (uint8, bool) {
+	value, ok := accelerometerDriverFor(sensorType).fullScales[range_]
+	return value, ok
+}
+
+// Returns the range an FS value selects, or false if it is reserved.
+func accelerometerRangeOf(sensorType SensorType, value uint8) (AccelerometerRange, bool) {
+	for range_, fullScale := range accelerometerDriverFor(sensorType).fullScales {
+		if fullScale == value {
+			return range_, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,199 @@
+package lsm303
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// Average is the result of SenseAveraged or SenseFor. Accelerometer values are
+// in units of standard gravity, magnetometer values in gauss.
+type Average struct {
+	Mean      Vector
+	Deviation Vector
+	// Samples is the number of readings that went into Mean, Rejected the
+	// number dropped as outliers.
+	Samples  int
+	Rejected int
+}
+
+// How often the status register is polled while waiting for new data, and
+// how long to wait before giving up on a device that isn't producing any.
+var (
+	dataReadyPollInterval = time.Millisecond
+	dataReadyTimeout      = 2 * time.Second
+)
+
+type averaging struct {
+	rejectOutliers float64
+}
+
+type (
+	// AverageOption configures SenseAveraged and SenseFor.
+	AverageOption interface {
+		Apply(*averaging)
+	}
+	// AverageOptionFunc is a function that configures averaging.
+	AverageOptionFunc func(*averaging)
+)
+
+// Apply calls OptionFunc on averaging instance
+func (f AverageOptionFunc) Apply(a *averaging) {
+	f(a)
+}
+
+// WithOutlierRejection can be used to drop readings more than sigma standard
+// deviations away from the mean on any axis before averaging. 3 is a sensible
+// value. When that would drop every reading, e.g. with sigma below 1, none
+// are.
+func WithOutlierRejection(sigma float64) AverageOption {
+	return AverageOptionFunc(func(a *averaging) {
+		a.rejectOutliers = sigma
+	})
+}
+
+// SenseAveraged averages n fresh readings, waiting for the data-ready flag
+// before each one so no sample is counted twice. The calibration and axis
+// remap are applied.
+func (a *Accelerometer) SenseAveraged(ctx context.Context, n int, opts ...AverageOption) (Average, error) {
+	return senseAveraged(ctx, n, opts, a.waitDataReady, a.senseCalibratedVector)
+}
+
+// SenseFor averages all fresh readings taken during the given duration.
+func (a *Accelerometer) SenseFor(ctx context.Context, duration time.Duration, opts ...AverageOption) (Average, error) {
+	return senseFor(ctx, duration, opts, a.waitDataReady, a.senseCalibratedVector)
+}
+
+func (a *Accelerometer) waitDataReady(ctx context.Context) error {
+	return waitDataReady(ctx, func() (bool, error) {
+		status, err := a.Status()
+		return status.Ready, err
+	})
+}
+
+// SenseAveraged averages n fresh readings, waiting for the data-ready flag
+// before each one so no sample is counted twice. The calibration and axis
+// remap are applied.
+func (m *Magnetometer) SenseAveraged(ctx context.Context, n int, opts ...AverageOption) (Average, error) {
+	return senseAveraged(ctx, n, opts, m.waitDataReady, m.senseCalibratedField)
+}
+
+// SenseFor averages all fresh readings taken during the given duration.
+func (m *Magnetometer) SenseFor(ctx context.Context, duration time.Duration, opts ...AverageOption) (Average, error) {
+	return senseFor(ctx, duration, opts, m.waitDataReady, m.senseCalibratedField)
+}
+
+func (m *Magnetometer) waitDataReady(ctx context.Context) error {
+	return waitDataReady(ctx, func() (bool, error) {
+		status, err := m.Status()
+		return status.Ready, err
+	})
+}
+
+func waitDataReady(ctx context.Context, ready func() (bool, error)) error {
+	deadline := time.Now().Add(dataReadyTimeout)
+	for {
+		if ok, err := ready(); err != nil || ok {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return errors.New("no new data from the sensor, is it powered down?")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(dataReadyPollInterval):
+		}
+	}
+}
+
+func senseAveraged(ctx context.Context, n int, opts []AverageOption, wait func(context.Context) error, read func() (Vector, error)) (Average, error) {
+	if n < 1 {
+		return Average{}, errors.New("need at least one sample to average")
+	}
+	samples := make([]Vector, 0, n)
+	for len(samples) < n {
+		if err := wait(ctx); err != nil {
+			return Average{}, err
+		}
+		sample, err := read()
+		if err != nil {
+			return Average{}, err
+		}
+		samples = append(samples, sample)
+	}
+	return average(samples, opts), nil
+}
+
+func senseFor(ctx context.Context, duration time.Duration, opts []AverageOption, wait func(context.Context) error, read func() (Vector, error)) (Average, error) {
+	collecting, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	var samples []Vector
+	for {
+		if err := wait(collecting); err != nil {
+			if ctx.Err() == nil && collecting.Err() != nil {
+				break
+			}
+			return Average{}, err
+		}
+		sample, err := read()
+		if err != nil {
+			return Average{}, err
+		}
+		samples = append(samples, sample)
+	}
+
+	if len(samples) == 0 {
+		return Average{}, errors.New("no samples within the duration")
+	}
+	return average(samples, opts), nil
+}
+
+func average(samples []Vector, opts []AverageOption) Average {
+	var config averaging
+	for i := range opts {
+		opts[i].Apply(&config)
+	}
+
+	mean, deviation := statistics(samples)
+	if config.rejectOutliers <= 0 {
+		return Average{Mean: mean, Deviation: deviation, Samples: len(samples)}
+	}
+
+	kept := make([]Vector, 0, len(samples))
+	for _, sample := range samples {
+		offset := sample.Sub(mean)
+		if math.Abs(offset.X) <= config.rejectOutliers*deviation.X &&
+			math.Abs(offset.Y) <= config.rejectOutliers*deviation.Y &&
+			math.Abs(offset.Z) <= config.rejectOutliers*deviation.Z {
+			kept = append(kept, sample)
+		}
+	}
+	if len(kept) == 0 {
+		return Average{Mean: mean, Deviation: deviation, Samples: len(samples)}
+	}
+	mean, deviation = statistics(kept)
+	return Average{Mean: mean, Deviation: deviation, Samples: len(kept), Rejected: len(samples) - len(kept)}
+}
+
+// Computes the mean and sample standard deviation per axis.
+func statistics(samples []Vector) (Vector, Vector) {
+	var sum Vector
+	for _, sample := range samples {
+		sum = sum.Add(sample)
+	}
+	mean := sum.Scale(1 / float64(len(samples)))
+	if len(samples) < 2 {
+		return mean, Vector{}
+	}
+
+	var squares Vector
+	for _, sample := range samples {
+		offset := sample.Sub(mean)
+		squares = squares.Add(Vector{offset.X * offset.X, offset.Y * offset.Y, offset.Z * offset.Z})
+	}
+	variance := squares.Scale(1 / float64(len(samples)-1))
+	return mean, Vector{math.Sqrt(variance.X), math.Sqrt(variance.Y), math.Sqrt(variance.Z)}
+}
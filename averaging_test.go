@@ -0,0 +1,159 @@
+package lsm303
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/mmr"
+)
+
+func TestAccelerometerSenseAveraged(t *testing.T) {
+	address := accelerometerDatasheet.ADDRESS
+	status := func(value byte) i2ctest.IO {
+		return i2ctest.IO{Addr: address, W: []byte{accelerometerDatasheet.STATUS_REG_A}, R: []byte{value}}
+	}
+	reading := func(x, z int16) []i2ctest.IO {
+		return []i2ctest.IO{
+			{Addr: address, W: []byte{accelerometerDatasheet.OUT_X_L_A}, R: []byte{uint8(x)}},
+			{Addr: address, W: []byte{accelerometerDatasheet.OUT_X_H_A}, R: []byte{uint8(uint16(x) >> 8)}},
+			{Addr: address, W: []byte{accelerometerDatasheet.OUT_Y_L_A}, R: []byte{0}},
+			{Addr: address, W: []byte{accelerometerDatasheet.OUT_Y_H_A}, R: []byte{0}},
+			{Addr: address, W: []byte{accelerometerDatasheet.OUT_Z_L_A}, R: []byte{uint8(z)}},
+			{Addr: address, W: []byte{accelerometerDatasheet.OUT_Z_H_A}, R: []byte{uint8(uint16(z) >> 8)}},
+		}
+	}
+
+	// The second sample is only read once the data-ready bit comes up
+	var ops []i2ctest.IO
+	ops = append(ops, status(0x08))
+	ops = append(ops, reading(1600, 16000)...)
+	ops = append(ops, status(0x00), status(0x07), status(0x0F))
+	ops = append(ops, reading(-1600, 16000)...)
+
+	accelerometer := &Accelerometer{
+		mmr: mmr.Dev8{
+			Conn:  &i2c.Dev{Bus: &i2ctest.Playback{Ops: ops}, Addr: address},
+			Order: binary.BigEndian,
+		},
+		datasheet: accelerometerDatasheet,
+		range_:    ACCELEROMETER_RANGE_2G,
+		mode:      ACCELEROMETER_MODE_NORMAL,
+	}
+
+	result, err := accelerometer.SenseAveraged(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Samples != 2 || math.Abs(result.Mean.X) > 1e-9 || result.Mean.Z < 0.9 {
+		t.Fatalf("unexpected average %+v", result)
+	}
+	// Readings of ±0.1 g have a sample deviation of 0.1·√2
+	if math.Abs(result.Deviation.X/result.Mean.Z-0.1*math.Sqrt2) > 1e-9 || result.Deviation.Z != 0 {
+		t.Fatalf("unexpected deviation %+v", result.Deviation)
+	}
+}
+
+func TestMagnetometerSenseAveraged(t *testing.T) {
+	address := magnetometerDatasheet.ADDRESS
+	scenario := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: address, W: []byte{magnetometerDatasheet.SR_REG_M}, R: []byte{0x00}},
+			{Addr: address, W: []byte{magnetometerDatasheet.SR_REG_M}, R: []byte{0x01}},
+			{Addr: address, W: []byte{magnetometerDatasheet.OUT_X_L_M}, R: []byte{0xC2}},
+			{Addr: address, W: []byte{magnetometerDatasheet.OUT_X_H_M}, R: []byte{0x01}},
+			{Addr: address, W: []byte{magnetometerDatasheet.OUT_Y_L_M}, R: []byte{0}},
+			{Addr: address, W: []byte{magnetometerDatasheet.OUT_Y_H_M}, R: []byte{0}},
+			{Addr: address, W: []byte{magnetometerDatasheet.OUT_Z_L_M}, R: []byte{0x38}},
+			{Addr: address, W: []byte{magnetometerDatasheet.OUT_Z_H_M}, R: []byte{0xFF}},
+		},
+	}
+
+	magnetometer := &Magnetometer{
+		mmr: mmr.Dev8{
+			Conn:  &i2c.Dev{Bus: scenario, Addr: address},
+			Order: binary.BigEndian,
+		},
+		sensorType: LSM303DLHC,
+		datasheet:  magnetometerDatasheet,
+		gain:       MAGNETOMETER_GAIN_4_0,
+		rate:       MAGNETOMETER_RATE_30,
+	}
+
+	result, err := magnetometer.SenseAveraged(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Mean != (Vector{1, 0, -0.5}) || result.Deviation != (Vector{}) {
+		t.Fatalf("unexpected average %+v", result)
+	}
+}
+
+func TestOutlierRejection(t *testing.T) {
+	samples := make([]Vector, 20)
+	for i := range samples {
+		samples[i] = Vector{float64(i%2)*0.02 - 0.01, 0, 1}
+	}
+	samples[7] = Vector{0.5, 0, 1}
+
+	plain := average(samples, nil)
+	if plain.Rejected != 0 || plain.Mean.X < 0.02 {
+		t.Fatalf("expected the spike to pull the plain mean, got %+v", plain)
+	}
+
+	robust := average(samples, []AverageOption{WithOutlierRejection(3)})
+	if robust.Rejected != 1 || robust.Samples != 19 {
+		t.Fatalf("expected exactly the spike rejected, got %+v", robust)
+	}
+	if math.Abs(robust.Mean.X) > 0.001 || robust.Deviation.X > 0.011 {
+		t.Fatalf("unexpected robust average %+v", robust)
+	}
+
+	// Every reading is further than 0.5σ from the mean
+	strict := average(samples[:2], []AverageOption{WithOutlierRejection(0.5)})
+	if strict.Samples != 2 || strict.Rejected != 0 || strict.Mean != (Vector{0, 0, 1}) {
+		t.Fatalf("expected the plain average when nothing is kept, got %+v", strict)
+	}
+}
+
+func TestSenseFor(t *testing.T) {
+	ready := time.NewTicker(time.Millisecond)
+	defer ready.Stop()
+	wait := func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ready.C:
+			return nil
+		}
+	}
+	read := func() (Vector, error) {
+		return Vector{Z: 1}, nil
+	}
+
+	result, err := senseFor(context.Background(), 30*time.Millisecond, nil, wait, read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Samples < 5 || result.Mean != (Vector{Z: 1}) {
+		t.Fatalf("unexpected average %+v", result)
+	}
+
+	// Cancelling the parent context is an error, unlike the duration running out
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := senseFor(ctx, time.Second, nil, wait, read); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestSenseAveragedNeedsSamples(t *testing.T) {
+	if _, err := playbackAccelerometer().SenseAveraged(context.Background(), 0); err == nil {
+		t.Fatal("expected an error for zero samples")
+	}
+}
@@ -0,0 +1,180 @@
+package lsm303
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Direction is where a sensor axis points on the body the sensor is mounted
+// on. The body frame is X forward, Y left, Z up (AXIS_CONVENTION_NWU), the same
+// as a breakout board lying face up.
+type Direction int
+
+const (
+	DIRECTION_FORWARD Direction = iota
+	DIRECTION_BACKWARD
+	DIRECTION_LEFT
+	DIRECTION_RIGHT
+	DIRECTION_UP
+	DIRECTION_DOWN
+)
+
+func (direction Direction) String() string {
+	names := [...]string{"forward", "backward", "left", "right", "up", "down"}
+	if direction < 0 || int(direction) >= len(names) {
+		return fmt.Sprintf("Direction(%d)", direction)
+	}
+	return names[direction]
+}
+
+func (direction Direction) vector() Vector {
+	return [...]Vector{
+		{1, 0, 0}, {-1, 0, 0},
+		{0, 1, 0}, {0, -1, 0},
+		{0, 0, 1}, {0, 0, -1},
+	}[direction]
+}
+
+// AxisRemap rotates (or flips) readings from the sensor axes into the body
+// frame, so code downstream doesn't have to care how the board is mounted.
+// It is the only mounting setting: the compass, inclinometer and orientation
+// filters all take the body frame.
+type AxisRemap struct {
+	// Columns are the body frame directions of the sensor X, Y and Z axes
+	matrix matrix3
+}
+
+// Presets for the usual mountings, named after where the sensor X and Z axes
+// point.
+var (
+	AXIS_REMAP_X_FORWARD_Z_UP    = mustParseAxisRemap("X-forward Z-up")
+	AXIS_REMAP_X_FORWARD_Z_DOWN  = mustParseAxisRemap("X-forward Z-down")
+	AXIS_REMAP_X_BACKWARD_Z_UP   = mustParseAxisRemap("X-backward Z-up")
+	AXIS_REMAP_X_BACKWARD_Z_DOWN = mustParseAxisRemap("X-backward Z-down")
+	AXIS_REMAP_X_LEFT_Z_UP       = mustParseAxisRemap("X-left Z-up")
+	AXIS_REMAP_X_RIGHT_Z_UP      = mustParseAxisRemap("X-right Z-up")
+	AXIS_REMAP_X_LEFT_Z_DOWN     = mustParseAxisRemap("X-left Z-down")
+	AXIS_REMAP_X_RIGHT_Z_DOWN    = mustParseAxisRemap("X-right Z-down")
+	AXIS_REMAP_X_UP_Z_FORWARD    = mustParseAxisRemap("X-up Z-forward")
+	AXIS_REMAP_X_DOWN_Z_FORWARD  = mustParseAxisRemap("X-down Z-forward")
+)
+
+// NewAxisRemap builds a signed permutation from the directions the sensor X, Y
+// and Z axes point in. Unlike ParseAxisRemap it accepts mirrored frames, for
+// boards with an axis wired backwards.
+func NewAxisRemap(x, y, z Direction) (AxisRemap, error) {
+	columns := [3]Vector{x.vector(), y.vector(), z.vector()}
+	if columns[0].Dot(columns[1]) != 0 || columns[0].Dot(columns[2]) != 0 || columns[1].Dot(columns[2]) != 0 {
+		return AxisRemap{}, fmt.Errorf("axes %s, %s and %s are not perpendicular", x, y, z)
+	}
+	return axisRemapFromColumns(columns), nil
+}
+
+// AxisRemapFromMatrix builds a remap from an arbitrary rotation matrix, body =
+// m * sensor. It is meant for boards mounted at odd angles.
+func AxisRemapFromMatrix(m [3][3]float64) (AxisRemap, error) {
+	product := matrix3(m).mul(matrix3(m).transpose())
+	identity := identity3()
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			if math.Abs(product[i][j]-identity[i][j]) > 1e-6 {
+				return AxisRemap{}, fmt.Errorf("%v is not a rotation matrix", m)
+			}
+		}
+	}
+	return AxisRemap{matrix: matrix3(m)}, nil
+}
+
+// ParseAxisRemap parses mountings like "X-forward Z-down": two sensor axes and
+// the directions they point in, the third axis follows from the right-hand
+// rule. All three axes can be given too, e.g. "X-left Y-up Z-forward".
+func ParseAxisRemap(s string) (AxisRemap, error) {
+	var columns [3]Vector
+	var given [3]bool
+	tokens := strings.Fields(s)
+	if len(tokens) < 2 || len(tokens) > 3 {
+		return AxisRemap{}, fmt.Errorf("axis remap %q should name two or three axes", s)
+	}
+
+	for _, token := range tokens {
+		parts := strings.SplitN(token, "-", 2)
+		if len(parts) != 2 {
+			return AxisRemap{}, fmt.Errorf("bad axis %q in %q, expected e.g. X-forward", token, s)
+		}
+		axis := strings.Index("XYZ", strings.ToUpper(parts[0]))
+		if len(parts[0]) != 1 || axis < 0 {
+			return AxisRemap{}, fmt.Errorf("bad axis %q in %q", parts[0], s)
+		}
+		direction := -1
+		for d := DIRECTION_FORWARD; d <= DIRECTION_DOWN; d++ {
+			if strings.EqualFold(parts[1], d.String()) {
+				direction = int(d)
+			}
+		}
+		if direction < 0 {
+			return AxisRemap{}, fmt.Errorf("bad direction %q in %q", parts[1], s)
+		}
+		if given[axis] {
+			return AxisRemap{}, fmt.Errorf("axis %s given twice in %q", parts[0], s)
+		}
+		given[axis] = true
+		columns[axis] = Direction(direction).vector()
+	}
+
+	// Fill in the missing axis: X = Y × Z, Y = Z × X, Z = X × Y
+	for axis := 0; axis < 3; axis++ {
+		if !given[axis] {
+			columns[axis] = columns[(axis+1)%3].Cross(columns[(axis+2)%3])
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		for j := i + 1; j < 3; j++ {
+			if columns[i].Dot(columns[j]) != 0 {
+				return AxisRemap{}, fmt.Errorf("axes in %q are not perpendicular", s)
+			}
+		}
+	}
+	if columns[0].Cross(columns[1]).Dot(columns[2]) < 0 {
+		return AxisRemap{}, fmt.Errorf("%q describes a mirrored frame", s)
+	}
+
+	return axisRemapFromColumns(columns), nil
+}
+
+func mustParseAxisRemap(s string) AxisRemap {
+	remap, err := ParseAxisRemap(s)
+	if err != nil {
+		panic(err)
+	}
+	return remap
+}
+
+func axisRemapFromColumns(columns [3]Vector) AxisRemap {
+	var m matrix3
+	for col, v := range columns {
+		m[0][col], m[1][col], m[2][col] = v.X, v.Y, v.Z
+	}
+	return AxisRemap{matrix: m}
+}
+
+// Matrix returns the rotation from the sensor to the body frame.
+func (r AxisRemap) Matrix() [3][3]float64 {
+	return r.matrix
+}
+
+// Apply rotates a reading from the sensor frame into the body frame.
+func (r AxisRemap) Apply(v Vector) Vector {
+	return r.matrix.mulVec(v)
+}
+
+// Rotates a raw reading, rounding and saturating to the int16 range.
+func (r AxisRemap) applyRaw(x, y, z int16) (int16, int16, int16) {
+	v := r.Apply(Vector{float64(x), float64(y), float64(z)})
+	return saturate(v.X), saturate(v.Y), saturate(v.Z)
+}
+
+func saturate(value float64) int16 {
+	return int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, math.Round(value))))
+}
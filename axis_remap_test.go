@@ -0,0 +1,147 @@
+package lsm303
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/mmr"
+)
+
+func TestParseAxisRemap(t *testing.T) {
+	tests := []struct {
+		mounting string
+		sensor   Vector
+		body     Vector
+	}{
+		{"X-forward Z-up", Vector{1, 2, 3}, Vector{1, 2, 3}},
+		// Upside down: Y now points right
+		{"X-forward Z-down", Vector{1, 2, 3}, Vector{1, -2, -3}},
+		{"x-backward z-up", Vector{1, 2, 3}, Vector{-1, -2, 3}},
+		{"Y-forward Z-up", Vector{1, 2, 3}, Vector{2, -1, 3}},
+		{"X-up Z-forward", Vector{1, 2, 3}, Vector{3, -2, 1}},
+		{"Z-forward X-up", Vector{1, 2, 3}, Vector{3, -2, 1}},
+		{"X-left Y-up Z-forward", Vector{1, 2, 3}, Vector{3, 1, 2}},
+	}
+
+	for _, test := range tests {
+		remap, err := ParseAxisRemap(test.mounting)
+		if err != nil {
+			t.Errorf("%q: %v", test.mounting, err)
+			continue
+		}
+		if body := remap.Apply(test.sensor); body != test.body {
+			t.Errorf("%q: expected %+v, got %+v", test.mounting, test.body, body)
+		}
+		if det := matrix3(remap.Matrix()).det(); det != 1 {
+			t.Errorf("%q: determinant is %v", test.mounting, det)
+		}
+	}
+}
+
+func TestParseAxisRemapErrors(t *testing.T) {
+	for _, mounting := range []string{
+		"",
+		"X-forward",
+		"X-forward X-up",
+		"X-forward Y-backward",
+		"X-forward Z-sideways",
+		"W-forward Z-up",
+		"X-forward Y-right Z-up",
+		"Xforward Zup",
+	} {
+		if _, err := ParseAxisRemap(mounting); err == nil {
+			t.Errorf("%q: expected an error", mounting)
+		}
+	}
+}
+
+func TestNewAxisRemap(t *testing.T) {
+	remap, err := NewAxisRemap(DIRECTION_FORWARD, DIRECTION_RIGHT, DIRECTION_DOWN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remap != AXIS_REMAP_X_FORWARD_Z_DOWN {
+		t.Fatalf("expected %v, got %v", AXIS_REMAP_X_FORWARD_Z_DOWN.Matrix(), remap.Matrix())
+	}
+
+	if _, err := NewAxisRemap(DIRECTION_FORWARD, DIRECTION_BACKWARD, DIRECTION_UP); err == nil {
+		t.Fatal("expected an error for parallel axes")
+	}
+}
+
+func TestAxisRemapFromMatrix(t *testing.T) {
+	// 30° around Z
+	remap, err := AxisRemapFromMatrix([3][3]float64{
+		{0.8660254037844387, -0.5, 0},
+		{0.5, 0.8660254037844387, 0},
+		{0, 0, 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := remap.Apply(Vector{1, 0, 0}); v.Sub(Vector{0.8660254037844387, 0.5, 0}).Norm() > 1e-12 {
+		t.Fatalf("unexpected rotation %+v", v)
+	}
+
+	if _, err := AxisRemapFromMatrix([3][3]float64{{2, 0, 0}, {0, 1, 0}, {0, 0, 1}}); err == nil {
+		t.Fatal("expected an error for a scaling matrix")
+	}
+}
+
+func TestAccelerometerAxisRemap(t *testing.T) {
+	accelerometer := playbackAccelerometer(Vector{100, -200, 16000}, Vector{100, -200, 16000})
+	WithAccelerometerAxisRemap(AXIS_REMAP_X_LEFT_Z_DOWN).Apply(accelerometer)
+
+	x, y, z, err := accelerometer.SenseRaw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x != -200 || y != 100 || z != -16000 {
+		t.Fatalf("unexpected raw reading %v %v %v", x, y, z)
+	}
+
+	vector, err := accelerometer.senseVector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vector.Z > -0.9 {
+		t.Fatalf("expected gravity along -Z in the body frame, got %+v", vector)
+	}
+}
+
+func TestMagnetometerAxisRemap(t *testing.T) {
+	scenario := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_X_L_M}, R: []byte{0xC2}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_X_H_M}, R: []byte{0x01}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_Y_L_M}, R: []byte{0}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_Y_H_M}, R: []byte{0}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_Z_L_M}, R: []byte{0x38}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_Z_H_M}, R: []byte{0xFF}},
+		},
+	}
+
+	magnetometer := &Magnetometer{
+		mmr: mmr.Dev8{
+			Conn:  &i2c.Dev{Bus: scenario, Addr: magnetometerDatasheet.ADDRESS},
+			Order: binary.BigEndian,
+		},
+		sensorType: LSM303DLHC,
+		datasheet:  magnetometerDatasheet,
+		gain:       MAGNETOMETER_GAIN_4_0,
+		rate:       MAGNETOMETER_RATE_30,
+	}
+	WithMagnetometerAxisRemap(AXIS_REMAP_X_UP_Z_FORWARD).Apply(magnetometer)
+
+	// Raw 450 on X is 1 gauss, -200 on Z is -0.5 gauss; the Z sensitivity
+	// must be applied before the axes are swapped.
+	x, y, z, err := magnetometer.Sense()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x != -Gauss/2 || y != 0 || z != Gauss {
+		t.Fatalf("unexpected field %v %v %v", x, y, z)
+	}
+}
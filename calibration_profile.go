@@ -0,0 +1,162 @@
+package lsm303
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// The current CalibrationProfile format version. Bump it whenever the meaning
+// of a field changes, and keep decoding older versions.
+const calibrationProfileVersion = 1
+
+// CalibrationProfile holds the calibration of one LSM303 board together with
+// the configuration it was recorded with, so it can be stored across reboots.
+type CalibrationProfile struct {
+	Version    int        `json:"version"`
+	SensorType SensorType `json:"sensor_type"`
+	// Free-form identifier of the board, e.g. a serial number
+	Serial  string    `json:"serial,omitempty"`
+	Created time.Time `json:"created"`
+
+	AccelerometerAddress uint16                    `json:"accelerometer_address,omitempty"`
+	AccelerometerRange   AccelerometerRange        `json:"accelerometer_range"`
+	Accelerometer        *AccelerometerCalibration `json:"accelerometer,omitempty"`
+
+	MagnetometerAddress uint16                   `json:"magnetometer_address,omitempty"`
+	MagnetometerGain    MagnetometerGain         `json:"magnetometer_gain"`
+	Magnetometer        *MagnetometerCalibration `json:"magnetometer,omitempty"`
+}
+
+// NewCalibrationProfile records the configuration and the calibration
+// currently applied to the given sensors. Either of them can be nil, both
+// have to be of the same variant.
+func NewCalibrationProfile(accelerometer *Accelerometer, magnetometer *Magnetometer) (*CalibrationProfile, error) {
+	if accelerometer != nil && magnetometer != nil && accelerometer.sensorType != magnetometer.sensorType {
+		return nil, fmt.Errorf("accelerometer is an %s and magnetometer an %s", accelerometer.sensorType, magnetometer.sensorType)
+	}
+
+	profile := &CalibrationProfile{
+		Version: calibrationProfileVersion,
+		Created: time.Now().UTC(),
+	}
+
+	if accelerometer != nil {
+		profile.SensorType = accelerometer.sensorType
+		if accelerometer.addr != nil {
+			profile.AccelerometerAddress = *accelerometer.addr
+		}
+		profile.AccelerometerRange = accelerometer.range_
+		profile.Accelerometer = accelerometer.calibration
+	}
+	if magnetometer != nil {
+		profile.SensorType = magnetometer.sensorType
+		if magnetometer.addr != nil {
+			profile.MagnetometerAddress = *magnetometer.addr
+		}
+		profile.MagnetometerGain = magnetometer.gain
+		profile.Magnetometer = magnetometer.calibration
+	}
+
+	return profile, nil
+}
+
+// LoadCalibrationProfile reads a profile saved with Save.
+func LoadCalibrationProfile(path string) (*CalibrationProfile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return DecodeCalibrationProfile(file)
+}
+
+// DecodeCalibrationProfile reads a JSON encoded profile.
+func DecodeCalibrationProfile(r io.Reader) (*CalibrationProfile, error) {
+	profile := &CalibrationProfile{}
+	if err := json.NewDecoder(r).Decode(profile); err != nil {
+		return nil, fmt.Errorf("invalid calibration profile: %v", err)
+	}
+	if profile.Version < 1 || profile.Version > calibrationProfileVersion {
+		return nil, fmt.Errorf("unsupported calibration profile version %d", profile.Version)
+	}
+	return profile, nil
+}
+
+// Save writes the profile to path as JSON.
+func (p *CalibrationProfile) Save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := p.Encode(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Encode writes the profile as indented JSON.
+func (p *CalibrationProfile) Encode(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+// CheckAccelerometer returns an error if the profile's accelerometer
+// calibration was recorded for a different sensor type or range.
+func (p *CalibrationProfile) CheckAccelerometer(accelerometer *Accelerometer) error {
+	if p.Accelerometer == nil {
+		return fmt.Errorf("profile has no accelerometer calibration")
+	}
+	if p.SensorType != accelerometer.sensorType {
+		return fmt.Errorf("profile was recorded for %s, not %s", p.SensorType, accelerometer.sensorType)
+	}
+	if p.AccelerometerRange != accelerometer.range_ {
+		return fmt.Errorf("profile was recorded at range %s, accelerometer is at %s", p.AccelerometerRange, accelerometer.range_)
+	}
+	return nil
+}
+
+// CheckMagnetometer returns an error if the profile's magnetometer calibration
+// was recorded for a different sensor type or gain.
+func (p *CalibrationProfile) CheckMagnetometer(magnetometer *Magnetometer) error {
+	if p.Magnetometer == nil {
+		return fmt.Errorf("profile has no magnetometer calibration")
+	}
+	if p.SensorType != magnetometer.sensorType {
+		return fmt.Errorf("profile was recorded for %s, not %s", p.SensorType, magnetometer.sensorType)
+	}
+	// Only variants with a gain field (the DLHC) have a configurable gain
+	if magnetometer.fields().gain.Width != 0 && p.MagnetometerGain != magnetometer.gain {
+		return fmt.Errorf("profile was recorded at gain %s, magnetometer is at %s", p.MagnetometerGain, magnetometer.gain)
+	}
+	return nil
+}
+
+// Apply checks the profile against the sensors and installs its calibrations.
+// Either sensor can be nil.
+func (p *CalibrationProfile) Apply(accelerometer *Accelerometer, magnetometer *Magnetometer) error {
+	if accelerometer != nil {
+		if err := p.CheckAccelerometer(accelerometer); err != nil {
+			return err
+		}
+	}
+	if magnetometer != nil {
+		if err := p.CheckMagnetometer(magnetometer); err != nil {
+			return err
+		}
+	}
+
+	if accelerometer != nil {
+		calibration := *p.Accelerometer
+		accelerometer.SetCalibration(&calibration)
+	}
+	if magnetometer != nil {
+		calibration := *p.Magnetometer
+		magnetometer.SetCalibration(&calibration)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package lsm303
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCalibrationProfileRoundTrip(t *testing.T) {
+	address := uint16(0x19)
+	accelerometer := &Accelerometer{
+		sensorType: LSM303DLHC,
+		addr:       &address,
+		range_:     ACCELEROMETER_RANGE_8G,
+		calibration: &AccelerometerCalibration{
+			Bias:  Vector{0.01, -0.02, 0.03},
+			Scale: [3][3]float64{{1.01, 0, 0}, {0, 0.99, 0}, {0, 0, 1.02}},
+		},
+	}
+	magnetometer := &Magnetometer{
+		sensorType: LSM303DLHC,
+		gain:       MAGNETOMETER_GAIN_1_9,
+		calibration: &MagnetometerCalibration{
+			Offset:   Vector{0.1, 0.2, -0.3},
+			SoftIron: [3][3]float64{{1, 0.1, 0}, {0.1, 1, 0}, {0, 0, 1}},
+		},
+	}
+
+	profile, err := NewCalibrationProfile(accelerometer, magnetometer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile.Serial = "board-7"
+	path := filepath.Join(t.TempDir(), "profile.json")
+	if err := profile.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadCalibrationProfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Serial != "board-7" || loaded.AccelerometerAddress != 0x19 || loaded.AccelerometerRange != ACCELEROMETER_RANGE_8G || loaded.MagnetometerGain != MAGNETOMETER_GAIN_1_9 {
+		t.Fatalf("metadata lost: %+v", loaded)
+	}
+	if *loaded.Accelerometer != *accelerometer.calibration || *loaded.Magnetometer != *magnetometer.calibration {
+		t.Fatalf("calibration lost: %+v", loaded)
+	}
+
+	fresh := &Accelerometer{sensorType: LSM303DLHC, range_: ACCELEROMETER_RANGE_8G}
+	freshMagnetometer := &Magnetometer{sensorType: LSM303DLHC, gain: MAGNETOMETER_GAIN_1_9}
+	if err := loaded.Apply(fresh, freshMagnetometer); err != nil {
+		t.Fatal(err)
+	}
+	if fresh.calibration == nil || freshMagnetometer.calibration == nil {
+		t.Fatal("calibration not applied")
+	}
+}
+
+func TestCalibrationProfileEncoding(t *testing.T) {
+	profile, err := NewCalibrationProfile(&Accelerometer{sensorType: LSM303AGR, range_: ACCELEROMETER_RANGE_2G}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buffer bytes.Buffer
+	if err := profile.Encode(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`"version": 1`, `"sensor_type": "LSM303AGR"`, `"accelerometer_range": "2G"`} {
+		if !strings.Contains(buffer.String(), expected) {
+			t.Errorf("expected %s in %s", expected, buffer.String())
+		}
+	}
+
+	if _, err := DecodeCalibrationProfile(strings.NewReader(`{"version": 99}`)); err == nil {
+		t.Error("expected error for a newer version")
+	}
+	if _, err := DecodeCalibrationProfile(strings.NewReader(`{"sensor_type": "LSM303C"}`)); err == nil {
+		t.Error("expected error for a missing version")
+	}
+	if _, err := DecodeCalibrationProfile(strings.NewReader(`{"version": 1, "accelerometer_range": "3G"}`)); err == nil {
+		t.Error("expected error for an unknown range")
+	}
+}
+
+func TestCalibrationProfileRefusesMismatch(t *testing.T) {
+	profile := &CalibrationProfile{
+		Version:            calibrationProfileVersion,
+		SensorType:         LSM303DLHC,
+		AccelerometerRange: ACCELEROMETER_RANGE_4G,
+		Accelerometer:      &AccelerometerCalibration{},
+		MagnetometerGain:   MAGNETOMETER_GAIN_4_0,
+		Magnetometer:       &MagnetometerCalibration{},
+	}
+
+	if err := profile.CheckAccelerometer(&Accelerometer{sensorType: LSM303C, range_: ACCELEROMETER_RANGE_4G}); err == nil {
+		t.Error("expected error for a different sensor type")
+	}
+	if err := profile.CheckAccelerometer(&Accelerometer{sensorType: LSM303DLHC, range_: ACCELEROMETER_RANGE_16G}); err == nil {
+		t.Error("expected error for a different range")
+	}
+	if err := profile.CheckMagnetometer(&Magnetometer{sensorType: LSM303DLHC, gain: MAGNETOMETER_GAIN_8_1}); err == nil {
+		t.Error("expected error for a different gain")
+	}
+
+	accelerometer := &Accelerometer{sensorType: LSM303DLHC, range_: ACCELEROMETER_RANGE_2G}
+	if err := profile.Apply(accelerometer, nil); err == nil || accelerometer.calibration != nil {
+		t.Error("a mismatched profile must not be applied")
+	}
+}
+
+func TestCalibrationProfileMismatchedSensors(t *testing.T) {
+	if _, err := NewCalibrationProfile(&Accelerometer{sensorType: LSM303AGR}, &Magnetometer{sensorType: LSM303C}); err == nil {
+		t.Error("recorded an AGR accelerometer with a C magnetometer")
+	}
+}
+
+func TestSettingsText(t *testing.T) {
+	var gain MagnetometerGain
+	if err := gain.UnmarshalText([]byte("5.6")); err != nil || gain != MAGNETOMETER_GAIN_5_6 {
+		t.Errorf("5.6 is %v, %v", gain, err)
+	}
+	if _, err := AccelerometerRange(4).MarshalText(); err == nil {
+		t.Error("marshalled an unknown range")
+	}
+	if _, err := MagnetometerGain(-1).MarshalText(); err == nil {
+		t.Error("marshalled an unknown gain")
+	}
+}
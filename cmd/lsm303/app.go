@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+	"periph.io/x/periph/conn/i2c"
+)
+
+// app holds what the commands share: the bus, the sensors opened on it and
+// the configuration given on the command line.
+type app struct {
+	ctx         context.Context
+	stdin       io.Reader
+	stdout      io.Writer
+	bus         i2c.Bus
+	sensorType  lsm303.SensorType
+	profilePath string
+	// Configuration flags in the order given, applied once the sensor they
+	// belong to is opened
+	settings      [][2]string
+	profile       *lsm303.CalibrationProfile
+	accelerometer *lsm303.Accelerometer
+	magnetometer  *lsm303.Magnetometer
+}
+
+// apply queues a configuration flag. It is checked and applied when the
+// sensor is opened, since the valid values depend on the variant.
+func (a *app) apply(setting, value string) error {
+	a.settings = append(a.settings, [2]string{setting, value})
+	return nil
+}
+
+// variant returns the sensor type given with -type, or detects it.
+func (a *app) variant() (lsm303.SensorType, error) {
+	if a.sensorType == "" {
+		sensorType, err := lsm303.Detect(a.bus)
+		if err != nil {
+			return "", err
+		}
+		a.sensorType = sensorType
+	}
+	return a.sensorType, nil
+}
+
+func (a *app) openAccelerometer() (*lsm303.Accelerometer, error) {
+	if a.accelerometer != nil {
+		return a.accelerometer, nil
+	}
+	sensorType, err := a.variant()
+	if err != nil {
+		return nil, err
+	}
+	accelerometer, err := lsm303.NewAccelerometer(a.bus, lsm303.WithAccelerometerSensorType(sensorType), lsm303.WithAccelerometerCurrentConfiguration())
+	if err != nil {
+		return nil, err
+	}
+	a.accelerometer = accelerometer
+
+	for _, setting := range a.settings {
+		if setting[0] == "range" || setting[0] == "mode" {
+			if err := a.change(setting[0], setting[1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	profile, err := a.loadProfile()
+	if err != nil || profile == nil || profile.Accelerometer == nil {
+		return accelerometer, err
+	}
+	return accelerometer, profile.Apply(accelerometer, nil)
+}
+
+func (a *app) openMagnetometer() (*lsm303.Magnetometer, error) {
+	if a.magnetometer != nil {
+		return a.magnetometer, nil
+	}
+	sensorType, err := a.variant()
+	if err != nil {
+		return nil, err
+	}
+	magnetometer, err := lsm303.NewMagnetometer(a.bus, lsm303.WithMagnetometerSensorType(sensorType), lsm303.WithMagnetometerCurrentConfiguration())
+	if err != nil {
+		return nil, err
+	}
+	a.magnetometer = magnetometer
+
+	for _, setting := range a.settings {
+		if setting[0] == "gain" || setting[0] == "rate" {
+			if err := a.change(setting[0], setting[1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	profile, err := a.loadProfile()
+	if err != nil || profile == nil || profile.Magnetometer == nil {
+		return magnetometer, err
+	}
+	return magnetometer, profile.Apply(nil, magnetometer)
+}
+
+func (a *app) loadProfile() (*lsm303.CalibrationProfile, error) {
+	if a.profile == nil && a.profilePath != "" {
+		profile, err := lsm303.LoadCalibrationProfile(a.profilePath)
+		if err != nil {
+			return nil, err
+		}
+		a.profile = profile
+	}
+	return a.profile, nil
+}
+
+// change writes one setting to the sensor it belongs to.
+func (a *app) change(setting, value string) error {
+	switch setting {
+	case "range":
+		var range_ lsm303.AccelerometerRange
+		if err := range_.UnmarshalText([]byte(strings.ToUpper(value))); err != nil {
+			return err
+		}
+		accelerometer, err := a.openAccelerometer()
+		if err != nil {
+			return err
+		}
+		return accelerometer.SetRange(range_)
+	case "mode":
+		mode, err := parseMode(value)
+		if err != nil {
+			return err
+		}
+		accelerometer, err := a.openAccelerometer()
+		if err != nil {
+			return err
+		}
+		return accelerometer.SetMode(mode)
+	case "gain":
+		var gain lsm303.MagnetometerGain
+		if err := gain.UnmarshalText([]byte(value)); err != nil {
+			return err
+		}
+		magnetometer, err := a.openMagnetometer()
+		if err != nil {
+			return err
+		}
+		return magnetometer.SetGain(gain)
+	case "rate":
+		magnetometer, err := a.openMagnetometer()
+		if err != nil {
+			return err
+		}
+		rate, err := parseRate(a.sensorType, value)
+		if err != nil {
+			return err
+		}
+		return magnetometer.SetRate(rate)
+	}
+	return fmt.Errorf("unknown setting %q", setting)
+}
+
+func parseMode(s string) (lsm303.AccelerometerMode, error) {
+	for mode := lsm303.ACCELEROMETER_MODE_NORMAL; mode <= lsm303.ACCELEROMETER_MODE_LOW_POWER; mode++ {
+		if strings.ReplaceAll(mode.String(), " ", "-") == s {
+			return mode, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown accelerometer mode %q", s)
+}
+
+func parseRate(sensorType lsm303.SensorType, s string) (lsm303.MagnetometerRate, error) {
+	hz, err := strconv.ParseFloat(strings.TrimSuffix(s, "Hz"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid magnetometer rate %q", s)
+	}
+	return lsm303.MagnetometerRateOf(sensorType, hz)
+}
+
+func formatRate(sensorType lsm303.SensorType, rate lsm303.MagnetometerRate) string {
+	if hz, ok := rate.Hertz(sensorType); ok {
+		return fmt.Sprintf("%g Hz", hz)
+	}
+	return fmt.Sprintf("setting %d", rate)
+}
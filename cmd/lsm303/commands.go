@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+)
+
+func (a *app) detect(args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	sensorType, err := lsm303.Detect(a.bus)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "%s on %s\n", sensorType, a.bus)
+	return nil
+}
+
+func (a *app) info(args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	accelerometer, err := a.openAccelerometer()
+	if err != nil {
+		return err
+	}
+	magnetometer, err := a.openMagnetometer()
+	if err != nil {
+		return err
+	}
+
+	mode, err := accelerometer.GetMode()
+	if err != nil {
+		return err
+	}
+	range_, err := accelerometer.GetRange()
+	if err != nil {
+		return err
+	}
+	rate, err := magnetometer.GetRate()
+	if err != nil {
+		return err
+	}
+	gain := "fixed"
+	if a.sensorType == lsm303.LSM303DLHC {
+		value, err := magnetometer.GetGain()
+		if err != nil {
+			return err
+		}
+		gain = value.String() + " gauss"
+	}
+
+	fmt.Fprintf(a.stdout, "sensor         %s on %s\n", a.sensorType, a.bus)
+	fmt.Fprintf(a.stdout, "accelerometer  mode %s, range %s\n", mode, range_)
+	fmt.Fprintf(a.stdout, "magnetometer   gain %s, rate %s\n", gain, formatRate(a.sensorType, rate))
+	if a.sensorType == lsm303.LSM303AGR {
+		// The driver doesn't read the AGR temperature sensor
+		return nil
+	}
+	temperature, err := magnetometer.SenseRelativeTemperature()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "temperature    %s relative\n", temperature)
+	return nil
+}
+
+func (a *app) dump(args []string) error {
+	flags := flag.NewFlagSet("dump", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	asJSON := flags.Bool("json", false, "")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 1 {
+		return errUsage
+	}
+	device := flags.Arg(0)
+
+	var dumps []lsm303.RegisterDump
+	if device == "" || device == "accelerometer" {
+		accelerometer, err := a.openAccelerometer()
+		if err != nil {
+			return err
+		}
+		dump, err := accelerometer.DumpRegisters()
+		if err != nil {
+			return err
+		}
+		dumps = append(dumps, dump)
+	}
+	if device == "" || device == "magnetometer" {
+		magnetometer, err := a.openMagnetometer()
+		if err != nil {
+			return err
+		}
+		dump, err := magnetometer.DumpRegisters()
+		if err != nil {
+			return err
+		}
+		dumps = append(dumps, dump)
+	}
+	if len(dumps) == 0 {
+		return errUsage
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(a.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(dumps)
+	}
+	for _, dump := range dumps {
+		fmt.Fprint(a.stdout, dump)
+	}
+	return nil
+}
+
+// reading is one line of the read output. Acceleration is in units of
+// standard gravity and the magnetic field in microtesla, or both in counts
+// with -raw.
+type reading struct {
+	Time          time.Time     `json:"time"`
+	Acceleration  lsm303.Vector `json:"acceleration"`
+	MagneticField lsm303.Vector `json:"magnetic_field"`
+}
+
+func (a *app) read(args []string) error {
+	flags := flag.NewFlagSet("read", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	rate := flags.Float64("rate", 0, "")
+	n := flags.Int("n", 0, "")
+	format := flags.String("format", "text", "")
+	raw := flags.Bool("raw", false, "")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 || *rate < 0 || *n < 0 {
+		return errUsage
+	}
+	write, err := readingWriter(a.stdout, *format, *raw)
+	if err != nil {
+		return err
+	}
+
+	accelerometer, err := a.openAccelerometer()
+	if err != nil {
+		return err
+	}
+	magnetometer, err := a.openMagnetometer()
+	if err != nil {
+		return err
+	}
+	sense := func() (reading, error) {
+		r := reading{Time: time.Now()}
+		if *raw {
+			x, y, z, err := accelerometer.SenseRaw()
+			if err != nil {
+				return r, err
+			}
+			r.Acceleration = lsm303.Vector{X: float64(x), Y: float64(y), Z: float64(z)}
+			x, y, z, err = magnetometer.SenseRaw()
+			r.MagneticField = lsm303.Vector{X: float64(x), Y: float64(y), Z: float64(z)}
+			return r, err
+		}
+		// Averaging one sample waits for a fresh one
+		acceleration, err := accelerometer.SenseAveraged(a.ctx, 1)
+		if err != nil {
+			return r, err
+		}
+		field, err := magnetometer.SenseAveraged(a.ctx, 1)
+		r.Acceleration = acceleration.Mean
+		r.MagneticField = field.Mean.Scale(float64(lsm303.Gauss) / float64(lsm303.MicroTesla))
+		return r, err
+	}
+
+	// Without a rate one reading, or n as fast as new data comes
+	var ticker *time.Ticker
+	if *rate > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / *rate))
+		defer ticker.Stop()
+	} else if *n == 0 {
+		*n = 1
+	}
+	for i := 0; *n == 0 || i < *n; i++ {
+		if i > 0 && ticker != nil {
+			select {
+			case <-a.ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		} else if a.ctx.Err() != nil {
+			return nil
+		}
+		r, err := sense()
+		if a.ctx.Err() != nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := write(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readingWriter returns a function writing readings to w in the given format.
+func readingWriter(w io.Writer, format string, raw bool) (func(reading) error, error) {
+	precision := 4
+	if raw {
+		precision = 0
+	}
+	value := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', precision, 64)
+	}
+
+	switch format {
+	case "text":
+		accelerationUnit, fieldUnit := "g", "µT"
+		if raw {
+			accelerationUnit, fieldUnit = "counts", "counts"
+		}
+		return func(r reading) error {
+			_, err := fmt.Fprintf(w, "%s acceleration x:%s y:%s z:%s %s  field x:%s y:%s z:%s %s\n",
+				r.Time.Format("15:04:05.000"),
+				value(r.Acceleration.X), value(r.Acceleration.Y), value(r.Acceleration.Z), accelerationUnit,
+				value(r.MagneticField.X), value(r.MagneticField.Y), value(r.MagneticField.Z), fieldUnit)
+			return err
+		}, nil
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write([]string{"time", "ax", "ay", "az", "mx", "my", "mz"}); err != nil {
+			return nil, err
+		}
+		return func(r reading) error {
+			writer.Write([]string{
+				r.Time.Format(time.RFC3339Nano),
+				value(r.Acceleration.X), value(r.Acceleration.Y), value(r.Acceleration.Z),
+				value(r.MagneticField.X), value(r.MagneticField.Y), value(r.MagneticField.Z),
+			})
+			// Flushed every line so streams can be piped
+			writer.Flush()
+			return writer.Error()
+		}, nil
+	case "json":
+		encoder := json.NewEncoder(w)
+		return func(r reading) error {
+			return encoder.Encode(r)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, use text, csv or json", format)
+}
+
+func (a *app) set(args []string) error {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return errUsage
+	}
+	for i := 0; i < len(args); i += 2 {
+		switch args[i] {
+		case "range", "mode", "gain", "rate":
+		default:
+			return errUsage
+		}
+		if err := a.change(args[i], args[i+1]); err != nil {
+			return err
+		}
+	}
+	return a.info(nil)
+}
+
+func (a *app) selfTest(args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	accelerometer, err := a.openAccelerometer()
+	if err != nil {
+		return err
+	}
+	magnetometer, err := a.openMagnetometer()
+	if err != nil {
+		return err
+	}
+
+	passed := true
+	for _, test := range []struct {
+		name string
+		run  func(context.Context) (lsm303.SelfTestReport, error)
+	}{
+		{"accelerometer", accelerometer.SelfTest},
+		{"magnetometer", magnetometer.SelfTest},
+	} {
+		report, err := test.run(a.ctx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", test.name, err)
+		}
+		fmt.Fprintf(a.stdout, "%-14s %s\n", test.name, report)
+		passed = passed && report.Passed
+	}
+	if !passed {
+		return errors.New("self-test failed")
+	}
+	return nil
+}
+
+func (a *app) calibrate(args []string) error {
+	flags := flag.NewFlagSet("calibrate", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	calibrateAccelerometer := flags.Bool("accelerometer", false, "")
+	calibrateMagnetometer := flags.Bool("magnetometer", false, "")
+	samples := flags.Int("samples", 50, "")
+	crossAxis := flags.Bool("cross-axis", false, "")
+	duration := flags.Duration("duration", 30*time.Second, "")
+	serial := flags.String("serial", "", "")
+	output := flags.String("o", "", "")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 || *samples < 1 {
+		return errUsage
+	}
+	if !*calibrateAccelerometer && !*calibrateMagnetometer {
+		*calibrateAccelerometer, *calibrateMagnetometer = true, true
+	}
+
+	var accelerometer *lsm303.Accelerometer
+	var magnetometer *lsm303.Magnetometer
+	var err error
+	if *calibrateAccelerometer {
+		if accelerometer, err = a.openAccelerometer(); err != nil {
+			return err
+		}
+		calibrator := lsm303.NewAccelerometerCalibrator(accelerometer)
+		input := bufio.NewReader(a.stdin)
+		err := calibrator.Calibrate(a.ctx, *samples, func(position lsm303.AccelerometerPosition) error {
+			fmt.Fprintf(a.stdout, "Rest the device with %s and press Enter ", position)
+			if _, err := input.ReadString('\n'); err != nil {
+				return fmt.Errorf("calibration aborted: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		calibration, err := calibrator.Solve(*crossAxis)
+		if err != nil {
+			return err
+		}
+		accelerometer.SetCalibration(&calibration)
+		fmt.Fprintf(a.stdout, "accelerometer bias %+.4f g\n", calibration.Bias)
+	}
+	if *calibrateMagnetometer {
+		if magnetometer, err = a.openMagnetometer(); err != nil {
+			return err
+		}
+		calibrator := lsm303.NewMagnetometerCalibrator(magnetometer)
+		fmt.Fprintf(a.stdout, "Rotate the device slowly in every direction for %s\n", *duration)
+		ctx, cancel := context.WithTimeout(a.ctx, *duration)
+		err := calibrator.Collect(ctx, 20*time.Millisecond)
+		cancel()
+		if err != nil {
+			return err
+		}
+		calibration, report, err := calibrator.Fit()
+		if err != nil {
+			return err
+		}
+		magnetometer.SetCalibration(&calibration)
+		fmt.Fprintf(a.stdout, "magnetometer %s\n", report)
+	}
+
+	profile, err := lsm303.NewCalibrationProfile(accelerometer, magnetometer)
+	if err != nil {
+		return err
+	}
+	profile.Serial = *serial
+	if *output == "" {
+		return profile.Encode(a.stdout)
+	}
+	if err := profile.Save(*output); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "saved %s\n", *output)
+	return nil
+}
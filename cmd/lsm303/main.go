@@ -0,0 +1,197 @@
+// Command lsm303 probes, configures and reads LSM303 sensors.
+//
+//	lsm303 [flags] <command> [arguments]
+//
+// Run it without a command for the list of commands and flags. With -simulate
+// it runs against the in-memory simulator instead of a real bus, which is handy
+// for trying it out without hardware.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+	"github.com/timoth-y/go-lsm303/simulator"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/host"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr, openBus)
+	switch {
+	case err == nil:
+	case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+		os.Exit(2)
+	default:
+		fmt.Fprintln(os.Stderr, "lsm303:", err)
+		os.Exit(1)
+	}
+}
+
+// errUsage is returned once the usage has been printed for a bad command line.
+var errUsage = errors.New("usage")
+
+// openBus opens a host I²C bus, or a simulated one when simulate names a
+// variant.
+func openBus(name string, simulate lsm303.SensorType) (i2c.BusCloser, error) {
+	if simulate != "" {
+		bus, err := simulator.New(simulate, simulator.Static(simulator.Level))
+		if err != nil {
+			return nil, err
+		}
+		return nopCloser{bus}, nil
+	}
+	if _, err := host.Init(); err != nil {
+		return nil, err
+	}
+	return i2creg.Open(name)
+}
+
+type nopCloser struct {
+	i2c.Bus
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+type command struct {
+	usage   string
+	summary string
+	run     func(a *app, args []string) error
+}
+
+var commands = map[string]command{
+	"detect": {
+		usage:   "detect",
+		summary: "print which LSM303 variant is on the bus",
+		run:     (*app).detect,
+	},
+	"info": {
+		usage:   "info",
+		summary: "print the configuration and temperature",
+		run:     (*app).info,
+	},
+	"dump": {
+		usage:   "dump [-json] [accelerometer|magnetometer]",
+		summary: "print the registers decoded into their bitfields",
+		run:     (*app).dump,
+	},
+	"read": {
+		usage:   "read [-rate Hz] [-n count] [-format text|csv|json] [-raw]",
+		summary: "print one reading, or stream them at a rate",
+		run:     (*app).read,
+	},
+	"set": {
+		usage:   "set <range|mode|gain|rate> <value> ...",
+		summary: "change the configuration, later commands keep it until the sensor is powered down",
+		run:     (*app).set,
+	},
+	"selftest": {
+		usage:   "selftest",
+		summary: "run the built-in self-test of both sensors",
+		run:     (*app).selfTest,
+	},
+	"calibrate": {
+		usage:   "calibrate [-accelerometer] [-magnetometer] [-o profile.json] ...",
+		summary: "calibrate interactively and save a calibration profile",
+		run:     (*app).calibrate,
+	},
+}
+
+// run parses the command line, opens the bus with open and runs the command.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer, open func(string, lsm303.SensorType) (i2c.BusCloser, error)) error {
+	a := &app{ctx: ctx, stdin: stdin, stdout: stdout}
+
+	flags := flag.NewFlagSet("lsm303", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	busName := flags.String("bus", "", "I²C bus to use, the first one by default")
+	flags.Func("type", "sensor variant (LSM303DLHC, LSM303AGR or LSM303C), detected by default", func(s string) error {
+		sensorType, err := parseSensorType(s)
+		a.sensorType = sensorType
+		return err
+	})
+	var simulate lsm303.SensorType
+	flags.Func("simulate", "use a simulated `variant` instead of a real bus", func(s string) (err error) {
+		simulate, err = parseSensorType(s)
+		return err
+	})
+	flags.StringVar(&a.profilePath, "profile", "", "calibration profile to apply to the readings")
+	flags.Func("range", "accelerometer range (2G, 4G, 8G or 16G)", func(s string) error {
+		return a.apply("range", s)
+	})
+	flags.Func("mode", "accelerometer mode (normal, high-resolution or low-power)", func(s string) error {
+		return a.apply("mode", s)
+	})
+	flags.Func("gain", "magnetometer gain in gauss (1.3 to 8.1), LSM303DLHC only", func(s string) error {
+		return a.apply("gain", s)
+	})
+	flags.Func("rate", "magnetometer data rate in Hz", func(s string) error {
+		return a.apply("rate", s)
+	})
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: lsm303 [flags] <command> [arguments]\n\ncommands:\n")
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(stderr, "  %-10s %s\n", name, commands[name].summary)
+		}
+		fmt.Fprintf(stderr, "\nflags:\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errUsage
+	}
+	name := flags.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "lsm303: unknown command %q\n", name)
+		flags.Usage()
+		return errUsage
+	}
+	if simulate != "" && a.sensorType == "" {
+		a.sensorType = simulate
+	}
+
+	bus, err := open(*busName, simulate)
+	if err != nil {
+		return err
+	}
+	defer bus.Close()
+	a.bus = bus
+
+	err = cmd.run(a, flags.Args()[1:])
+	if errors.Is(err, errUsage) {
+		fmt.Fprintf(stderr, "usage: lsm303 [flags] %s\n", cmd.usage)
+	}
+	return err
+}
+
+func parseSensorType(s string) (lsm303.SensorType, error) {
+	for _, sensorType := range []lsm303.SensorType{lsm303.LSM303DLHC, lsm303.LSM303AGR, lsm303.LSM303C} {
+		if strings.EqualFold(s, string(sensorType)) {
+			return sensorType, nil
+		}
+	}
+	return "", fmt.Errorf("unknown sensor type %q", s)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+	"github.com/timoth-y/go-lsm303/simulator"
+	"periph.io/x/periph/conn/i2c"
+)
+
+// lsm303Test runs the command line against bus and returns what it printed.
+func lsm303Test(t *testing.T, bus *simulator.Bus, stdin io.Reader, args ...string) (string, error) {
+	t.Helper()
+	open := func(string, lsm303.SensorType) (i2c.BusCloser, error) {
+		return nopCloser{bus}, nil
+	}
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), args, stdin, &stdout, &stderr, open)
+	return stdout.String() + stderr.String(), err
+}
+
+func newBus(t *testing.T, sensorType lsm303.SensorType) *simulator.Bus {
+	bus, err := simulator.New(sensorType, simulator.Static(simulator.Level))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bus
+}
+
+func TestDetect(t *testing.T) {
+	for _, sensorType := range []lsm303.SensorType{lsm303.LSM303DLHC, lsm303.LSM303AGR, lsm303.LSM303C} {
+		output, err := lsm303Test(t, newBus(t, sensorType), nil, "detect")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(output, string(sensorType)+" on ") {
+			t.Errorf("detected %q on a %s", output, sensorType)
+		}
+	}
+}
+
+func TestInfoAndSet(t *testing.T) {
+	bus := newBus(t, lsm303.LSM303DLHC)
+	output, err := lsm303Test(t, bus, nil, "-range", "8G", "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"LSM303DLHC", "mode normal, range 8G", "gain 4.0 gauss, rate 30 Hz", "temperature"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("info doesn't mention %q:\n%s", expected, output)
+		}
+	}
+
+	output, err = lsm303Test(t, bus, nil, "set", "mode", "low-power", "gain", "8.1", "rate", "75")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"mode low power", "gain 8.1 gauss, rate 75 Hz"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("set doesn't show %q:\n%s", expected, output)
+		}
+	}
+	// Opening the running sensor again keeps the settings
+	output, err = lsm303Test(t, bus, nil, "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"mode low power, range 8G", "gain 8.1 gauss, rate 75 Hz"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("info after set doesn't show %q:\n%s", expected, output)
+		}
+	}
+
+	// The AGR rates are its own
+	bus = newBus(t, lsm303.LSM303AGR)
+	if output, err = lsm303Test(t, bus, nil, "set", "rate", "50"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "gain fixed, rate 50 Hz") {
+		t.Errorf("set doesn't show the AGR rate:\n%s", output)
+	}
+	if _, err = lsm303Test(t, bus, nil, "set", "rate", "75"); err == nil {
+		t.Error("set a rate the AGR doesn't have")
+	}
+	if _, err = lsm303Test(t, bus, nil, "set", "gain", "4.0"); err == nil {
+		t.Error("set the gain on an AGR")
+	}
+}
+
+func TestDump(t *testing.T) {
+	bus := newBus(t, lsm303.LSM303AGR)
+	output, err := lsm303Test(t, bus, nil, "dump", "magnetometer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "WHO_AM_I_M       0x4F = 0x40") || strings.Contains(output, "accelerometer") {
+		t.Errorf("unexpected dump:\n%s", output)
+	}
+
+	output, err = lsm303Test(t, bus, nil, "dump", "-json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dumps []lsm303.RegisterDump
+	if err := json.Unmarshal([]byte(output), &dumps); err != nil {
+		t.Fatal(err)
+	}
+	if len(dumps) != 2 || dumps[0].Values["WHO_AM_I_A"] != 0x33 {
+		t.Errorf("unexpected dumps %+v", dumps)
+	}
+}
+
+func TestRead(t *testing.T) {
+	bus := newBus(t, lsm303.LSM303C)
+
+	output, err := lsm303Test(t, bus, nil, "read", "-format", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r reading
+	if err := json.Unmarshal([]byte(output), &r); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(r.Acceleration.Z-1) > 0.01 {
+		t.Errorf("read %+v lying level", r.Acceleration)
+	}
+
+	start := time.Now()
+	output, err = lsm303Test(t, bus, nil, "read", "-rate", "50", "-n", "5", "-format", "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("5 readings at 50 Hz took %s", elapsed)
+	}
+	records, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 6 || records[0][3] != "az" || !strings.HasPrefix(records[5][3], "1.00") {
+		t.Errorf("unexpected CSV %q", records)
+	}
+
+	if output, err = lsm303Test(t, bus, nil, "read", "-raw"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "counts") {
+		t.Errorf("unexpected raw reading %q", output)
+	}
+
+	if _, err = lsm303Test(t, bus, nil, "read", "-format", "xml"); err == nil {
+		t.Error("read in an unknown format")
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	output, err := lsm303Test(t, newBus(t, lsm303.LSM303C), nil, "-range", "2G", "selftest")
+	if err != nil {
+		t.Fatalf("%v:\n%s", err, output)
+	}
+	if strings.Count(output, "self-test passed") != 2 {
+		t.Errorf("unexpected self-test output:\n%s", output)
+	}
+}
+
+// positioner turns the simulated board into the next calibration position
+// every time Enter is pressed.
+type positioner struct {
+	bus       *simulator.Bus
+	positions []lsm303.Vector
+}
+
+func (p *positioner) Read(b []byte) (int, error) {
+	if len(p.positions) == 0 {
+		return 0, io.EOF
+	}
+	p.bus.SetSource(simulator.Static(simulator.Sample{Acceleration: p.positions[0], MagneticField: simulator.Level.MagneticField}))
+	p.positions = p.positions[1:]
+	// Long enough for a conversion at 100 Hz
+	time.Sleep(20 * time.Millisecond)
+	return copy(b, "\n"), nil
+}
+
+func TestCalibrate(t *testing.T) {
+	bus := newBus(t, lsm303.LSM303DLHC)
+	stdin := &positioner{bus: bus, positions: []lsm303.Vector{
+		{X: 1.02}, {X: -0.98}, {Y: 1.02}, {Y: -0.98}, {Z: 1.02}, {Z: -0.98},
+	}}
+	path := filepath.Join(t.TempDir(), "profile.json")
+	output, err := lsm303Test(t, bus, stdin, "calibrate", "-accelerometer", "-samples", "5", "-serial", "board-7", "-o", path)
+	if err != nil {
+		t.Fatalf("%v:\n%s", err, output)
+	}
+	if strings.Count(output, "Rest the device") != 6 {
+		t.Errorf("unexpected prompts:\n%s", output)
+	}
+
+	profile, err := lsm303.LoadCalibrationProfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Serial != "board-7" || profile.Accelerometer == nil || profile.Magnetometer != nil {
+		t.Fatalf("unexpected profile %+v", profile)
+	}
+	if bias := profile.Accelerometer.Bias; math.Abs(bias.X-0.02) > 0.01 || math.Abs(bias.Z-0.02) > 0.01 {
+		t.Errorf("bias is %+v, want 0.02 g", bias)
+	}
+
+	// Readings with the profile applied are corrected
+	output, err = lsm303Test(t, bus, nil, "-profile", path, "read", "-format", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r reading
+	if err := json.Unmarshal([]byte(output), &r); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(r.Acceleration.Z+1) > 0.01 {
+		t.Errorf("calibrated reading is %+v, want Z -1 g", r.Acceleration)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	bus := newBus(t, lsm303.LSM303DLHC)
+	for _, args := range [][]string{
+		{},
+		{"fly"},
+		{"detect", "now"},
+		{"set", "range"},
+		{"set", "speed", "10"},
+	} {
+		output, err := lsm303Test(t, bus, nil, args...)
+		if err != errUsage || !strings.Contains(output, "usage: lsm303") {
+			t.Errorf("%q: got %v, %q", args, err, output)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package lsm303
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+)
+
+// Compass combines the LSM303 accelerometer and magnetometer into a
+// tilt-compensated heading. The heading is that of the body frame, so mount
+// the sensors with WithAccelerometerAxisRemap and WithMagnetometerAxisRemap.
+type Compass struct {
+	accelerometer *Accelerometer
+	magnetometer  *Magnetometer
+
+	// Declination is either fixed by the user or computed from the magnetic
+	// model for the configured location, and then cached for a day.
+	declination *float64
+	location    *geoLocation
+	model       *MagneticModel
+	// Guards the cache, headings can be read from several goroutines.
+	mu                sync.Mutex
+	declinationDay    time.Time
+	cachedDeclination float64
+}
+
+type geoLocation struct {
+	latitude  float64
+	longitude float64
+	altitude  physic.Distance
+}
+
+// NewCompass creates a compass from already initialized sensor handles.
+func NewCompass(accelerometer *Accelerometer, magnetometer *Magnetometer, opts ...CompassOption) (*Compass, error) {
+	if accelerometer == nil || magnetometer == nil {
+		return nil, errors.New("compass requires both an accelerometer and a magnetometer")
+	}
+
+	compass := &Compass{
+		accelerometer: accelerometer,
+		magnetometer:  magnetometer,
+	}
+
+	for i := range opts {
+		opts[i].Apply(compass)
+	}
+
+	return compass, nil
+}
+
+// Heading returns the tilt-compensated magnetic heading in degrees, clockwise
+// from magnetic north in [0, 360).
+func (c *Compass) Heading() (float64, error) {
+	_, _, heading, err := c.Sense()
+	return heading, err
+}
+
+// TrueHeading returns the tilt-compensated heading in degrees, clockwise from
+// true north in [0, 360). The declination comes from WithDeclination or, when
+// WithLocation is used, from the World Magnetic Model.
+func (c *Compass) TrueHeading() (float64, error) {
+	declination, err := c.Declination(time.Now())
+	if err != nil {
+		return 0, err
+	}
+	heading, err := c.Heading()
+	if err != nil {
+		return 0, err
+	}
+	return math.Mod(heading+declination+360, 360), nil
+}
+
+// Declination returns the angle in degrees between true and magnetic north
+// (positive east) that the compass applies at the given time.
+func (c *Compass) Declination(at time.Time) (float64, error) {
+	if c.declination != nil {
+		return *c.declination, nil
+	}
+	if c.location == nil {
+		return 0, errors.New("compass has no declination or location configured")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	day := at.UTC().Truncate(24 * time.Hour)
+	if !day.Equal(c.declinationDay) {
+		model := c.model
+		if model == nil {
+			var err error
+			if model, err = MagneticModelFor(at); err != nil {
+				return 0, err
+			}
+		}
+		elements, err := model.Evaluate(c.location.latitude, c.location.longitude, c.location.altitude, at)
+		if err != nil {
+			return 0, err
+		}
+		c.cachedDeclination = elements.Declination
+		c.declinationDay = day
+	}
+
+	return c.cachedDeclination, nil
+}
+
+// Sense returns pitch, roll and the tilt-compensated heading, all in degrees.
+// Pitch is positive nose up, roll is positive right side down.
+func (c *Compass) Sense() (float64, float64, float64, error) {
+	xa, ya, za, err := c.accelerometer.Sense()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	xm, ym, zm, err := c.magnetometer.Sense()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	acceleration := Vector{forceToG(xa), forceToG(ya), forceToG(za)}
+	field := Vector{float64(xm), float64(ym), float64(zm)}
+	pitch, roll, heading := tiltCompensatedHeading(acceleration, field, AXIS_CONVENTION_NWU)
+
+	return degrees(pitch), degrees(roll), degrees(heading), nil
+}
+
+// Computes pitch, roll and heading (in radians) from a specific force and a
+// magnetic field vector, both expressed in the sensor frame described by axes.
+// The math follows Freescale AN4248 and works in the NED body frame.
+func tiltCompensatedHeading(acceleration, field Vector, axes AxisConvention) (float64, float64, float64) {
+	// The accelerometer measures the reaction to gravity, so flip it to get
+	// the direction of "down".
+	g := axes.ToNED(acceleration).Scale(-1)
+	m := axes.ToNED(field)
+
+	roll := math.Atan2(g.Y, g.Z)
+	pitch := math.Atan2(-g.X, math.Hypot(g.Y, g.Z))
+
+	sinRoll, cosRoll := math.Sincos(roll)
+	sinPitch, cosPitch := math.Sincos(pitch)
+
+	xh := m.X*cosPitch + m.Y*sinPitch*sinRoll + m.Z*sinPitch*cosRoll
+	yh := m.Z*sinRoll - m.Y*cosRoll
+
+	heading := math.Atan2(yh, xh)
+	if heading < 0 {
+		heading += 2 * math.Pi
+	}
+
+	return pitch, roll, heading
+}
+
+// ToNED converts a vector in the given sensor frame to the NED body frame
+// (x forward, y right, z down).
+func (axes AxisConvention) ToNED(v Vector) Vector {
+	switch axes {
+	case AXIS_CONVENTION_NED:
+		return v
+	case AXIS_CONVENTION_ENU:
+		return Vector{v.Y, v.X, -v.Z}
+	default:
+		return Vector{v.X, -v.Y, -v.Z}
+	}
+}
+
+// FromNED converts a vector in the NED body frame to the given sensor frame.
+func (axes AxisConvention) FromNED(v Vector) Vector {
+	// Each conversion swaps or negates axes, so it is its own inverse
+	return axes.ToNED(v)
+}
+
+func forceToG(f physic.Force) float64 {
+	return float64(f) / float64(physic.EarthGravity)
+}
+
+func gToForce(g float64) physic.Force {
+	return physic.Force(math.Round(g * float64(physic.EarthGravity)))
+}
+
+func degrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
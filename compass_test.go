@@ -0,0 +1,84 @@
+package lsm303
+
+import (
+	"math"
+	"testing"
+)
+
+// Builds the specific force and magnetic field an ideal sensor would read in
+// the NED body frame for the given attitude (in degrees).
+func syntheticNED(heading, pitch, roll float64) (Vector, Vector) {
+	const inclination = 60.0
+	north := Vector{math.Cos(radians(inclination)), 0, math.Sin(radians(inclination))}
+	up := Vector{0, 0, -1}
+
+	toBody := func(v Vector) Vector {
+		// Inverse of Rz(heading) * Ry(pitch) * Rx(roll)
+		sy, cy := math.Sincos(radians(heading))
+		sp, cp := math.Sincos(radians(pitch))
+		sr, cr := math.Sincos(radians(roll))
+		v = Vector{cy*v.X + sy*v.Y, -sy*v.X + cy*v.Y, v.Z}
+		v = Vector{cp*v.X - sp*v.Z, v.Y, sp*v.X + cp*v.Z}
+		return Vector{v.X, cr*v.Y + sr*v.Z, -sr*v.Y + cr*v.Z}
+	}
+
+	return toBody(up), toBody(north).Scale(500)
+}
+
+func angleDiff(a, b float64) float64 {
+	d := math.Mod(a-b+540, 360) - 180
+	return math.Abs(d)
+}
+
+func TestTiltCompensatedHeading(t *testing.T) {
+	axesList := [...]AxisConvention{AXIS_CONVENTION_NWU, AXIS_CONVENTION_NED, AXIS_CONVENTION_ENU}
+	headings := [...]float64{0, 45, 90, 135, 180, 225, 270, 315, 359}
+	tilts := [...][2]float64{{0, 0}, {20, 0}, {0, -30}, {-45, 25}, {70, 10}}
+
+	for _, axes := range axesList {
+		for _, heading := range headings {
+			for _, tilt := range tilts {
+				a, m := syntheticNED(heading, tilt[0], tilt[1])
+				pitch, roll, computed := tiltCompensatedHeading(axes.FromNED(a), axes.FromNED(m), axes)
+
+				if angleDiff(degrees(computed), heading) > 1e-6 {
+					t.Errorf("%s heading %v pitch %v roll %v: got heading %v", axes, heading, tilt[0], tilt[1], degrees(computed))
+				}
+				if math.Abs(degrees(pitch)-tilt[0]) > 1e-6 {
+					t.Errorf("%s heading %v: expected pitch %v, got %v", axes, heading, tilt[0], degrees(pitch))
+				}
+				if math.Abs(degrees(roll)-tilt[1]) > 1e-6 {
+					t.Errorf("%s heading %v: expected roll %v, got %v", axes, heading, tilt[1], degrees(roll))
+				}
+			}
+		}
+	}
+}
+
+func TestHeadingIgnoresFieldStrength(t *testing.T) {
+	a, m := syntheticNED(123, 10, -5)
+	_, _, weak := tiltCompensatedHeading(a, m.Scale(0.01), AXIS_CONVENTION_NED)
+	_, _, strong := tiltCompensatedHeading(a.Scale(3), m.Scale(10), AXIS_CONVENTION_NED)
+
+	if angleDiff(degrees(weak), degrees(strong)) > 1e-9 {
+		t.Fatalf("heading depends on magnitude: %v vs %v", degrees(weak), degrees(strong))
+	}
+}
+
+func TestNewCompassRequiresSensors(t *testing.T) {
+	if _, err := NewCompass(nil, &Magnetometer{}); err == nil {
+		t.Fatal("expected error for missing accelerometer")
+	}
+	if _, err := NewCompass(&Accelerometer{}, nil); err == nil {
+		t.Fatal("expected error for missing magnetometer")
+	}
+}
+
+func TestAxisConventionString(t *testing.T) {
+	if name := AXIS_CONVENTION_ENU.String(); name != "ENU" {
+		t.Errorf("ENU is %q", name)
+	}
+	if name := AxisConvention(3).String(); name != "AxisConvention(3)" {
+		t.Errorf("unknown convention is %q", name)
+	}
+}
@@ -0,0 +1,37 @@
+package lsm303
+
+import (
+	"fmt"
+
+	"periph.io/x/periph/conn/i2c"
+)
+
+// Detect finds out which LSM303 variant is on the bus by reading the
+// identification registers at the default addresses. It only reads, so it
+// doesn't disturb a sensor somebody else has configured.
+func Detect(bus i2c.Bus) (SensorType, error) {
+	// In the detection_order of the register descriptions. The LSM303C is
+	// checked first since its accelerometer has its own address, and the AGR
+	// before the DLHC because the DLHC identification register is only a
+	// constant that other chips may read back by chance.
+	for _, sensorType := range detectionOrder {
+		magnetometer := datasheetForMagnetometer(sensorType)
+		if !identify(bus, magnetometer.ADDRESS, magnetometer.WHO_AM_I_M, magnetometer.CHIP_ID) {
+			continue
+		}
+		accelerometer := datasheetForAccelerometer(sensorType)
+		if !identify(bus, accelerometer.ADDRESS, accelerometer.WHO_AM_I_A, accelerometer.CHIP_ID) {
+			continue
+		}
+		return sensorType, nil
+	}
+	return "", fmt.Errorf("no LSM303 found on %s", bus)
+}
+
+func identify(bus i2c.Bus, addr uint16, register, id uint8) bool {
+	value := []byte{0}
+	if err := bus.Tx(addr, []byte{register}, value); err != nil {
+		return false
+	}
+	return value[0] == id
+}
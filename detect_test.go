@@ -0,0 +1,68 @@
+package lsm303
+
+import (
+	"testing"
+
+	"periph.io/x/periph/conn/i2c/i2ctest"
+)
+
+func TestDetect(t *testing.T) {
+	data := []struct {
+		name     string
+		ops      []i2ctest.IO
+		expected SensorType
+	}{
+		{
+			name: "LSM303C",
+			ops: []i2ctest.IO{
+				{Addr: 0x1E, W: []byte{0x0F}, R: []byte{0x3D}},
+				{Addr: 0x1D, W: []byte{0x0F}, R: []byte{0x41}},
+			},
+			expected: LSM303C,
+		},
+		{
+			name: "LSM303AGR",
+			ops: []i2ctest.IO{
+				{Addr: 0x1E, W: []byte{0x0F}, R: []byte{0x00}},
+				{Addr: 0x1E, W: []byte{0x4F}, R: []byte{0x40}},
+				{Addr: 0x19, W: []byte{0x0F}, R: []byte{0x33}},
+			},
+			expected: LSM303AGR,
+		},
+		{
+			name: "LSM303DLHC",
+			ops: []i2ctest.IO{
+				{Addr: 0x1E, W: []byte{0x0F}, R: []byte{0x00}},
+				{Addr: 0x1E, W: []byte{0x4F}, R: []byte{0x00}},
+				{Addr: 0x1E, W: []byte{0x0A}, R: []byte{0x48}},
+				{Addr: 0x19, W: []byte{0x0F}, R: []byte{0x33}},
+			},
+			expected: LSM303DLHC,
+		},
+		{
+			// A magnetometer that answers like a DLHC, but no accelerometer
+			name: "none",
+			ops: []i2ctest.IO{
+				{Addr: 0x1E, W: []byte{0x0F}, R: []byte{0x00}},
+				{Addr: 0x1E, W: []byte{0x4F}, R: []byte{0x00}},
+				{Addr: 0x1E, W: []byte{0x0A}, R: []byte{0x48}},
+				{Addr: 0x19, W: []byte{0x0F}, R: []byte{0x00}},
+			},
+		},
+	}
+
+	for _, test := range data {
+		scenario := &i2ctest.Playback{Ops: test.ops}
+		sensorType, err := Detect(scenario)
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf("%s: detected %s", test.name, sensorType)
+			}
+		} else if err != nil || sensorType != test.expected {
+			t.Errorf("%s: detected %q, %v", test.name, sensorType, err)
+		}
+		if err := scenario.Close(); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package lsm303
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/physic"
+)
+
+// Device is both halves of an LSM303 on one bus, of the same variant, with one
+// lifetime.
+type Device struct {
+	mu            sync.Mutex
+	sensorType    SensorType
+	accelerometer *Accelerometer
+	magnetometer  *Magnetometer
+
+	// Options for the halves, given to NewDevice
+	accelerometerOptions []AccelerometerOption
+	magnetometerOptions  []MagnetometerOption
+}
+
+// DeviceSample is a reading of both sensors with one timestamp.
+type DeviceSample struct {
+	Time time.Time `json:"time"`
+	// In units of standard gravity
+	Acceleration Vector `json:"acceleration"`
+	// In gauss
+	MagneticField Vector `json:"magnetic_field"`
+}
+
+// NewDevice opens both sensors of an LSM303. Unless WithDeviceSensorType is
+// given the variant is detected, which only works at the default addresses.
+func NewDevice(bus i2c.Bus, opts ...DeviceOption) (*Device, error) {
+	device := &Device{}
+	for i := range opts {
+		opts[i].Apply(device)
+	}
+
+	if device.sensorType == "" {
+		sensorType, err := Detect(bus)
+		if err != nil {
+			return nil, err
+		}
+		device.sensorType = sensorType
+	}
+
+	// Last, so the halves can't end up with different variants
+	accelerometer, err := NewAccelerometer(bus, append(device.accelerometerOptions, WithAccelerometerSensorType(device.sensorType))...)
+	if err != nil {
+		return nil, fmt.Errorf("accelerometer: %w", err)
+	}
+	magnetometer, err := NewMagnetometer(bus, append(device.magnetometerOptions, WithMagnetometerSensorType(device.sensorType))...)
+	if err != nil {
+		accelerometer.Halt()
+		return nil, fmt.Errorf("magnetometer: %w", err)
+	}
+
+	device.accelerometer, device.magnetometer = accelerometer, magnetometer
+	device.accelerometerOptions, device.magnetometerOptions = nil, nil
+	return device, nil
+}
+
+// SensorType returns the variant of the device.
+func (d *Device) SensorType() SensorType {
+	return d.sensorType
+}
+
+// Accelerometer returns the accelerometer half, for its settings and the
+// types that build on it.
+func (d *Device) Accelerometer() *Accelerometer {
+	return d.accelerometer
+}
+
+// Magnetometer returns the magnetometer half.
+func (d *Device) Magnetometer() *Magnetometer {
+	return d.magnetometer
+}
+
+// SenseAll reads both sensors one after the other, calibrated like Sense. The
+// timestamp is halfway between the two reads, and calls from several
+// goroutines don't interleave.
+func (d *Device) SenseAll() (DeviceSample, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	started := time.Now()
+	acceleration, err := d.accelerometer.senseCalibratedVector()
+	if err != nil {
+		return DeviceSample{}, err
+	}
+	field, err := d.magnetometer.senseCalibratedField()
+	if err != nil {
+		return DeviceSample{}, err
+	}
+	return DeviceSample{
+		Time:          started.Add(time.Since(started) / 2),
+		Acceleration:  acceleration,
+		MagneticField: field,
+	}, nil
+}
+
+// SenseRelativeTemperature reads the temperature sensor next to the
+// magnetometer, see Magnetometer.SenseRelativeTemperature.
+func (d *Device) SenseRelativeTemperature() (physic.Temperature, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.magnetometer.SenseRelativeTemperature()
+}
+
+// Halt powers both sensors down. Reset brings them back.
+func (d *Device) Halt() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return errors.Join(d.accelerometer.Halt(), d.magnetometer.Halt())
+}
+
+// Reset reboots both sensors and re-applies their configuration.
+func (d *Device) Reset() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return errors.Join(d.accelerometer.Reset(), d.magnetometer.Reset())
+}
+
+// Close leaves both sensors rebooted and powered down. The bus is left open,
+// it belongs to the caller.
+func (d *Device) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return errors.Join(d.accelerometer.Close(), d.magnetometer.Close())
+}
+
+// String names the device and where it is, e.g.
+// "LSM303DLHC on I2C1 at 0x19 and 0x1E".
+func (d *Device) String() string {
+	accelerometer, okA := d.accelerometer.mmr.Conn.(*i2c.Dev)
+	magnetometer, okM := d.magnetometer.mmr.Conn.(*i2c.Dev)
+	if !okA || !okM || accelerometer.Bus == nil {
+		return string(d.sensorType)
+	}
+	return fmt.Sprintf("%s on %s at 0x%02X and 0x%02X", d.sensorType, accelerometer.Bus, accelerometer.Addr, magnetometer.Addr)
+}
@@ -0,0 +1,90 @@
+package lsm303_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+	"github.com/timoth-y/go-lsm303/simulator"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/physic"
+)
+
+func near(a, b lsm303.Vector) bool {
+	return math.Abs(a.X-b.X) < 0.01 && math.Abs(a.Y-b.Y) < 0.01 && math.Abs(a.Z-b.Z) < 0.01
+}
+
+func TestNewDevice(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	bus, err := simulator.New(lsm303.LSM303C, simulator.Static(simulator.Level), simulator.WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	device, err := lsm303.NewDevice(bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.SensorType() != lsm303.LSM303C || device.Accelerometer().SensorType() != lsm303.LSM303C || device.Magnetometer().SensorType() != lsm303.LSM303C {
+		t.Errorf("opened %s", device.SensorType())
+	}
+	now = now.Add(time.Second)
+
+	before := time.Now()
+	sample, err := device.SenseAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample.Time.Before(before) || sample.Time.After(time.Now()) {
+		t.Errorf("sample taken at %s", sample.Time)
+	}
+	if !near(sample.Acceleration, simulator.Level.Acceleration) || !near(sample.MagneticField, simulator.Level.MagneticField) {
+		t.Errorf("unexpected sample %+v", sample)
+	}
+	temperature, err := device.SenseRelativeTemperature()
+	if err != nil || temperature != physic.ZeroCelsius+20*physic.Celsius {
+		t.Errorf("temperature is %s, %v", temperature, err)
+	}
+
+	if err := device.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	// The accelerometer data rate cleared, the magnetometer in power-down
+	if ctrl1, _ := bus.Peek(0x1D, 0x20); ctrl1&0x70 != 0 {
+		t.Errorf("accelerometer CTRL_REG1 is %08b", ctrl1)
+	}
+	if ctrl3, _ := bus.Peek(0x1E, 0x22); ctrl3&0b11 != 0b11 {
+		t.Errorf("magnetometer CTRL_REG3 is %08b", ctrl3)
+	}
+}
+
+func TestNewDeviceOptions(t *testing.T) {
+	bus, err := simulator.New(lsm303.LSM303DLHC, simulator.Static(simulator.Level))
+	if err != nil {
+		t.Fatal(err)
+	}
+	device, err := lsm303.NewDevice(bus,
+		lsm303.WithDeviceSensorType(lsm303.LSM303DLHC),
+		// The device's sensor type wins
+		lsm303.WithAccelerometerOptions(lsm303.WithRange(lsm303.ACCELEROMETER_RANGE_16G), lsm303.WithAccelerometerSensorType(lsm303.LSM303C)),
+		lsm303.WithMagnetometerOptions(lsm303.WithGain(lsm303.MAGNETOMETER_GAIN_8_1)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if range_, err := device.Accelerometer().GetRange(); err != nil || range_ != lsm303.ACCELEROMETER_RANGE_16G {
+		t.Errorf("range is %s, %v", range_, err)
+	}
+	if gain, err := device.Magnetometer().GetGain(); err != nil || gain != lsm303.MAGNETOMETER_GAIN_8_1 {
+		t.Errorf("gain is %s, %v", gain, err)
+	}
+	if device.Accelerometer().SensorType() != lsm303.LSM303DLHC {
+		t.Errorf("accelerometer opened as %s", device.Accelerometer().SensorType())
+	}
+}
+
+func TestNewDeviceNotFound(t *testing.T) {
+	if _, err := lsm303.NewDevice(&i2ctest.Playback{DontPanic: true}); err == nil {
+		t.Error("opened a device on an empty bus")
+	}
+}
@@ -0,0 +1,122 @@
+// Package driver registers an LSM303 driver with periph, which looks for the
+// sensors on every I²C bus of the host when it is initialized. Import it for
+// that side effect, then open what was found:
+//
+//	import _ "github.com/timoth-y/go-lsm303/driver"
+//
+//	host.Init()
+//	for _, found := range driver.Devices() {
+//		device, bus, _ := found.Open()
+//		...
+//	}
+//
+// The buses are only read from, the identification registers at the default
+// addresses, so whatever else is on them isn't disturbed.
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+	"periph.io/x/periph"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2creg"
+)
+
+// Found is an LSM303 on one of the host's I²C buses.
+type Found struct {
+	// The name of the bus, as i2creg.Open takes it
+	Bus        string
+	SensorType lsm303.SensorType
+}
+
+func (f Found) String() string {
+	return fmt.Sprintf("%s on %s", f.SensorType, f.Bus)
+}
+
+// Open opens the bus and the device on it. The bus belongs to the caller,
+// close it after the device.
+func (f Found) Open(opts ...lsm303.DeviceOption) (*lsm303.Device, i2c.BusCloser, error) {
+	bus, err := i2creg.Open(f.Bus)
+	if err != nil {
+		return nil, nil, err
+	}
+	device, err := lsm303.NewDevice(bus, append(opts, lsm303.WithDeviceSensorType(f.SensorType))...)
+	if err != nil {
+		bus.Close()
+		return nil, nil, err
+	}
+	return device, bus, nil
+}
+
+// Enumerate looks for an LSM303 on every bus registered with i2creg. Buses
+// that can't be opened are skipped, and reported in the error.
+func Enumerate() ([]Found, error) {
+	var found []Found
+	var errs []error
+	for _, ref := range i2creg.All() {
+		bus, err := ref.Open()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ref.Name, err))
+			continue
+		}
+		if sensorType, err := lsm303.Detect(bus); err == nil {
+			found = append(found, Found{Bus: ref.Name, SensorType: sensorType})
+		}
+		bus.Close()
+	}
+	return found, errors.Join(errs...)
+}
+
+var (
+	mu    sync.Mutex
+	found []Found
+)
+
+// Devices returns what the driver found when periph was initialized, nothing
+// before host.Init or periph.Init.
+func Devices() []Found {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Found(nil), found...)
+}
+
+type lsm303Driver struct{}
+
+var _ periph.Driver = lsm303Driver{}
+
+func (lsm303Driver) String() string {
+	return "lsm303"
+}
+
+func (lsm303Driver) Prerequisites() []string {
+	return nil
+}
+
+// After the drivers that register the host's I²C buses.
+func (lsm303Driver) After() []string {
+	return []string{"sysfs-i2c", "ftdi"}
+}
+
+// Init enumerates the devices. Not finding any skips the driver rather than
+// failing it, most hosts don't have an LSM303.
+func (lsm303Driver) Init() (bool, error) {
+	devices, err := Enumerate()
+	mu.Lock()
+	found = devices
+	mu.Unlock()
+
+	if len(devices) == 0 {
+		if err != nil {
+			return false, fmt.Errorf("no LSM303 found: %w", err)
+		}
+		return false, errors.New("no LSM303 found")
+	}
+	return true, nil
+}
+
+func init() {
+	periph.MustRegister(lsm303Driver{})
+}
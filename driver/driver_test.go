@@ -0,0 +1,75 @@
+package driver
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+	"github.com/timoth-y/go-lsm303/simulator"
+	"periph.io/x/periph"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+)
+
+type simulatedBus struct {
+	*simulator.Bus
+}
+
+func (simulatedBus) Close() error {
+	return nil
+}
+
+func TestEnumerate(t *testing.T) {
+	bus, err := simulator.New(lsm303.LSM303C, simulator.Static(simulator.Level))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, opener := range map[string]i2creg.Opener{
+		"SIM1": func() (i2c.BusCloser, error) { return simulatedBus{bus}, nil },
+		"SIM2": func() (i2c.BusCloser, error) { return &i2ctest.Playback{DontPanic: true}, nil },
+		"SIM3": func() (i2c.BusCloser, error) { return nil, errors.New("permission denied") },
+	} {
+		if err := i2creg.Register(name, nil, -1, opener); err != nil {
+			t.Fatal(err)
+		}
+		name := name
+		t.Cleanup(func() { i2creg.Unregister(name) })
+	}
+
+	found, err := Enumerate()
+	if len(found) != 1 || found[0] != (Found{Bus: "SIM1", SensorType: lsm303.LSM303C}) {
+		t.Errorf("found %v", found)
+	}
+	if err == nil || !strings.Contains(err.Error(), "SIM3: permission denied") {
+		t.Errorf("enumerating: %v", err)
+	}
+
+	// The way host.Init would
+	state, err := periph.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Loaded) != 1 || state.Loaded[0].String() != "lsm303" {
+		t.Errorf("loaded %v, skipped %v, failed %v", state.Loaded, state.Skipped, state.Failed)
+	}
+	if devices := Devices(); len(devices) != 1 || devices[0].String() != "LSM303C on SIM1" {
+		t.Errorf("devices %v", devices)
+	}
+
+	device, closer, err := Devices()[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	if device.SensorType() != lsm303.LSM303C || device.String() != "LSM303C on LSM303C simulator at 0x1D and 0x1E" {
+		t.Errorf("opened %s", device)
+	}
+	if accelerometer := device.Accelerometer().String(); accelerometer != "LSM303C accelerometer on LSM303C simulator at 0x1D" {
+		t.Errorf("accelerometer is %s", accelerometer)
+	}
+	if err := device.Halt(); err != nil {
+		t.Error(err)
+	}
+}
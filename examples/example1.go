@@ -1,42 +1,61 @@
-package lsm303
+package main
 
 import (
 	"fmt"
 	"log"
 	"time"
-	lsm303 "github.com/bskari/go-lsm303"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+	"periph.io/x/periph/conn/i2c/i2creg"
+	"periph.io/x/periph/host"
 )
 
 func main() {
-	accelerometer, err := NewAccelerometer()
+	if _, err := host.Init(); err != nil {
+		log.Fatal(err)
+	}
+	bus, err := i2creg.Open("")
 	if err != nil {
-		log.Fatal("Couldn't connect to accelerometer")
+		log.Fatal(err)
 	}
+	defer bus.Close()
 
-	magnetometer, err != NewMagnetometer()
+	accelerometer, err := lsm303.NewAccelerometer(bus)
 	if err != nil {
-		log.Fatal("Couldn't connect to magnetometer")
+		log.Fatal("Couldn't connect to accelerometer: ", err)
 	}
 
+	magnetometer, err := lsm303.NewMagnetometer(bus)
+	if err != nil {
+		log.Fatal("Couldn't connect to magnetometer: ", err)
+	}
+
+	// Examples for setting options
+	/*
+		accelerometer.SetRange(lsm303.ACCELEROMETER_RANGE_16G)
+		accelerometer.SetMode(lsm303.ACCELEROMETER_MODE_LOW_POWER)
+		magnetometer.SetGain(lsm303.MAGNETOMETER_GAIN_5_6)
+		magnetometer.SetRate(lsm303.MAGNETOMETER_RATE_75)
+	*/
+
 	for {
-		xa, ya, za := accelerometer.Sense()
+		xa, ya, za, err := accelerometer.Sense()
+		if err != nil {
+			log.Fatal(err)
+		}
 		fmt.Printf("accel x:%v y:%v z:%v\n", xa, ya, za)
-		xa, ya, za = accelerometer.SenseRaw()
-		fmt.Printf("raw accel x:%v y:%v z:%v\n", xa, ya, za)
+		xr, yr, zr, err := accelerometer.SenseRaw()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("raw accel x:%v y:%v z:%v\n", xr, yr, zr)
 
-		// The periph.io has units defined for many things, but not for
-		// magnetometer flux, so we only have SenseRaw
-		xm, ym, zm := magnetometer.SenseRaw()
-		fmt.Printf("raw mag x:%v y:%v z:%v\n", xm, ym, zm)
+		xm, ym, zm, err := magnetometer.Sense()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("mag x:%v y:%v z:%v\n", xm, ym, zm)
 
 		time.Sleep(time.Second * 1)
 	}
-
-	// Examples for setting options
-	/*
-	accelerometer.SetRange(ACCELEROMETER_RANGE_16G)
-	accelerometer.SetMode(ACCELEROMETER_MODE_LOW_POWER)
-	magnetometer.SetGain(MAGNETOMETER_GAIN_5_6)
-	magnetometer.SetRate(MAGNETOMETER_RATE_75)
-	*/
 }
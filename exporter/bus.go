@@ -0,0 +1,107 @@
+package exporter
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/physic"
+)
+
+// The upper bounds of the latency histogram buckets, in seconds. A register
+// read at 400kHz takes around 100µs, a slow or clock stretched bus a few ms.
+var latencyBuckets = []float64{0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.1}
+
+// Bus is an i2c.Bus that passes every transaction on to another bus and
+// measures how long it took and whether it failed, per device address. Pass
+// it to NewAccelerometer and NewMagnetometer instead of the real bus, and to
+// the exporter with WithBus.
+type Bus struct {
+	mu        sync.Mutex
+	bus       i2c.Bus
+	now       func() time.Time
+	addresses map[uint16]*addressStats
+}
+
+type addressStats struct {
+	// Not cumulative, one per bucket and the last one for +Inf
+	buckets []uint64
+	sum     float64
+	count   uint64
+	errors  uint64
+}
+
+// Instrument wraps bus to measure its transactions.
+func Instrument(bus i2c.Bus) *Bus {
+	return &Bus{
+		bus:       bus,
+		now:       time.Now,
+		addresses: map[uint16]*addressStats{},
+	}
+}
+
+func (b *Bus) String() string {
+	return fmt.Sprintf("instrumented %s", b.bus)
+}
+
+// Tx runs the transaction on the wrapped bus and records it.
+func (b *Bus) Tx(addr uint16, w, r []byte) error {
+	started := b.now()
+	err := b.bus.Tx(addr, w, r)
+	seconds := b.now().Sub(started).Seconds()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats, ok := b.addresses[addr]
+	if !ok {
+		stats = &addressStats{buckets: make([]uint64, len(latencyBuckets)+1)}
+		b.addresses[addr] = stats
+	}
+	stats.buckets[sort.SearchFloat64s(latencyBuckets, seconds)]++
+	stats.sum += seconds
+	stats.count++
+	if err != nil {
+		stats.errors++
+	}
+	return err
+}
+
+// SetSpeed is passed on to the wrapped bus.
+func (b *Bus) SetSpeed(f physic.Frequency) error {
+	return b.bus.SetSpeed(f)
+}
+
+func (b *Bus) write(m *metricWriter) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	addresses := make([]uint16, 0, len(b.addresses))
+	for addr := range b.addresses {
+		addresses = append(addresses, addr)
+	}
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i] < addresses[j] })
+
+	m.family("lsm303_i2c_transaction_duration_seconds", "histogram", "Time taken by I²C transactions, per device address.")
+	for _, addr := range addresses {
+		stats := b.addresses[addr]
+		address := fmt.Sprintf("0x%02X", addr)
+		var cumulative uint64
+		for i, count := range stats.buckets {
+			cumulative += count
+			le := "+Inf"
+			if i < len(latencyBuckets) {
+				le = formatFloat(latencyBuckets[i])
+			}
+			m.sample("lsm303_i2c_transaction_duration_seconds_bucket", float64(cumulative), "address", address, "le", le)
+		}
+		m.sample("lsm303_i2c_transaction_duration_seconds_sum", stats.sum, "address", address)
+		m.sample("lsm303_i2c_transaction_duration_seconds_count", float64(stats.count), "address", address)
+	}
+
+	m.family("lsm303_i2c_errors_total", "counter", "Failed I²C transactions, per device address.")
+	for _, addr := range addresses {
+		m.sample("lsm303_i2c_errors_total", float64(b.addresses[addr].errors), "address", fmt.Sprintf("0x%02X", addr))
+	}
+}
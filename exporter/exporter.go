@@ -0,0 +1,209 @@
+// Package exporter serves the readings of an LSM303 as Prometheus metrics.
+//
+// The sensors are read on every scrape, so the gauges are as fresh as the
+// scrape interval. Reads that fail are counted and their gauges left out of
+// that scrape, rather than reporting a stale value.
+//
+//	bus := exporter.Instrument(i2cBus)
+//	accelerometer, _ := lsm303.NewAccelerometer(bus)
+//	magnetometer, _ := lsm303.NewMagnetometer(bus)
+//	handler, _ := exporter.New(accelerometer, magnetometer, exporter.WithBus(bus))
+//	http.Handle("/metrics", handler)
+package exporter
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"sync"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+	"periph.io/x/periph/conn/physic"
+)
+
+// What is read on a scrape, as the sensor label of the error and overrun
+// counters.
+const (
+	accelerometerSensor = "accelerometer"
+	magnetometerSensor  = "magnetometer"
+	temperatureSensor   = "temperature"
+	headingSensor       = "heading"
+)
+
+// Exporter is an http.Handler writing the metrics in the Prometheus text
+// exposition format. Scrapes are serialized, the sensors aren't read
+// concurrently.
+type Exporter struct {
+	mu            sync.Mutex
+	accelerometer *lsm303.Accelerometer
+	magnetometer  *lsm303.Magnetometer
+	compass       *lsm303.Compass
+	bus           *Bus
+	labels        []string
+
+	// The sensor labels of what is read on a scrape
+	read       []string
+	readErrors map[string]uint64
+	overruns   map[string]uint64
+}
+
+// New creates an exporter for the given sensors, either can be nil. When both
+// are given the heading is exported too, from a default compass unless
+// WithCompass is used.
+func New(accelerometer *lsm303.Accelerometer, magnetometer *lsm303.Magnetometer, opts ...Option) (*Exporter, error) {
+	if accelerometer == nil && magnetometer == nil {
+		return nil, errors.New("no sensor to export")
+	}
+	e := &Exporter{
+		accelerometer: accelerometer,
+		magnetometer:  magnetometer,
+		readErrors:    map[string]uint64{},
+		overruns:      map[string]uint64{},
+	}
+	for i := range opts {
+		opts[i].Apply(e)
+	}
+
+	if e.compass == nil && accelerometer != nil && magnetometer != nil {
+		compass, err := lsm303.NewCompass(accelerometer, magnetometer)
+		if err != nil {
+			return nil, err
+		}
+		e.compass = compass
+	}
+
+	if accelerometer != nil {
+		e.read = append(e.read, accelerometerSensor)
+	}
+	if magnetometer != nil {
+		e.read = append(e.read, magnetometerSensor)
+		if magnetometer.SensorType() != lsm303.LSM303AGR {
+			e.read = append(e.read, temperatureSensor)
+		}
+	}
+	if e.compass != nil {
+		e.read = append(e.read, headingSensor)
+	}
+	return e, nil
+}
+
+// ServeHTTP reads the sensors and writes the metrics.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	w.Header().Set("Content-Type", contentType)
+	m := &metricWriter{w: bufio.NewWriter(w), labels: e.labels}
+	defer m.w.Flush()
+
+	if e.accelerometer != nil {
+		e.checkOverrun(accelerometerSensor, e.accelerometer.Status)
+		m.family("lsm303_acceleration_g", "gauge", "Acceleration in units of standard gravity.")
+		if sample, err := e.accelerometer.SenseSample(); e.count(accelerometerSensor, err) {
+			writeVector(m, "lsm303_acceleration_g", sample.Acceleration)
+		}
+	}
+
+	if e.magnetometer != nil {
+		e.checkOverrun(magnetometerSensor, e.magnetometer.Status)
+		m.family("lsm303_magnetic_field_gauss", "gauge", "Magnetic field in gauss.")
+		if sample, err := e.magnetometer.SenseSample(); e.count(magnetometerSensor, err) {
+			writeVector(m, "lsm303_magnetic_field_gauss", sample.MagneticField)
+		}
+
+		// The driver doesn't read the AGR temperature sensor
+		if e.magnetometer.SensorType() != lsm303.LSM303AGR {
+			m.family("lsm303_relative_temperature_celsius", "gauge", "Uncalibrated die temperature, about 20°C below the actual one.")
+			if temperature, err := e.magnetometer.SenseRelativeTemperature(); e.count(temperatureSensor, err) {
+				m.sample("lsm303_relative_temperature_celsius", float64(temperature-physic.ZeroCelsius)/float64(physic.Celsius))
+			}
+		}
+	}
+
+	if e.compass != nil {
+		m.family("lsm303_heading_degrees", "gauge", "Tilt-compensated magnetic heading, clockwise from magnetic north.")
+		if heading, err := e.compass.Heading(); e.count(headingSensor, err) {
+			m.sample("lsm303_heading_degrees", heading)
+		}
+	}
+
+	// All of the counters are written, so they start at zero
+	m.family("lsm303_read_errors_total", "counter", "Failed sensor reads.")
+	for _, sensor := range e.read {
+		m.sample("lsm303_read_errors_total", float64(e.readErrors[sensor]), "sensor", sensor)
+	}
+	m.family("lsm303_overruns_total", "counter", "Scrapes that found samples overwritten before they were read.")
+	for _, sensor := range e.read {
+		if sensor == accelerometerSensor || sensor == magnetometerSensor {
+			m.sample("lsm303_overruns_total", float64(e.overruns[sensor]), "sensor", sensor)
+		}
+	}
+
+	if e.bus != nil {
+		e.bus.write(m)
+	}
+}
+
+// count counts a failed read, and returns whether the read succeeded.
+func (e *Exporter) count(sensor string, err error) bool {
+	if err != nil {
+		e.readErrors[sensor]++
+		return false
+	}
+	return true
+}
+
+func (e *Exporter) checkOverrun(sensor string, status func() (lsm303.DataStatus, error)) {
+	s, err := status()
+	if !e.count(sensor, err) {
+		return
+	}
+	if s.Overrun {
+		e.overruns[sensor]++
+	}
+}
+
+func writeVector(m *metricWriter, name string, v lsm303.Vector) {
+	m.sample(name, v.X, "axis", "x")
+	m.sample(name, v.Y, "axis", "y")
+	m.sample(name, v.Z, "axis", "z")
+}
+
+type (
+	// Option configures an Exporter.
+	Option interface {
+		Apply(*Exporter)
+	}
+	// OptionFunc is a function that configures an exporter.
+	OptionFunc func(*Exporter)
+)
+
+// Apply calls OptionFunc on exporter instance
+func (f OptionFunc) Apply(e *Exporter) {
+	f(e)
+}
+
+// WithCompass exports the heading of a compass the program already has,
+// instead of one built over the exporter's sensors. The exported heading is
+// magnetic, the compass's declination doesn't change it.
+func WithCompass(compass *lsm303.Compass) Option {
+	return OptionFunc(func(e *Exporter) {
+		e.compass = compass
+	})
+}
+
+// WithBus exports the latency and errors of the transactions on bus, which
+// should be the one the sensors were created with.
+func WithBus(bus *Bus) Option {
+	return OptionFunc(func(e *Exporter) {
+		e.bus = bus
+	})
+}
+
+// WithLabel adds a label to every metric, to tell devices apart when they
+// aren't scraped as separate targets.
+func WithLabel(name, value string) Option {
+	return OptionFunc(func(e *Exporter) {
+		e.labels = append(e.labels, name, value)
+	})
+}
@@ -0,0 +1,211 @@
+package exporter
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+	"github.com/timoth-y/go-lsm303/simulator"
+	"periph.io/x/periph/conn/i2c"
+)
+
+// failingBus fails every transaction once broken is set.
+type failingBus struct {
+	i2c.Bus
+	broken bool
+}
+
+func (b *failingBus) Tx(addr uint16, w, r []byte) error {
+	if b.broken {
+		return errors.New("nack")
+	}
+	return b.Bus.Tx(addr, w, r)
+}
+
+type setup struct {
+	simulator *simulator.Bus
+	bus       *failingBus
+	now       time.Time
+	exporter  *Exporter
+}
+
+func newSetup(t *testing.T, sensorType lsm303.SensorType, opts ...Option) *setup {
+	s := &setup{now: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+	var err error
+	s.simulator, err = simulator.New(sensorType, simulator.Static(simulator.Level), simulator.WithClock(func() time.Time { return s.now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.bus = &failingBus{Bus: s.simulator}
+	bus := Instrument(s.bus)
+
+	accelerometer, err := lsm303.NewAccelerometer(bus, lsm303.WithAccelerometerSensorType(sensorType))
+	if err != nil {
+		t.Fatal(err)
+	}
+	magnetometer, err := lsm303.NewMagnetometer(bus, lsm303.WithMagnetometerSensorType(sensorType))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Let the first samples be converted
+	s.now = s.now.Add(time.Second)
+
+	s.exporter, err = New(accelerometer, magnetometer, append(opts, WithBus(bus))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// scrape returns the samples by name and labels, as written.
+func scrape(t *testing.T, handler http.Handler) map[string]float64 {
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type is %q", contentType)
+	}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := map[string]float64{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(body), "\n"), "\n") {
+		if strings.HasPrefix(line, "# ") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if i < 0 || err != nil {
+			t.Fatalf("malformed line %q", line)
+		}
+		samples[line[:i]] = value
+	}
+	return samples
+}
+
+func TestExporter(t *testing.T) {
+	s := newSetup(t, lsm303.LSM303DLHC, WithLabel("device", `gateway "7"`))
+	samples := scrape(t, s.exporter)
+
+	for name, expected := range map[string]float64{
+		`lsm303_acceleration_g{device="gateway \"7\"",axis="x"}`:       simulator.Level.Acceleration.X,
+		`lsm303_acceleration_g{device="gateway \"7\"",axis="z"}`:       simulator.Level.Acceleration.Z,
+		`lsm303_magnetic_field_gauss{device="gateway \"7\"",axis="x"}`: simulator.Level.MagneticField.X,
+		`lsm303_magnetic_field_gauss{device="gateway \"7\"",axis="z"}`: simulator.Level.MagneticField.Z,
+		`lsm303_heading_degrees{device="gateway \"7\""}`:               0,
+	} {
+		value, ok := samples[name]
+		// Wrapped to ±180 for the heading, which is either side of north
+		if !ok || math.Abs(math.Mod(value+180, 360)-180-expected) > 0.01 {
+			t.Errorf("%s is %v, want %v", name, value, expected)
+		}
+	}
+	if _, ok := samples[`lsm303_relative_temperature_celsius{device="gateway \"7\""}`]; !ok {
+		t.Error("no temperature")
+	}
+	for _, sensor := range []string{"accelerometer", "magnetometer", "temperature", "heading"} {
+		if value, ok := samples[`lsm303_read_errors_total{device="gateway \"7\"",sensor="`+sensor+`"}`]; !ok || value != 0 {
+			t.Errorf("%s read errors are %v", sensor, value)
+		}
+	}
+
+	// Transactions on the simulator take next to no time, well below 100ms even
+	// under the race detector
+	count := samples[`lsm303_i2c_transaction_duration_seconds_count{device="gateway \"7\"",address="0x19"}`]
+	if count == 0 || samples[`lsm303_i2c_transaction_duration_seconds_bucket{device="gateway \"7\"",address="0x19",le="0.1"}`] != count ||
+		samples[`lsm303_i2c_transaction_duration_seconds_bucket{device="gateway \"7\"",address="0x19",le="+Inf"}`] != count {
+		t.Errorf("unexpected histogram %v", samples)
+	}
+	if _, ok := samples[`lsm303_i2c_errors_total{device="gateway \"7\"",address="0x1E"}`]; !ok {
+		t.Error("no I²C errors for the magnetometer")
+	}
+}
+
+func TestExporterWithoutTemperature(t *testing.T) {
+	s := newSetup(t, lsm303.LSM303AGR)
+	samples := scrape(t, s.exporter)
+	for name := range samples {
+		if strings.Contains(name, "temperature") {
+			t.Errorf("exported %s", name)
+		}
+	}
+	if _, ok := samples[`lsm303_overruns_total{sensor="magnetometer"}`]; !ok {
+		t.Error("no magnetometer overruns")
+	}
+}
+
+func TestExporterCountsErrors(t *testing.T) {
+	s := newSetup(t, lsm303.LSM303C)
+	scrape(t, s.exporter)
+
+	s.bus.broken = true
+	samples := scrape(t, s.exporter)
+	for name := range samples {
+		if strings.HasPrefix(name, "lsm303_acceleration_g") || strings.HasPrefix(name, "lsm303_heading_degrees") {
+			t.Errorf("exported %s without reading it", name)
+		}
+	}
+	// The status and the sample of each sensor
+	for name, expected := range map[string]float64{
+		`lsm303_read_errors_total{sensor="accelerometer"}`: 2,
+		`lsm303_read_errors_total{sensor="magnetometer"}`:  2,
+		`lsm303_read_errors_total{sensor="temperature"}`:   1,
+		`lsm303_read_errors_total{sensor="heading"}`:       1,
+	} {
+		if samples[name] != expected {
+			t.Errorf("%s is %v, want %v", name, samples[name], expected)
+		}
+	}
+	if samples[`lsm303_i2c_errors_total{address="0x1D"}`] == 0 || samples[`lsm303_i2c_errors_total{address="0x1E"}`] == 0 {
+		t.Errorf("I²C errors weren't counted: %v", samples)
+	}
+}
+
+func TestExporterCountsOverruns(t *testing.T) {
+	s := newSetup(t, lsm303.LSM303DLHC)
+	overruns := func() float64 {
+		return scrape(t, s.exporter)[`lsm303_overruns_total{sensor="accelerometer"}`]
+	}
+	if value := overruns(); value != 0 {
+		t.Errorf("%v overruns reading every sample", value)
+	}
+
+	// A sample converted and another one over it before the next scrape
+	s.now = s.now.Add(time.Second)
+	s.simulator.Peek(0x19, 0x27)
+	s.now = s.now.Add(time.Second)
+	if value := overruns(); value != 1 {
+		t.Errorf("%v overruns after missing a sample", value)
+	}
+	if value := overruns(); value != 1 {
+		t.Errorf("%v overruns without a new sample", value)
+	}
+
+	// The DLHC magnetometer has no overrun flag
+	s.now = s.now.Add(time.Second)
+	s.simulator.Peek(0x1E, 0x09)
+	s.now = s.now.Add(time.Second)
+	if value := scrape(t, s.exporter)[`lsm303_overruns_total{sensor="magnetometer"}`]; value != 0 {
+		t.Errorf("%v magnetometer overruns", value)
+	}
+}
+
+func TestNewWithoutSensors(t *testing.T) {
+	if _, err := New(nil, nil); err == nil {
+		t.Error("created an exporter without sensors")
+	}
+}
@@ -0,0 +1,55 @@
+package exporter
+
+import (
+	"bufio"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The version of the text exposition format that is written.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricWriter writes the Prometheus text exposition format. The labels set
+// with WithLabel are added to every sample.
+type metricWriter struct {
+	w      *bufio.Writer
+	labels []string
+}
+
+func (m *metricWriter) family(name, kind, help string) {
+	m.w.WriteString("# HELP " + name + " " + help + "\n")
+	m.w.WriteString("# TYPE " + name + " " + kind + "\n")
+}
+
+// sample writes one line, labels are name and value pairs.
+func (m *metricWriter) sample(name string, value float64, labels ...string) {
+	m.w.WriteString(name)
+	labels = append(m.labels[:len(m.labels):len(m.labels)], labels...)
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i == 0 {
+			m.w.WriteByte('{')
+		} else {
+			m.w.WriteByte(',')
+		}
+		m.w.WriteString(labels[i] + `="` + labelEscaper.Replace(labels[i+1]) + `"`)
+	}
+	if len(labels) > 0 {
+		m.w.WriteByte('}')
+	}
+	m.w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
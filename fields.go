@@ -0,0 +1,198 @@
+package lsm303
+
+import (
+	"fmt"
+	"math/bits"
+
+	"periph.io/x/periph/conn/mmr"
+)
+
+// Field is a bitfield at a fixed register address: Width bits starting at bit
+// Shift.
+type Field struct {
+	Name     string
+	Register uint8
+	Shift    uint8
+	Width    uint8
+	// Set to trigger an action (reboot, reset), cleared by the chip when
+	// done. These are never written back by RestoreRegisters.
+	SelfClearing bool
+}
+
+// Mask returns the bits of the register the field occupies.
+func (f Field) Mask() uint8 {
+	return (1<<f.Width - 1) << f.Shift
+}
+
+// Get extracts the field from a register value.
+func (f Field) Get(value uint8) uint8 {
+	return value & f.Mask() >> f.Shift
+}
+
+// Set returns the register value with the field replaced by x.
+func (f Field) Set(value, x uint8) uint8 {
+	return value&^f.Mask() | x<<f.Shift&f.Mask()
+}
+
+// Field looks up a bitfield of the register by name.
+func (r Register) Field(name string) (Field, bool) {
+	for _, field := range r.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+func readField(dev *mmr.Dev8, field Field) (uint8, error) {
+	value, err := dev.ReadUint8(field.Register)
+	if err != nil {
+		return 0, err
+	}
+	return field.Get(value), nil
+}
+
+// Read-modify-writes the register, leaving the other fields alone.
+func writeField(dev *mmr.Dev8, field Field, x uint8) error {
+	value, err := dev.ReadUint8(field.Register)
+	if err != nil {
+		return err
+	}
+	return dev.WriteUint8(field.Register, field.Set(value, x))
+}
+
+// The accelerometer fields the driver uses. A zero Width means the variant
+// doesn't have the field.
+type accelerometerFields struct {
+	dataRate        Field
+	lowPower        Field
+	highResolution  Field
+	fullScale       Field
+	blockDataUpdate Field
+	axisEnable      [3]Field
+	selfTest        Field
+	boot            Field
+	dataReady       Field
+	overrun         Field
+}
+
+// The magnetometer fields the driver uses. A zero Width means the variant
+// doesn't have the field.
+type magnetometerFields struct {
+	dataRate    Field
+	temperature Field
+	gain        Field
+	mode        Field
+	selfTest    Field
+	reboot      Field
+	softReset   Field
+	dataReady   Field
+	overrun     Field
+}
+
+// A value to give a field, see writeFields.
+type fieldValue struct {
+	field Field
+	value uint8
+}
+
+// A whole register value, as saved by writeFields.
+type registerValue struct {
+	register uint8
+	value    uint8
+}
+
+// Sets several fields with a read and a write per register, and returns the
+// registers as they were so writeRegisterValues can put them back. Fields the
+// variant doesn't have are skipped.
+func writeFields(dev *mmr.Dev8, values []fieldValue) ([]registerValue, error) {
+	var original, updated []registerValue
+	for _, v := range values {
+		if v.field.Width == 0 {
+			continue
+		}
+		i := 0
+		for i < len(updated) && updated[i].register != v.field.Register {
+			i++
+		}
+		if i == len(updated) {
+			value, err := dev.ReadUint8(v.field.Register)
+			if err != nil {
+				return nil, err
+			}
+			original = append(original, registerValue{v.field.Register, value})
+			updated = append(updated, registerValue{v.field.Register, value})
+		}
+		updated[i].value = v.field.Set(updated[i].value, v.value)
+	}
+	return original, writeRegisterValues(dev, updated)
+}
+
+func writeRegisterValues(dev *mmr.Dev8, values []registerValue) error {
+	for _, v := range values {
+		if err := dev.WriteUint8(v.register, v.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Moves fields from the address a register has in the variant's datasheet to
+// the one the handle's datasheet gives it, see WithDatasheet.
+type relocation map[uint8]uint8
+
+func (r relocation) add(from, to uint8) {
+	// Registers a variant doesn't have are left at zero, keep the first
+	if _, ok := r[from]; !ok && from != to {
+		r[from] = to
+	}
+}
+
+func (r relocation) apply(fields ...*Field) {
+	for _, field := range fields {
+		if to, ok := r[field.Register]; ok && field.Width != 0 {
+			field.Register = to
+		}
+	}
+}
+
+func (a *Accelerometer) fields() accelerometerFields {
+	fields := accelerometerDriverFor(a.sensorType).fields
+	defaults := datasheetForAccelerometer(a.sensorType)
+	if a.datasheet == nil || *a.datasheet == *defaults {
+		return fields
+	}
+	moved := relocation{}
+	moved.add(defaults.CTRL_REG1_A, a.datasheet.CTRL_REG1_A)
+	moved.add(defaults.CTRL_REG4_A, a.datasheet.CTRL_REG4_A)
+	moved.add(defaults.STATUS_REG_A, a.datasheet.STATUS_REG_A)
+	moved.apply(&fields.dataRate, &fields.lowPower, &fields.highResolution, &fields.fullScale,
+		&fields.blockDataUpdate, &fields.axisEnable[0], &fields.axisEnable[1], &fields.axisEnable[2],
+		&fields.selfTest, &fields.boot, &fields.dataReady, &fields.overrun)
+	return fields
+}
+
+func (m *Magnetometer) fields() magnetometerFields {
+	fields := magnetometerDriverFor(m.sensorType).fields
+	defaults := datasheetForMagnetometer(m.sensorType)
+	if m.datasheet == nil || *m.datasheet == *defaults {
+		return fields
+	}
+	moved := relocation{}
+	moved.add(defaults.CRA_REG_M, m.datasheet.CRA_REG_M)
+	moved.add(defaults.CRB_REG_M, m.datasheet.CRB_REG_M)
+	moved.add(defaults.MR_REG_M, m.datasheet.MR_REG_M)
+	moved.add(defaults.SR_REG_M, m.datasheet.SR_REG_M)
+	moved.apply(&fields.dataRate, &fields.temperature, &fields.gain, &fields.mode,
+		&fields.selfTest, &fields.reboot, &fields.softReset, &fields.dataReady, &fields.overrun)
+	// DRDY_M is a mask with the data ready bit set
+	if drdy := m.datasheet.DRDY_M; drdy != defaults.DRDY_M && drdy != 0 {
+		fields.dataReady.Shift = uint8(bits.TrailingZeros8(drdy))
+		fields.dataReady.Width = 1
+	}
+	return fields
+}
+
+func unsupported(sensorType SensorType, what string) error {
+	return fmt.Errorf("%s has no %s setting", sensorType, what)
+}
@@ -0,0 +1,302 @@
+package lsm303
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/mmr"
+)
+
+func TestField(t *testing.T) {
+	field := Field{Register: 0x20, Shift: 4, Width: 3}
+
+	if mask := field.Mask(); mask != 0b01110000 {
+		t.Errorf("mask is %08b, want 01110000", mask)
+	}
+	if value := field.Get(0b11011111); value != 0b101 {
+		t.Errorf("got %03b, want 101", value)
+	}
+	if value := field.Set(0b10001111, 0b011); value != 0b10111111 {
+		t.Errorf("set to %08b, want 10111111", value)
+	}
+	// Values too wide for the field don't leak into its neighbours
+	if value := field.Set(0, 0xFF); value != 0b01110000 {
+		t.Errorf("set to %08b, want 01110000", value)
+	}
+}
+
+// Every field the driver uses must be in the driver description of the
+// variant, the generator only checks the names that are there.
+func TestFieldsExist(t *testing.T) {
+	for _, sensorType := range []SensorType{LSM303DLHC, LSM303AGR, LSM303C} {
+		accelerometer := accelerometerDriverFor(sensorType).fields
+		for name, field := range map[string]Field{
+			"dataRate":        accelerometer.dataRate,
+			"highResolution":  accelerometer.highResolution,
+			"fullScale":       accelerometer.fullScale,
+			"blockDataUpdate": accelerometer.blockDataUpdate,
+			"xEnable":         accelerometer.axisEnable[0],
+			"yEnable":         accelerometer.axisEnable[1],
+			"zEnable":         accelerometer.axisEnable[2],
+			"selfTest":        accelerometer.selfTest,
+			"boot":            accelerometer.boot,
+			"dataReady":       accelerometer.dataReady,
+			"overrun":         accelerometer.overrun,
+		} {
+			if field.Width == 0 {
+				t.Errorf("%s accelerometer has no %s field", sensorType, name)
+			}
+		}
+		if (accelerometer.lowPower.Width == 0) != (sensorType == LSM303C) {
+			t.Errorf("%s accelerometer low power field is %+v", sensorType, accelerometer.lowPower)
+		}
+
+		magnetometer := magnetometerDriverFor(sensorType).fields
+		for name, field := range map[string]Field{
+			"dataRate":    magnetometer.dataRate,
+			"temperature": magnetometer.temperature,
+			"mode":        magnetometer.mode,
+			"dataReady":   magnetometer.dataReady,
+		} {
+			if field.Width == 0 {
+				t.Errorf("%s magnetometer has no %s field", sensorType, name)
+			}
+		}
+		if (magnetometer.gain.Width == 0) != (sensorType != LSM303DLHC) {
+			t.Errorf("%s magnetometer gain field is %+v", sensorType, magnetometer.gain)
+		}
+		if (magnetometer.overrun.Width == 0) != (sensorType == LSM303DLHC) {
+			t.Errorf("%s magnetometer overrun field is %+v", sensorType, magnetometer.overrun)
+		}
+	}
+}
+
+func scriptedMagnetometer(sensorType SensorType, ops ...i2ctest.IO) (*Magnetometer, *i2ctest.Playback) {
+	d := datasheetForMagnetometer(sensorType)
+	scenario := &i2ctest.Playback{Ops: ops}
+	return &Magnetometer{
+		mmr:        mmr.Dev8{Conn: &i2c.Dev{Bus: scenario, Addr: d.ADDRESS}, Order: binary.BigEndian},
+		sensorType: sensorType,
+		datasheet:  d,
+	}, scenario
+}
+
+func scriptedAccelerometer(sensorType SensorType, ops ...i2ctest.IO) (*Accelerometer, *i2ctest.Playback) {
+	d := datasheetForAccelerometer(sensorType)
+	scenario := &i2ctest.Playback{Ops: ops}
+	return &Accelerometer{
+		mmr:        mmr.Dev8{Conn: &i2c.Dev{Bus: scenario, Addr: d.ADDRESS}, Order: binary.BigEndian},
+		sensorType: sensorType,
+		datasheet:  d,
+	}, scenario
+}
+
+func TestMagnetometerGetGain(t *testing.T) {
+	// The gain lives in CRB_REG_M, CRA_REG_M holds the data rate
+	magnetometer, scenario := scriptedMagnetometer(LSM303DLHC,
+		i2ctest.IO{Addr: magnetometerDatasheet.ADDRESS, W: []byte{0x01}, R: []byte{uint8(MAGNETOMETER_GAIN_5_6) << 5}},
+	)
+
+	gain, err := magnetometer.GetGain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gain != MAGNETOMETER_GAIN_5_6 {
+		t.Errorf("got %s, want %s", gain, MAGNETOMETER_GAIN_5_6)
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMagnetometerGainFixed(t *testing.T) {
+	for _, sensorType := range []SensorType{LSM303AGR, LSM303C} {
+		// Nothing must be read or written
+		magnetometer, scenario := scriptedMagnetometer(sensorType)
+
+		if _, err := magnetometer.GetGain(); err == nil {
+			t.Errorf("%s: GetGain succeeded on a fixed gain", sensorType)
+		}
+		if err := magnetometer.SetGain(MAGNETOMETER_GAIN_1_3); err == nil {
+			t.Errorf("%s: SetGain succeeded on a fixed gain", sensorType)
+		}
+		if err := scenario.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMagnetometerGetRate(t *testing.T) {
+	tests := []struct {
+		sensorType SensorType
+		ops        []i2ctest.IO
+		want       MagnetometerRate
+	}{
+		// DO in CRA_REG_M bits 2-4, TEMP_EN set
+		{LSM303DLHC, []i2ctest.IO{{W: []byte{0x00}, R: []byte{0x80 | 0b110<<2}}}, MAGNETOMETER_RATE_75},
+		// ODR in CFG_REG_A_M bits 2-3, COMP_TEMP_EN and MD set
+		{LSM303AGR, []i2ctest.IO{{W: []byte{0x60}, R: []byte{0x80 | 0b11<<2 | 0b11}}}, MAGNETOMETER_RATE_7_5},
+		// DO in CTRL_REG1_M bits 2-4, ST set
+		{LSM303C, []i2ctest.IO{{W: []byte{0x20}, R: []byte{0b101<<2 | 0b1}}}, MAGNETOMETER_RATE_30},
+	}
+
+	for _, test := range tests {
+		d := datasheetForMagnetometer(test.sensorType)
+		for i := range test.ops {
+			test.ops[i].Addr = d.ADDRESS
+		}
+		magnetometer, scenario := scriptedMagnetometer(test.sensorType, test.ops...)
+
+		rate, err := magnetometer.GetRate()
+		if err != nil {
+			t.Fatalf("%s: %v", test.sensorType, err)
+		}
+		if rate != test.want {
+			t.Errorf("%s: got %s, want %s", test.sensorType, rate, test.want)
+		}
+		if err := scenario.Close(); err != nil {
+			t.Fatalf("%s: %v", test.sensorType, err)
+		}
+	}
+}
+
+func TestAccelerometerGetMode(t *testing.T) {
+	tests := []struct {
+		sensorType SensorType
+		ops        []i2ctest.IO
+		want       AccelerometerMode
+	}{
+		// LPen in CTRL_REG1_A, HR in CTRL_REG4_A
+		{LSM303DLHC, []i2ctest.IO{
+			{W: []byte{0x20}, R: []byte{0x5F}},
+			{W: []byte{0x23}, R: []byte{0x00}},
+		}, ACCELEROMETER_MODE_LOW_POWER},
+		{LSM303AGR, []i2ctest.IO{
+			{W: []byte{0x20}, R: []byte{0x57}},
+			{W: []byte{0x23}, R: []byte{0x08}},
+		}, ACCELEROMETER_MODE_HIGH_RESOLUTION},
+		// No LPen, HR is CTRL_REG1_A bit 7
+		{LSM303C, []i2ctest.IO{
+			{W: []byte{0x20}, R: []byte{0x87}},
+		}, ACCELEROMETER_MODE_HIGH_RESOLUTION},
+	}
+
+	for _, test := range tests {
+		d := datasheetForAccelerometer(test.sensorType)
+		for i := range test.ops {
+			test.ops[i].Addr = d.ADDRESS
+		}
+		accelerometer, scenario := scriptedAccelerometer(test.sensorType, test.ops...)
+
+		mode, err := accelerometer.GetMode()
+		if err != nil {
+			t.Fatalf("%s: %v", test.sensorType, err)
+		}
+		if mode != test.want {
+			t.Errorf("%s: got %s, want %s", test.sensorType, mode, test.want)
+		}
+		if err := scenario.Close(); err != nil {
+			t.Fatalf("%s: %v", test.sensorType, err)
+		}
+	}
+}
+
+func TestAccelerometerSetModeLowPowerLSM303C(t *testing.T) {
+	accelerometer, scenario := scriptedAccelerometer(LSM303C)
+
+	if err := accelerometer.SetMode(ACCELEROMETER_MODE_LOW_POWER); err == nil {
+		t.Error("LSM303C accepted low power mode")
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccelerometerSetRange(t *testing.T) {
+	d := accelerometerDatasheet
+	// FS is CTRL_REG4_A bits 4-5, HR and the rest are kept
+	accelerometer, scenario := scriptedAccelerometer(LSM303DLHC,
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x23}, R: []byte{0b10001000}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x23, 0b10111000}},
+	)
+
+	if err := accelerometer.SetRange(ACCELEROMETER_RANGE_16G); err != nil {
+		t.Fatal(err)
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccelerometerRangeLSM303C(t *testing.T) {
+	d := datasheetForAccelerometer(LSM303C)
+	// FS is CTRL_REG4_A bits 4-5, 00 = 2G, 10 = 4G, 11 = 8G, 01 is reserved
+	for _, test := range []struct {
+		range_ AccelerometerRange
+		value  uint8
+	}{
+		{ACCELEROMETER_RANGE_4G, 0b10},
+		{ACCELEROMETER_RANGE_8G, 0b11},
+	} {
+		accelerometer, scenario := scriptedAccelerometer(LSM303C,
+			i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x23}, R: []byte{0b00000100}},
+			i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x23, test.value<<4 | 0b00000100}},
+			i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x23}, R: []byte{test.value<<4 | 0b00000100}},
+		)
+
+		if err := accelerometer.SetRange(test.range_); err != nil {
+			t.Fatal(err)
+		}
+		if range_, err := accelerometer.GetRange(); err != nil || range_ != test.range_ {
+			t.Errorf("read back %s, %v, want %s", range_, err, test.range_)
+		}
+		if err := scenario.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Nothing must be written for the missing range, nor read as a range
+	accelerometer, scenario := scriptedAccelerometer(LSM303C,
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x23}, R: []byte{0b01 << 4}},
+	)
+	if err := accelerometer.SetRange(ACCELEROMETER_RANGE_16G); err == nil {
+		t.Error("LSM303C accepted a 16G range")
+	}
+	if range_, err := accelerometer.GetRange(); err == nil {
+		t.Errorf("reserved full scale read as %s", range_)
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMagnetometerFieldsFollowDatasheet(t *testing.T) {
+	// A DLHC lookalike with the control registers moved up by 0x10 and data
+	// ready in bit 1
+	d := datasheetForMagnetometer(LSM303DLHC)
+	d.CRA_REG_M, d.CRB_REG_M, d.MR_REG_M, d.SR_REG_M = 0x10, 0x11, 0x12, 0x19
+	d.DRDY_M = 0b10
+	magnetometer, scenario := scriptedMagnetometer(LSM303DLHC,
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x11}, R: []byte{0}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x11, uint8(MAGNETOMETER_GAIN_8_1) << 5}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x12}, R: []byte{0}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x12, 0b11}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x19}, R: []byte{0b10}},
+	)
+	magnetometer.datasheet = d
+
+	if err := magnetometer.SetGain(MAGNETOMETER_GAIN_8_1); err != nil {
+		t.Fatal(err)
+	}
+	if err := magnetometer.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if status, err := magnetometer.Status(); err != nil || !status.Ready {
+		t.Errorf("status %+v, %v, want ready", status, err)
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,81 @@
+package filter
+
+import (
+	"errors"
+	"math"
+)
+
+// Butterworth is a low-pass Butterworth filter, built as a cascade of second
+// order sections with the bilinear transform. The state starts settled on the
+// first sample, so there is no ramp up from zero.
+type Butterworth struct {
+	sections    []section
+	initialized bool
+}
+
+// A biquad in transposed direct form II, a0 normalized to 1.
+type section struct {
+	b0, b1, b2 float64
+	a1, a2     float64
+	s1, s2     float64
+}
+
+// NewButterworth creates a low-pass filter of the given order (1 to 8) with
+// its -3 dB point at cutoff Hz, for samples arriving at sampleRate Hz.
+func NewButterworth(order int, cutoff, sampleRate float64) (*Butterworth, error) {
+	if order < 1 || order > 8 {
+		return nil, errors.New("butterworth order must be between 1 and 8")
+	}
+	if cutoff <= 0 || cutoff >= sampleRate/2 {
+		return nil, errors.New("butterworth cutoff must be between 0 and half the sample rate")
+	}
+
+	// Prewarped analog cutoff
+	k := math.Tan(math.Pi * cutoff / sampleRate)
+	b := &Butterworth{}
+	for i := 0; i < order/2; i++ {
+		q := 1 / (2 * math.Sin(math.Pi*float64(2*i+1)/float64(2*order)))
+		norm := 1 / (1 + k/q + k*k)
+		b0 := k * k * norm
+		b.sections = append(b.sections, section{
+			b0: b0, b1: 2 * b0, b2: b0,
+			a1: 2 * (k*k - 1) * norm,
+			a2: (1 - k/q + k*k) * norm,
+		})
+	}
+	if order%2 == 1 {
+		norm := 1 / (1 + k)
+		b.sections = append(b.sections, section{
+			b0: k * norm, b1: k * norm,
+			a1: (k - 1) * norm,
+		})
+	}
+	return b, nil
+}
+
+func (b *Butterworth) Next(x float64) float64 {
+	if !b.initialized {
+		// Every section has unity gain at DC, so they all settle on x
+		for i := range b.sections {
+			s := &b.sections[i]
+			s.s2 = (s.b2 - s.a2) * x
+			s.s1 = (s.b1-s.a1)*x + s.s2
+		}
+		b.initialized = true
+	}
+	for i := range b.sections {
+		s := &b.sections[i]
+		y := s.b0*x + s.s1
+		s.s1 = s.b1*x - s.a1*y + s.s2
+		s.s2 = s.b2*x - s.a2*y
+		x = y
+	}
+	return x
+}
+
+func (b *Butterworth) Reset() {
+	for i := range b.sections {
+		b.sections[i].s1, b.sections[i].s2 = 0, 0
+	}
+	b.initialized = false
+}
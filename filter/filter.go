@@ -0,0 +1,95 @@
+// Package filter has small stateful digital filters for smoothing LSM303
+// readings: moving average, exponential smoothing, median and Butterworth
+// low-pass. Each filter works on one scalar stream; Axes runs one per axis on
+// vectors and the Stream functions attach them to a sensor.
+package filter
+
+import (
+	"errors"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+)
+
+// Filter processes a stream of samples one at a time.
+type Filter interface {
+	// Next feeds a sample and returns the filtered value.
+	Next(x float64) float64
+	// Reset forgets all past samples.
+	Reset()
+}
+
+type chain []Filter
+
+// Chain runs filters one after another, e.g. a median to drop spikes followed
+// by a low-pass.
+func Chain(filters ...Filter) Filter {
+	return chain(filters)
+}
+
+func (c chain) Next(x float64) float64 {
+	for _, f := range c {
+		x = f.Next(x)
+	}
+	return x
+}
+
+func (c chain) Reset() {
+	for _, f := range c {
+		f.Reset()
+	}
+}
+
+// Apply runs a filter over a slice of samples and returns the filtered copy.
+// The filter keeps its state, so consecutive slices are treated as one stream.
+func Apply(f Filter, samples []float64) []float64 {
+	filtered := make([]float64, len(samples))
+	for i, x := range samples {
+		filtered[i] = f.Next(x)
+	}
+	return filtered
+}
+
+// Axes filters vectors with an independent filter per axis.
+type Axes struct {
+	x, y, z Filter
+}
+
+// PerAxis creates an Axes with three filters made by newFilter, e.g.
+//
+//	filter.PerAxis(func() (filter.Filter, error) { return filter.NewMedian(5) })
+func PerAxis(newFilter func() (Filter, error)) (*Axes, error) {
+	var filters [3]Filter
+	for i := range filters {
+		f, err := newFilter()
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			return nil, errors.New("filter constructor returned nil")
+		}
+		filters[i] = f
+	}
+	return &Axes{filters[0], filters[1], filters[2]}, nil
+}
+
+// Next feeds a vector sample and returns the filtered vector.
+func (a *Axes) Next(v lsm303.Vector) lsm303.Vector {
+	return lsm303.Vector{X: a.x.Next(v.X), Y: a.y.Next(v.Y), Z: a.z.Next(v.Z)}
+}
+
+// Reset forgets all past samples on every axis.
+func (a *Axes) Reset() {
+	a.x.Reset()
+	a.y.Reset()
+	a.z.Reset()
+}
+
+// ApplyVectors runs the filters over a slice of vectors and returns the
+// filtered copy.
+func (a *Axes) ApplyVectors(samples []lsm303.Vector) []lsm303.Vector {
+	filtered := make([]lsm303.Vector, len(samples))
+	for i, v := range samples {
+		filtered[i] = a.Next(v)
+	}
+	return filtered
+}
@@ -0,0 +1,212 @@
+package filter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+)
+
+const sampleRate = 100.0
+
+// Measures the gain of a filter at the given frequency by feeding it a sine
+// wave and correlating the settled output with it over whole periods.
+func gain(t *testing.T, f Filter, frequency float64) float64 {
+	t.Helper()
+	f.Reset()
+	const settle, measure = 2000, 2000
+	var in, quadrature float64
+	for i := 0; i < settle+measure; i++ {
+		phase := 2 * math.Pi * frequency * float64(i) / sampleRate
+		y := f.Next(math.Sin(phase))
+		if i >= settle {
+			in += y * math.Sin(phase)
+			quadrature += y * math.Cos(phase)
+		}
+	}
+	return 2 * math.Hypot(in, quadrature) / measure
+}
+
+func decibels(gain float64) float64 {
+	return 20 * math.Log10(gain)
+}
+
+func TestButterworthFrequencyResponse(t *testing.T) {
+	for order := 1; order <= 6; order++ {
+		f, err := NewButterworth(order, 5, sampleRate)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if g := gain(t, f, 0.5); math.Abs(g-1) > 0.01 {
+			t.Errorf("order %d: passband gain %v", order, g)
+		}
+		if g := decibels(gain(t, f, 5)); math.Abs(g+3.01) > 0.1 {
+			t.Errorf("order %d: expected -3 dB at the cutoff, got %.2f dB", order, g)
+		}
+		// At least 6 dB per octave per order: 20·order dB a decade, which the
+		// bilinear transform only improves on
+		if g := decibels(gain(t, f, 25)); g > -13.9*float64(order) {
+			t.Errorf("order %d: only %.1f dB at 25 Hz", order, g)
+		}
+	}
+}
+
+func TestButterworthStartsSettled(t *testing.T) {
+	f, err := NewButterworth(4, 2, sampleRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if y := f.Next(9.81); math.Abs(y-9.81) > 1e-9 {
+			t.Fatalf("sample %d: expected a constant input to pass through, got %v", i, y)
+		}
+	}
+}
+
+func TestMovingAverageFrequencyResponse(t *testing.T) {
+	f, err := NewMovingAverage(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// |H(f)| = |sin(πfN/fs) / (N sin(πf/fs))|, with nulls at multiples of fs/N
+	for _, frequency := range []float64{1, 3, 7, 10, 20} {
+		expected := math.Abs(math.Sin(math.Pi*frequency*10/sampleRate) / (10 * math.Sin(math.Pi*frequency/sampleRate)))
+		if g := gain(t, f, frequency); math.Abs(g-expected) > 0.01 {
+			t.Errorf("%v Hz: expected gain %v, got %v", frequency, expected, g)
+		}
+	}
+}
+
+func TestExponentialFrequencyResponse(t *testing.T) {
+	const alpha = 0.2
+	f, err := NewExponential(alpha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// |H(ω)| = α / |1 - (1-α)e^(-jω)|
+	for _, frequency := range []float64{1, 5, 20} {
+		w := 2 * math.Pi * frequency / sampleRate
+		expected := alpha / math.Hypot(1-(1-alpha)*math.Cos(w), (1-alpha)*math.Sin(w))
+		if g := gain(t, f, frequency); math.Abs(g-expected) > 0.01 {
+			t.Errorf("%v Hz: expected gain %v, got %v", frequency, expected, g)
+		}
+	}
+}
+
+func TestMovingAverageWarmUp(t *testing.T) {
+	f, _ := NewMovingAverage(4)
+	filtered := Apply(f, []float64{4, 2, 6, 8, 10})
+	expected := []float64{4, 3, 4, 5, 6.5}
+	for i := range expected {
+		if math.Abs(filtered[i]-expected[i]) > 1e-12 {
+			t.Fatalf("expected %v, got %v", expected, filtered)
+		}
+	}
+}
+
+func TestMedianRejectsSpikes(t *testing.T) {
+	f, _ := NewMedian(3)
+	filtered := Apply(f, []float64{1, 1, 100, 1, 2, 3, -50, 3})
+	expected := []float64{1, 1, 1, 1, 2, 2, 2, 3}
+	for i := range expected {
+		if filtered[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, filtered)
+		}
+	}
+}
+
+func TestChainAndAxes(t *testing.T) {
+	axes, err := PerAxis(func() (Filter, error) {
+		median, err := NewMedian(3)
+		if err != nil {
+			return nil, err
+		}
+		average, err := NewMovingAverage(2)
+		if err != nil {
+			return nil, err
+		}
+		return Chain(median, average), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filtered := axes.ApplyVectors([]lsm303.Vector{{X: 1, Z: 1}, {X: 1, Y: 9, Z: 3}, {X: 1, Z: 5}})
+	last := filtered[len(filtered)-1]
+	// Medians {1, 1, 1}, {0, 4.5, 0}, {1, 3, 3} averaged pairwise
+	if last != (lsm303.Vector{X: 1, Y: 2.25, Z: 2.5}) {
+		t.Fatalf("unexpected output %+v", filtered)
+	}
+
+	axes.Reset()
+	if v := axes.Next(lsm303.Vector{X: 7}); v != (lsm303.Vector{X: 7}) {
+		t.Fatalf("expected reset filters to pass the first sample, got %+v", v)
+	}
+}
+
+func TestConstructorErrors(t *testing.T) {
+	if _, err := NewMovingAverage(0); err == nil {
+		t.Error("expected error for an empty moving average")
+	}
+	if _, err := NewMedian(0); err == nil {
+		t.Error("expected error for an empty median")
+	}
+	if _, err := NewExponential(1.5); err == nil {
+		t.Error("expected error for alpha above 1")
+	}
+	if _, err := NewButterworth(2, 60, sampleRate); err == nil {
+		t.Error("expected error for a cutoff above Nyquist")
+	}
+	if _, err := NewButterworth(0, 5, sampleRate); err == nil {
+		t.Error("expected error for order 0")
+	}
+	if _, err := PerAxis(func() (Filter, error) { return NewMedian(-1) }); err == nil {
+		t.Error("expected PerAxis to pass on constructor errors")
+	}
+}
+
+func TestStream(t *testing.T) {
+	axes, _ := PerAxis(func() (Filter, error) { return NewMovingAverage(2) })
+	readings := []lsm303.Vector{{X: 2}, {X: 4}}
+	broken := errors.New("bus error")
+
+	n := 0
+	samples := stream(context.Background(), time.Millisecond, axes, func() (lsm303.Vector, error) {
+		if n == len(readings) {
+			return lsm303.Vector{}, broken
+		}
+		n++
+		return readings[n-1], nil
+	})
+
+	var received []Sample
+	for sample := range samples {
+		received = append(received, sample)
+	}
+	if len(received) != 3 {
+		t.Fatalf("expected 3 samples, got %+v", received)
+	}
+	if received[0].Value.X != 2 || received[1].Value.X != 3 {
+		t.Errorf("unexpected values %+v", received)
+	}
+	if received[2].Err != broken {
+		t.Errorf("expected the read error last, got %+v", received[2])
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	samples := stream(ctx, time.Millisecond, nil, func() (lsm303.Vector, error) {
+		return lsm303.Vector{Z: 1}, nil
+	})
+	if sample := <-samples; sample.Value.Z != 1 {
+		t.Fatalf("unexpected sample %+v", sample)
+	}
+	cancel()
+	for range samples {
+	}
+}
@@ -0,0 +1,126 @@
+package filter
+
+import (
+	"errors"
+	"sort"
+)
+
+// MovingAverage is the mean of the last n samples. Until n samples have been
+// seen it averages what it has.
+type MovingAverage struct {
+	window []float64
+	next   int
+	filled bool
+	sum    float64
+}
+
+// NewMovingAverage creates a moving average over n samples.
+func NewMovingAverage(n int) (*MovingAverage, error) {
+	if n < 1 {
+		return nil, errors.New("moving average needs a window of at least one sample")
+	}
+	return &MovingAverage{window: make([]float64, n)}, nil
+}
+
+func (m *MovingAverage) Next(x float64) float64 {
+	m.sum += x - m.window[m.next]
+	m.window[m.next] = x
+	m.next++
+	if m.next == len(m.window) {
+		m.next = 0
+		m.filled = true
+		// Recompute now and then so rounding errors don't pile up
+		m.sum = 0
+		for _, value := range m.window {
+			m.sum += value
+		}
+	}
+	if m.filled {
+		return m.sum / float64(len(m.window))
+	}
+	return m.sum / float64(m.next)
+}
+
+func (m *MovingAverage) Reset() {
+	for i := range m.window {
+		m.window[i] = 0
+	}
+	m.next, m.filled, m.sum = 0, false, 0
+}
+
+// Exponential is exponential smoothing, y += alpha * (x - y). The first sample
+// is passed through as is.
+type Exponential struct {
+	alpha       float64
+	value       float64
+	initialized bool
+}
+
+// NewExponential creates an exponential smoothing filter. alpha is between 0
+// (never moves) and 1 (no smoothing); for a time constant tau at sample
+// interval dt, alpha = dt / (tau + dt).
+func NewExponential(alpha float64) (*Exponential, error) {
+	if alpha <= 0 || alpha > 1 {
+		return nil, errors.New("exponential smoothing factor must be in (0, 1]")
+	}
+	return &Exponential{alpha: alpha}, nil
+}
+
+func (e *Exponential) Next(x float64) float64 {
+	if !e.initialized {
+		e.value, e.initialized = x, true
+	} else {
+		e.value += e.alpha * (x - e.value)
+	}
+	return e.value
+}
+
+func (e *Exponential) Reset() {
+	e.value, e.initialized = 0, false
+}
+
+// Median is the median of the last n samples, good at dropping spikes without
+// smearing edges. Until n samples have been seen it uses what it has.
+type Median struct {
+	window []float64
+	sorted []float64
+	next   int
+	count  int
+}
+
+// NewMedian creates a median filter over n samples, n is usually odd.
+func NewMedian(n int) (*Median, error) {
+	if n < 1 {
+		return nil, errors.New("median needs a window of at least one sample")
+	}
+	return &Median{window: make([]float64, n), sorted: make([]float64, 0, n)}, nil
+}
+
+func (m *Median) Next(x float64) float64 {
+	if m.count == len(m.window) {
+		// Drop the sample falling out of the window
+		old := m.window[m.next]
+		i := sort.SearchFloat64s(m.sorted, old)
+		m.sorted = append(m.sorted[:i], m.sorted[i+1:]...)
+	} else {
+		m.count++
+	}
+	m.window[m.next] = x
+	m.next = (m.next + 1) % len(m.window)
+
+	i := sort.SearchFloat64s(m.sorted, x)
+	m.sorted = append(m.sorted, 0)
+	copy(m.sorted[i+1:], m.sorted[i:])
+	m.sorted[i] = x
+
+	middle := len(m.sorted) / 2
+	if len(m.sorted)%2 == 1 {
+		return m.sorted[middle]
+	}
+	return (m.sorted[middle-1] + m.sorted[middle]) / 2
+}
+
+func (m *Median) Reset() {
+	m.sorted = m.sorted[:0]
+	m.next, m.count = 0, 0
+}
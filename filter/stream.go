@@ -0,0 +1,78 @@
+package filter
+
+import (
+	"context"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+	"periph.io/x/periph/conn/physic"
+)
+
+// Sample is a filtered reading from a stream. A sample with Err set is the
+// last one on the channel.
+type Sample struct {
+	Time  time.Time
+	Value lsm303.Vector
+	Err   error
+}
+
+// StreamAccelerometer reads the accelerometer every interval, filters the
+// readings (in units of standard gravity) and sends them on the returned
+// channel until the context is done or a read fails. filters can be nil.
+func StreamAccelerometer(ctx context.Context, accelerometer *lsm303.Accelerometer, interval time.Duration, filters *Axes) <-chan Sample {
+	return stream(ctx, interval, filters, func() (lsm303.Vector, error) {
+		x, y, z, err := accelerometer.Sense()
+		if err != nil {
+			return lsm303.Vector{}, err
+		}
+		g := float64(physic.EarthGravity)
+		return lsm303.Vector{X: float64(x) / g, Y: float64(y) / g, Z: float64(z) / g}, nil
+	})
+}
+
+// StreamMagnetometer reads the magnetometer every interval, filters the
+// readings (in gauss) and sends them on the returned channel until the context
+// is done or a read fails.
+func StreamMagnetometer(ctx context.Context, magnetometer *lsm303.Magnetometer, interval time.Duration, filters *Axes) <-chan Sample {
+	return stream(ctx, interval, filters, func() (lsm303.Vector, error) {
+		x, y, z, err := magnetometer.Sense()
+		if err != nil {
+			return lsm303.Vector{}, err
+		}
+		gauss := float64(lsm303.Gauss)
+		return lsm303.Vector{X: float64(x) / gauss, Y: float64(y) / gauss, Z: float64(z) / gauss}, nil
+	})
+}
+
+func stream(ctx context.Context, interval time.Duration, filters *Axes, read func() (lsm303.Vector, error)) <-chan Sample {
+	samples := make(chan Sample)
+	go func() {
+		defer close(samples)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			value, err := read()
+			sample := Sample{Time: time.Now(), Err: err}
+			if err == nil {
+				if filters != nil {
+					value = filters.Next(value)
+				}
+				sample.Value = value
+			}
+			select {
+			case samples <- sample:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return samples
+}
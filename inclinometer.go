@@ -0,0 +1,177 @@
+package lsm303
+
+import (
+	"context"
+	"errors"
+	"math"
+)
+
+// Tilt is an inclinometer reading, all angles in degrees. Pitch is positive
+// nose up, roll is positive right side down and Angle is the total tilt away
+// from the reference vertical regardless of direction.
+type Tilt struct {
+	Pitch float64
+	Roll  float64
+	Angle float64
+}
+
+// Inclinometer measures static tilt with the accelerometer. It is meant for
+// things that should stay put (poles, tanks, solar trackers), so readings are
+// averaged and can be taken relative to the orientation captured at install
+// time. Tilt is that of the body frame, the accelerometer's axis remap is the
+// mounting.
+type Inclinometer struct {
+	accelerometer *Accelerometer
+	samples       int
+
+	// Rotation taking the installed "down" direction onto the vertical
+	reference     *matrix3
+	referenceDown *Vector
+
+	threshold float64
+	alert     func(Tilt)
+	exceeded  bool
+}
+
+// NewInclinometer creates an inclinometer from an initialized accelerometer.
+func NewInclinometer(accelerometer *Accelerometer, opts ...InclinometerOption) (*Inclinometer, error) {
+	if accelerometer == nil {
+		return nil, errors.New("inclinometer requires an accelerometer")
+	}
+
+	inclinometer := &Inclinometer{
+		accelerometer: accelerometer,
+		samples:       1,
+	}
+
+	for i := range opts {
+		opts[i].Apply(inclinometer)
+	}
+
+	if inclinometer.samples < 1 {
+		return nil, errors.New("inclinometer needs to average at least one sample")
+	}
+	if inclinometer.referenceDown != nil {
+		reference := rotationBetween(AXIS_CONVENTION_NWU.ToNED(*inclinometer.referenceDown).Normalize(), Vector{0, 0, 1})
+		inclinometer.reference = &reference
+	}
+
+	return inclinometer, nil
+}
+
+// Sense returns the averaged tilt. If a threshold is configured and the tilt
+// just went beyond it, the alert callback is called before returning.
+func (i *Inclinometer) Sense() (Tilt, error) {
+	down, err := i.senseDown()
+	if err != nil {
+		return Tilt{}, err
+	}
+
+	tilt := i.tilt(down)
+	if i.alert != nil {
+		exceeded := tilt.Angle > i.threshold
+		if exceeded && !i.exceeded {
+			i.alert(tilt)
+		}
+		i.exceeded = exceeded
+	}
+
+	return tilt, nil
+}
+
+// Exceeded reports whether the last reading was beyond the threshold.
+func (i *Inclinometer) Exceeded() bool {
+	return i.exceeded
+}
+
+// Zero captures the current orientation as the reference, so later readings
+// are relative to it. Call it once the device is installed.
+func (i *Inclinometer) Zero() error {
+	down, err := i.senseDown()
+	if err != nil {
+		return err
+	}
+	reference := rotationBetween(down, Vector{0, 0, 1})
+	i.reference = &reference
+	referenceDown := AXIS_CONVENTION_NWU.FromNED(down)
+	i.referenceDown = &referenceDown
+	i.exceeded = false
+	return nil
+}
+
+// Reference returns the reference captured by Zero, in the form
+// WithTiltReference expects, and whether there is one.
+func (i *Inclinometer) Reference() (Vector, bool) {
+	if i.reference == nil || i.referenceDown == nil {
+		return Vector{}, false
+	}
+	return *i.referenceDown, true
+}
+
+// ClearZero goes back to measuring tilt relative to the true vertical.
+func (i *Inclinometer) ClearZero() {
+	i.reference = nil
+	i.referenceDown = nil
+	i.exceeded = false
+}
+
+// Reads the averaged direction of gravity in the NED body frame, from fresh
+// samples like SenseAveraged.
+func (i *Inclinometer) senseDown() (Vector, error) {
+	result, err := i.accelerometer.SenseAveraged(context.Background(), i.samples)
+	if err != nil {
+		return Vector{}, err
+	}
+	down := AXIS_CONVENTION_NWU.ToNED(result.Mean).Scale(-1).Normalize()
+	if down == (Vector{}) {
+		return Vector{}, errors.New("accelerometer reads no gravity")
+	}
+	return down, nil
+}
+
+func (i *Inclinometer) tilt(down Vector) Tilt {
+	if i.reference != nil {
+		down = i.reference.mulVec(down)
+	}
+	return Tilt{
+		Pitch: degrees(math.Atan2(-down.X, math.Hypot(down.Y, down.Z))),
+		Roll:  degrees(math.Atan2(down.Y, down.Z)),
+		Angle: degrees(math.Acos(math.Max(-1, math.Min(1, down.Z)))),
+	}
+}
+
+// Computes the rotation matrix turning the unit vector from onto the unit
+// vector to (Rodrigues' formula).
+func rotationBetween(from, to Vector) matrix3 {
+	axis := from.Cross(to)
+	sin := axis.Norm()
+	cos := from.Dot(to)
+	if sin < 1e-12 {
+		if cos > 0 {
+			return identity3()
+		}
+		// Opposite vectors, turn half way around any perpendicular axis
+		perpendicular := from.Cross(Vector{1, 0, 0})
+		if perpendicular.Norm() < 1e-6 {
+			perpendicular = from.Cross(Vector{0, 1, 0})
+		}
+		axis = perpendicular.Normalize()
+		sin, cos = 0, -1
+	} else {
+		axis = axis.Scale(1 / sin)
+	}
+
+	k := matrix3{
+		{0, -axis.Z, axis.Y},
+		{axis.Z, 0, -axis.X},
+		{-axis.Y, axis.X, 0},
+	}
+	k2 := k.mul(k)
+	r := identity3()
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			r[row][col] += sin*k[row][col] + (1-cos)*k2[row][col]
+		}
+	}
+	return r
+}
@@ -0,0 +1,172 @@
+package lsm303
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/mmr"
+)
+
+// Creates an accelerometer (±2G, normal mode) that returns the given raw
+// readings in order.
+func playbackAccelerometer(readings ...Vector) *Accelerometer {
+	return newPlaybackAccelerometer(false, readings)
+}
+
+// Like playbackAccelerometer, with the data-ready flag up before each reading.
+func freshAccelerometer(readings ...Vector) *Accelerometer {
+	return newPlaybackAccelerometer(true, readings)
+}
+
+func newPlaybackAccelerometer(fresh bool, readings []Vector) *Accelerometer {
+	registers := [...]uint8{
+		accelerometerDatasheet.OUT_X_L_A, accelerometerDatasheet.OUT_X_H_A,
+		accelerometerDatasheet.OUT_Y_L_A, accelerometerDatasheet.OUT_Y_H_A,
+		accelerometerDatasheet.OUT_Z_L_A, accelerometerDatasheet.OUT_Z_H_A,
+	}
+	var ops []i2ctest.IO
+	for _, reading := range readings {
+		if fresh {
+			ops = append(ops, i2ctest.IO{Addr: accelerometerDatasheet.ADDRESS, W: []byte{accelerometerDatasheet.STATUS_REG_A}, R: []byte{0x08}})
+		}
+		for axis, value := range [3]float64{reading.X, reading.Y, reading.Z} {
+			raw := uint16(int16(math.Round(value)))
+			ops = append(ops,
+				i2ctest.IO{Addr: accelerometerDatasheet.ADDRESS, W: []byte{registers[2*axis]}, R: []byte{uint8(raw)}},
+				i2ctest.IO{Addr: accelerometerDatasheet.ADDRESS, W: []byte{registers[2*axis+1]}, R: []byte{uint8(raw >> 8)}})
+		}
+	}
+
+	return &Accelerometer{
+		mmr: mmr.Dev8{
+			Conn:  &i2c.Dev{Bus: &i2ctest.Playback{Ops: ops}, Addr: accelerometerDatasheet.ADDRESS},
+			Order: binary.BigEndian,
+		},
+		datasheet: accelerometerDatasheet,
+		range_:    ACCELEROMETER_RANGE_2G,
+		mode:      ACCELEROMETER_MODE_NORMAL,
+	}
+}
+
+// Raw reading of a level sensor (NWU) tilted by pitch and roll degrees.
+func tiltedReading(pitch, roll float64) Vector {
+	specificForce, _ := syntheticNED(0, pitch, roll)
+	return AXIS_CONVENTION_NWU.FromNED(specificForce).Scale(16000)
+}
+
+func TestInclinometerTilt(t *testing.T) {
+	accelerometer := freshAccelerometer(tiltedReading(10, -20))
+	inclinometer, err := NewInclinometer(accelerometer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tilt, err := inclinometer.Sense()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(tilt.Pitch-10) > 0.1 || math.Abs(tilt.Roll+20) > 0.1 {
+		t.Fatalf("unexpected tilt %+v", tilt)
+	}
+	// cos(angle) = cos(pitch) * cos(roll)
+	expected := degrees(math.Acos(math.Cos(radians(10)) * math.Cos(radians(20))))
+	if math.Abs(tilt.Angle-expected) > 0.1 {
+		t.Fatalf("expected total tilt %v, got %v", expected, tilt.Angle)
+	}
+}
+
+func TestInclinometerAveraging(t *testing.T) {
+	// Two readings symmetric around level average out to level
+	accelerometer := freshAccelerometer(tiltedReading(5, 0), tiltedReading(-5, 0))
+	inclinometer, err := NewInclinometer(accelerometer, WithAveraging(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tilt, err := inclinometer.Sense()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(tilt.Angle) > 0.1 {
+		t.Fatalf("expected level after averaging, got %+v", tilt)
+	}
+
+	if _, err := NewInclinometer(accelerometer, WithAveraging(0)); err == nil {
+		t.Fatal("expected error for zero samples")
+	}
+}
+
+func TestInclinometerZero(t *testing.T) {
+	// Installed crooked at 15° pitch, then leaning 5° further
+	accelerometer := freshAccelerometer(tiltedReading(15, 0), tiltedReading(20, 0))
+	inclinometer, err := NewInclinometer(accelerometer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := inclinometer.Zero(); err != nil {
+		t.Fatal(err)
+	}
+	tilt, err := inclinometer.Sense()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(tilt.Pitch-5) > 0.1 || math.Abs(tilt.Angle-5) > 0.1 {
+		t.Fatalf("expected 5° relative to install, got %+v", tilt)
+	}
+
+	// The reference survives a restart
+	reference, ok := inclinometer.Reference()
+	if !ok || reference.Z > -0.9 {
+		t.Fatalf("expected gravity pointing down the Z axis, got %+v", reference)
+	}
+	restored, err := NewInclinometer(freshAccelerometer(tiltedReading(15, 0)), WithTiltReference(reference))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tilt, err = restored.Sense()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tilt.Angle > 0.1 {
+		t.Fatalf("expected level with restored reference, got %+v", tilt)
+	}
+}
+
+func TestInclinometerThreshold(t *testing.T) {
+	accelerometer := freshAccelerometer(
+		tiltedReading(1, 0),
+		tiltedReading(6, 0),
+		tiltedReading(7, 0),
+		tiltedReading(2, 0),
+		tiltedReading(0, -8),
+	)
+	var alerts []Tilt
+	inclinometer, err := NewInclinometer(accelerometer, WithTiltThreshold(5, func(tilt Tilt) {
+		alerts = append(alerts, tilt)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []bool{false, true, true, false, true}
+	for i := range expected {
+		if _, err := inclinometer.Sense(); err != nil {
+			t.Fatal(err)
+		}
+		if inclinometer.Exceeded() != expected[i] {
+			t.Fatalf("reading %d: expected exceeded=%v", i, expected[i])
+		}
+	}
+
+	// Only crossing the limit alerts, not staying beyond it
+	if len(alerts) != 2 {
+		t.Fatalf("expected 2 alerts, got %+v", alerts)
+	}
+	if math.Abs(alerts[1].Roll+8) > 0.1 {
+		t.Fatalf("unexpected second alert %+v", alerts[1])
+	}
+}
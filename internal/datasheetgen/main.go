@@ -0,0 +1,775 @@
+// Command datasheetgen generates the per-variant datasheet values and register
+// maps of the lsm303 package, and their documentation, from the JSON register
+// descriptions in datasheets/. Run it with go generate from the package
+// directory:
+//
+//	go generate github.com/timoth-y/go-lsm303
+//
+// Besides the register maps, a description holds what the driver needs to know
+// about the variant: which bitfields play which part, the data rates, ranges
+// and sensitivities, the self-test limits and where Detect probes it. Adding a
+// variant means adding a description file and a SensorType constant named
+// after its sensor_type. The driver itself needs no changes; outside of it,
+// list the variant in the -type flag of cmd/lsm303, and add a model to
+// simulator/variants.go to simulate it, and check the special cases for the
+// LSM303DLHC (configurable gain) and LSM303AGR (no magnetometer temperature) in
+// the server, mqtt, exporter and cmd/lsm303 packages if it shares either.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Variant is the description of one LSM303 variant, one file per variant.
+type Variant struct {
+	// SensorType is the name of the SensorType constant
+	SensorType  string `json:"sensor_type"`
+	Description string `json:"description"`
+	// Default is the variant unknown sensor types fall back to, there must be
+	// exactly one
+	Default bool `json:"default"`
+	// DetectionOrder is the position Detect probes the variant at, from 1.
+	// Variants without one aren't probed.
+	DetectionOrder int    `json:"detection_order"`
+	Accelerometer  Device `json:"accelerometer"`
+	Magnetometer   Device `json:"magnetometer"`
+
+	file string
+}
+
+// Device is the accelerometer or magnetometer half of a variant.
+type Device struct {
+	// Datasheet lists the fields of the AccelerometerDatasheet or
+	// MagnetometerDatasheet value, in order
+	Datasheet []DatasheetValue `json:"datasheet"`
+	// Registers is the register map, in address order
+	Registers []Register `json:"registers"`
+	// Driver is what the driver needs beyond the register map. Variants
+	// without one use the default variant's.
+	Driver *Driver `json:"driver"`
+}
+
+// DatasheetValue is one field of a datasheet struct. Value is a Go integer
+// literal and is written out as is.
+type DatasheetValue struct {
+	Field   string `json:"field"`
+	Value   string `json:"value"`
+	Comment string `json:"comment"`
+}
+
+// Register is one entry of a register map. Address is a Go integer literal.
+type Register struct {
+	Name        string  `json:"name"`
+	Address     string  `json:"address"`
+	Writable    bool    `json:"writable"`
+	Description string  `json:"description"`
+	Fields      []Field `json:"fields"`
+
+	address uint8
+}
+
+// Field is a group of bits of a register.
+type Field struct {
+	Name         string `json:"name"`
+	Shift        uint8  `json:"shift"`
+	Width        uint8  `json:"width"`
+	SelfClearing bool   `json:"self_clearing"`
+}
+
+// Driver is what the driver needs to know about a device beyond its register
+// map. The accelerometer and the magnetometer use different keys, see
+// validateAccelerometer and validateMagnetometer. Values are Go integer
+// literals, written out as is.
+type Driver struct {
+	// Fields names the bitfield playing each part the driver knows, as
+	// REGISTER.FIELD, see accelerometerRoles and magnetometerRoles
+	Fields map[string]string `json:"fields"`
+	// Rate100Hz is the accelerometer ODR value for 100 Hz in normal mode
+	Rate100Hz string `json:"rate_100hz"`
+	// FullScales is the accelerometer FS value by range, ranges the variant
+	// doesn't have are left out
+	FullScales map[string]string `json:"full_scales"`
+	// Rates are the magnetometer output data rates by rate setting, and
+	// DefaultRate the one it starts at
+	Rates       []float64 `json:"rates_hz"`
+	DefaultRate float64   `json:"default_rate_hz"`
+	// The magnetometer sensitivity, either by gain setting as X/Y and Z counts
+	// per gauss, or fixed in mgauss per count
+	LsbPerGauss  [][2]float64 `json:"lsb_per_gauss"`
+	MgaussPerLsb float64      `json:"mgauss_per_lsb"`
+	SelfTest     *SelfTest    `json:"self_test"`
+
+	// Fields resolved against the register map, by key
+	fields      map[string]resolvedField
+	defaultRate int
+}
+
+// SelfTest is the datasheet self-test procedure: the value that turns the
+// self_test field on, how long the output takes to settle, how many samples to
+// average and the limits of the change on each axis.
+type SelfTest struct {
+	Enable   string     `json:"enable"`
+	SettleMs int        `json:"settle_ms"`
+	Samples  int        `json:"samples"`
+	Min      [3]float64 `json:"min"`
+	Max      [3]float64 `json:"max"`
+	// Comment is written out above the procedure
+	Comment string `json:"comment"`
+}
+
+type resolvedField struct {
+	register Register
+	field    Field
+}
+
+// A bitfield the driver uses, by its key in Driver.Fields and the member of
+// accelerometerFields or magnetometerFields it fills in.
+type role struct {
+	key, member string
+	required    bool
+}
+
+var accelerometerRoles = []role{
+	{"data_rate", "dataRate", true},
+	{"low_power", "lowPower", false},
+	{"high_resolution", "highResolution", true},
+	{"full_scale", "fullScale", true},
+	{"block_data_update", "blockDataUpdate", true},
+	{"x_enable", "axisEnable[0]", true},
+	{"y_enable", "axisEnable[1]", true},
+	{"z_enable", "axisEnable[2]", true},
+	{"self_test", "selfTest", true},
+	{"boot", "boot", true},
+	{"data_ready", "dataReady", true},
+	{"overrun", "overrun", false},
+}
+
+var magnetometerRoles = []role{
+	{"data_rate", "dataRate", true},
+	{"temperature", "temperature", true},
+	{"gain", "gain", false},
+	{"mode", "mode", true},
+	{"self_test", "selfTest", false},
+	{"reboot", "reboot", false},
+	{"soft_reset", "softReset", false},
+	{"data_ready", "dataReady", true},
+	{"overrun", "overrun", false},
+}
+
+// The keys of Driver.FullScales and the AccelerometerRange constants they
+// stand for, in order.
+var accelerometerRanges = []struct{ key, constant string }{
+	{"2G", "ACCELEROMETER_RANGE_2G"},
+	{"4G", "ACCELEROMETER_RANGE_4G"},
+	{"8G", "ACCELEROMETER_RANGE_8G"},
+	{"16G", "ACCELEROMETER_RANGE_16G"},
+}
+
+func main() {
+	dir := flag.String("dir", "datasheets", "directory with the register descriptions")
+	out := flag.String("out", "datasheet_generated.go", "Go file to generate")
+	doc := flag.String("doc", filepath.Join("datasheets", "REGISTERS.md"), "documentation file to generate")
+	flag.Parse()
+
+	variants, err := load(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	source, err := generateGo(variants)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, source, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*doc, generateDoc(variants), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// load reads and validates every *.json description in dir. Variants are
+// sorted by sensor type.
+func load(dir string) ([]Variant, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var variants []Variant
+	for _, file := range files {
+		variant, err := loadVariant(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		variants = append(variants, variant)
+	}
+	sort.Slice(variants, func(i, j int) bool {
+		return variants[i].SensorType < variants[j].SensorType
+	})
+
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("no register descriptions in %s", dir)
+	}
+	defaults := 0
+	detectionOrders := map[int]string{}
+	for i, variant := range variants {
+		if i > 0 && variant.SensorType == variants[i-1].SensorType {
+			return nil, fmt.Errorf("%s is described twice", variant.SensorType)
+		}
+		if variant.Default {
+			defaults++
+			// The others fall back to it
+			if variant.Accelerometer.Driver == nil || variant.Magnetometer.Driver == nil {
+				return nil, fmt.Errorf("the default variant %s needs an accelerometer and a magnetometer driver", variant.SensorType)
+			}
+		}
+		if order := variant.DetectionOrder; order != 0 {
+			if other, ok := detectionOrders[order]; ok {
+				return nil, fmt.Errorf("%s and %s have the same detection_order", other, variant.SensorType)
+			}
+			detectionOrders[order] = variant.SensorType
+		}
+	}
+	if defaults != 1 {
+		return nil, fmt.Errorf("%d variants are marked as the default, need exactly one", defaults)
+	}
+	return variants, nil
+}
+
+func loadVariant(file string) (Variant, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Variant{}, err
+	}
+
+	var variant Variant
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&variant); err != nil {
+		return Variant{}, err
+	}
+	variant.file = filepath.Base(file)
+
+	if variant.SensorType == "" {
+		return Variant{}, fmt.Errorf("sensor_type is missing")
+	}
+	if err := variant.Accelerometer.validate(); err != nil {
+		return Variant{}, fmt.Errorf("accelerometer: %w", err)
+	}
+	if err := variant.Magnetometer.validate(); err != nil {
+		return Variant{}, fmt.Errorf("magnetometer: %w", err)
+	}
+	if driver := variant.Accelerometer.Driver; driver != nil {
+		if err := driver.validateAccelerometer(variant.Accelerometer.Registers); err != nil {
+			return Variant{}, fmt.Errorf("accelerometer driver: %w", err)
+		}
+	}
+	if driver := variant.Magnetometer.Driver; driver != nil {
+		if err := driver.validateMagnetometer(variant.Magnetometer.Registers); err != nil {
+			return Variant{}, fmt.Errorf("magnetometer driver: %w", err)
+		}
+	}
+	if variant.DetectionOrder < 0 {
+		return Variant{}, fmt.Errorf("detection_order must be positive")
+	}
+	return variant, nil
+}
+
+func (d *Device) validate() error {
+	fields := map[string]bool{}
+	for _, value := range d.Datasheet {
+		if fields[value.Field] {
+			return fmt.Errorf("datasheet field %s is set twice", value.Field)
+		}
+		fields[value.Field] = true
+		// ADDRESS is the only 16 bit field
+		if _, err := strconv.ParseUint(value.Value, 0, 16); err != nil {
+			return fmt.Errorf("datasheet field %s: %w", value.Field, err)
+		}
+	}
+
+	names := map[string]bool{}
+	for i := range d.Registers {
+		register := &d.Registers[i]
+		address, err := strconv.ParseUint(register.Address, 0, 8)
+		if err != nil {
+			return fmt.Errorf("register %s: %w", register.Name, err)
+		}
+		register.address = uint8(address)
+
+		if names[register.Name] {
+			return fmt.Errorf("register %s is described twice", register.Name)
+		}
+		names[register.Name] = true
+		if i > 0 && register.address <= d.Registers[i-1].address {
+			return fmt.Errorf("register %s is out of address order", register.Name)
+		}
+
+		var used uint8
+		for _, field := range register.Fields {
+			if field.Width == 0 || int(field.Shift)+int(field.Width) > 8 {
+				return fmt.Errorf("%s.%s doesn't fit in a byte", register.Name, field.Name)
+			}
+			mask := uint8((1<<field.Width - 1) << field.Shift)
+			if used&mask != 0 {
+				return fmt.Errorf("%s.%s overlaps another field", register.Name, field.Name)
+			}
+			used |= mask
+		}
+	}
+	return nil
+}
+
+// Resolves Fields against the register map. Every key must be a role, and the
+// required roles must be there.
+func (d *Driver) resolveFields(roles []role, registers []Register) error {
+	known := map[string]bool{}
+	for _, role := range roles {
+		known[role.key] = true
+	}
+	keys := make([]string, 0, len(d.Fields))
+	for key := range d.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !known[key] {
+			return fmt.Errorf("fields.%s isn't a field the driver uses", key)
+		}
+	}
+
+	d.fields = map[string]resolvedField{}
+	for _, role := range roles {
+		name, ok := d.Fields[role.key]
+		if !ok {
+			if role.required {
+				return fmt.Errorf("fields.%s is missing", role.key)
+			}
+			continue
+		}
+		field, err := lookupField(registers, name)
+		if err != nil {
+			return fmt.Errorf("fields.%s: %w", role.key, err)
+		}
+		d.fields[role.key] = field
+	}
+	return nil
+}
+
+func lookupField(registers []Register, name string) (resolvedField, error) {
+	registerName, fieldName, ok := strings.Cut(name, ".")
+	if !ok {
+		return resolvedField{}, fmt.Errorf("%q isn't REGISTER.FIELD", name)
+	}
+	for _, register := range registers {
+		if register.Name != registerName {
+			continue
+		}
+		for _, field := range register.Fields {
+			if field.Name == fieldName {
+				return resolvedField{register, field}, nil
+			}
+		}
+	}
+	return resolvedField{}, fmt.Errorf("no bitfield %s in the register map", name)
+}
+
+// Checks a value is an integer literal that fits in the field.
+func checkValue(key, value string, field resolvedField) error {
+	x, err := strconv.ParseUint(value, 0, 8)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	if x >= 1<<field.field.Width {
+		return fmt.Errorf("%s %s doesn't fit in %s.%s", key, value, field.register.Name, field.field.Name)
+	}
+	return nil
+}
+
+func (d *Driver) validateAccelerometer(registers []Register) error {
+	if err := d.resolveFields(accelerometerRoles, registers); err != nil {
+		return err
+	}
+	if len(d.Rates) != 0 || d.DefaultRate != 0 || len(d.LsbPerGauss) != 0 || d.MgaussPerLsb != 0 {
+		return fmt.Errorf("rates_hz, default_rate_hz, lsb_per_gauss and mgauss_per_lsb are magnetometer settings")
+	}
+	if err := checkValue("rate_100hz", d.Rate100Hz, d.fields["data_rate"]); err != nil {
+		return err
+	}
+
+	if len(d.FullScales) == 0 {
+		return fmt.Errorf("full_scales is missing")
+	}
+	known := map[string]bool{}
+	for _, range_ := range accelerometerRanges {
+		known[range_.key] = true
+	}
+	ranges := map[uint64]string{}
+	for key, value := range d.FullScales {
+		if !known[key] {
+			return fmt.Errorf("full_scales.%s isn't a range", key)
+		}
+		if err := checkValue("full_scales."+key, value, d.fields["full_scale"]); err != nil {
+			return err
+		}
+		// GetRange maps the value back
+		x, _ := strconv.ParseUint(value, 0, 8)
+		if other, ok := ranges[x]; ok {
+			return fmt.Errorf("full_scales.%s and full_scales.%s are both %s", other, key, value)
+		}
+		ranges[x] = key
+	}
+
+	if d.SelfTest == nil {
+		return fmt.Errorf("self_test is missing")
+	}
+	return d.SelfTest.validate(d.fields["self_test"])
+}
+
+func (d *Driver) validateMagnetometer(registers []Register) error {
+	if err := d.resolveFields(magnetometerRoles, registers); err != nil {
+		return err
+	}
+	if d.Rate100Hz != "" || len(d.FullScales) != 0 {
+		return fmt.Errorf("rate_100hz and full_scales are accelerometer settings")
+	}
+
+	if len(d.Rates) == 0 {
+		return fmt.Errorf("rates_hz is missing")
+	}
+	if len(d.Rates) > 1<<d.fields["data_rate"].field.Width {
+		return fmt.Errorf("rates_hz has more rates than fields.data_rate can select")
+	}
+	d.defaultRate = -1
+	for i, rate := range d.Rates {
+		if rate == d.DefaultRate {
+			d.defaultRate = i
+		}
+	}
+	if d.defaultRate < 0 {
+		return fmt.Errorf("default_rate_hz %v isn't one of rates_hz", d.DefaultRate)
+	}
+
+	_, gain := d.fields["gain"]
+	switch {
+	case gain && (len(d.LsbPerGauss) == 0 || d.MgaussPerLsb != 0):
+		return fmt.Errorf("a gain field needs lsb_per_gauss by gain setting and no mgauss_per_lsb")
+	case !gain && (d.MgaussPerLsb <= 0 || len(d.LsbPerGauss) != 0):
+		return fmt.Errorf("a fixed gain needs mgauss_per_lsb and no lsb_per_gauss")
+	case len(d.LsbPerGauss) > 1<<d.fields["gain"].field.Width:
+		return fmt.Errorf("lsb_per_gauss has more entries than fields.gain can select")
+	}
+
+	// The driver reboots, then soft resets
+	_, reboot := d.fields["reboot"]
+	_, softReset := d.fields["soft_reset"]
+	if reboot != softReset {
+		return fmt.Errorf("fields.reboot and fields.soft_reset go together")
+	}
+
+	_, selfTest := d.fields["self_test"]
+	if selfTest != (d.SelfTest != nil) {
+		return fmt.Errorf("fields.self_test and self_test go together")
+	}
+	if d.SelfTest != nil {
+		return d.SelfTest.validate(d.fields["self_test"])
+	}
+	return nil
+}
+
+func (s *SelfTest) validate(field resolvedField) error {
+	if err := checkValue("self_test.enable", s.Enable, field); err != nil {
+		return err
+	}
+	if s.SettleMs <= 0 || s.Samples <= 0 {
+		return fmt.Errorf("self_test needs settle_ms and samples")
+	}
+	for i, axis := range []string{"X", "Y", "Z"} {
+		if s.Min[i] >= s.Max[i] {
+			return fmt.Errorf("self_test limits leave nothing on %s", axis)
+		}
+	}
+	return nil
+}
+
+// Prefix of the generated identifiers of the variant, e.g. lsm303dlhc.
+func (v Variant) ident() string {
+	return strings.ToLower(v.SensorType)
+}
+
+// generateGo renders the datasheet values, register maps and lookup functions
+// as gofmt'ed Go source.
+func generateGo(variants []Variant) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("// Code generated by datasheetgen from datasheets/*.json. DO NOT EDIT.\n\n")
+	b.WriteString("package lsm303\n\n")
+	b.WriteString("import \"time\"\n")
+
+	for _, variant := range variants {
+		for _, device := range []struct {
+			name string
+			Device
+		}{{"Accelerometer", variant.Accelerometer}, {"Magnetometer", variant.Magnetometer}} {
+			fmt.Fprintf(&b, "\n// %s %s, from datasheets/%s.\n", variant.SensorType, strings.ToLower(device.name), variant.file)
+			fmt.Fprintf(&b, "var %s%sDatasheet = %sDatasheet{\n", variant.ident(), device.name, device.name)
+			for _, value := range device.Datasheet {
+				fmt.Fprintf(&b, "%s: %s,", value.Field, value.Value)
+				if value.Comment != "" {
+					fmt.Fprintf(&b, " // %s", value.Comment)
+				}
+				b.WriteString("\n")
+			}
+			b.WriteString("}\n")
+
+			fmt.Fprintf(&b, "\nvar %s%sRegisters = []Register{\n", variant.ident(), device.name)
+			for _, register := range device.Registers {
+				if register.Description != "" {
+					fmt.Fprintf(&b, "// %s\n", register.Description)
+				}
+				fmt.Fprintf(&b, "{%q, 0x%02X, %t, ", register.Name, register.address, register.Writable)
+				if len(register.Fields) == 0 {
+					b.WriteString("nil")
+				} else {
+					b.WriteString("[]Field{")
+					for i, field := range register.Fields {
+						if i > 0 {
+							b.WriteString(", ")
+						}
+						b.WriteString(fieldLiteral(resolvedField{register, field}))
+					}
+					b.WriteString("}")
+				}
+				b.WriteString("},\n")
+			}
+			b.WriteString("}\n")
+		}
+		if driver := variant.Accelerometer.Driver; driver != nil {
+			writeAccelerometerDriver(&b, variant, driver)
+		}
+		if driver := variant.Magnetometer.Driver; driver != nil {
+			writeMagnetometerDriver(&b, variant, driver)
+		}
+	}
+
+	for _, lookup := range []struct{ name, result, suffix string }{
+		{"datasheetForAccelerometer", "*AccelerometerDatasheet", "AccelerometerDatasheet"},
+		{"datasheetForMagnetometer", "*MagnetometerDatasheet", "MagnetometerDatasheet"},
+		{"accelerometerRegisters", "[]Register", "AccelerometerRegisters"},
+		{"magnetometerRegisters", "[]Register", "MagnetometerRegisters"},
+	} {
+		// Datasheets are copied, callers are free to modify theirs
+		copied := strings.HasPrefix(lookup.result, "*")
+		fmt.Fprintf(&b, "\nfunc %s(sensorType SensorType) %s {\n", lookup.name, lookup.result)
+		b.WriteString("switch sensorType {\n")
+		var fallback Variant
+		for _, variant := range variants {
+			if variant.Default {
+				fallback = variant
+				continue
+			}
+			fmt.Fprintf(&b, "case %s:\n", variant.SensorType)
+			writeLookupReturn(&b, variant.ident()+lookup.suffix, copied)
+		}
+		b.WriteString("default:\n")
+		writeLookupReturn(&b, fallback.ident()+lookup.suffix, copied)
+		b.WriteString("}\n}\n")
+	}
+
+	// Drivers are shared, variants without their own get the default's
+	for _, lookup := range []struct {
+		name, result, suffix string
+		driver               func(Variant) *Driver
+	}{
+		{"accelerometerDriverFor", "*accelerometerDriver", "AccelerometerDriver", func(v Variant) *Driver { return v.Accelerometer.Driver }},
+		{"magnetometerDriverFor", "*magnetometerDriver", "MagnetometerDriver", func(v Variant) *Driver { return v.Magnetometer.Driver }},
+	} {
+		fmt.Fprintf(&b, "\nfunc %s(sensorType SensorType) %s {\n", lookup.name, lookup.result)
+		b.WriteString("switch sensorType {\n")
+		var fallback Variant
+		for _, variant := range variants {
+			if variant.Default {
+				fallback = variant
+				continue
+			}
+			if lookup.driver(variant) == nil {
+				continue
+			}
+			fmt.Fprintf(&b, "case %s:\nreturn &%s%s\n", variant.SensorType, variant.ident(), lookup.suffix)
+		}
+		fmt.Fprintf(&b, "default:\nreturn &%s%s\n", fallback.ident(), lookup.suffix)
+		b.WriteString("}\n}\n")
+	}
+
+	probed := make([]Variant, 0, len(variants))
+	for _, variant := range variants {
+		if variant.DetectionOrder != 0 {
+			probed = append(probed, variant)
+		}
+	}
+	sort.Slice(probed, func(i, j int) bool { return probed[i].DetectionOrder < probed[j].DetectionOrder })
+	b.WriteString("\n// The order Detect probes the variants in.\n")
+	b.WriteString("var detectionOrder = []SensorType{")
+	for i, variant := range probed {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(variant.SensorType)
+	}
+	b.WriteString("}\n")
+
+	source, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return source, nil
+}
+
+func fieldLiteral(f resolvedField) string {
+	return fmt.Sprintf("{%q, 0x%02X, %d, %d, %t}", f.field.Name, f.register.address, f.field.Shift, f.field.Width, f.field.SelfClearing)
+}
+
+// Writes the fields of the roles the driver has, as the members of an
+// accelerometerFields or magnetometerFields literal.
+func writeFields(b *bytes.Buffer, driver *Driver, roles []role) {
+	var axisEnable []string
+	for _, role := range roles {
+		field, ok := driver.fields[role.key]
+		if !ok {
+			continue
+		}
+		// The axes are required and listed together
+		if strings.HasPrefix(role.member, "axisEnable[") {
+			axisEnable = append(axisEnable, fieldLiteral(field))
+			if len(axisEnable) == 3 {
+				fmt.Fprintf(b, "axisEnable: [3]Field{%s},\n", strings.Join(axisEnable, ", "))
+			}
+			continue
+		}
+		fmt.Fprintf(b, "%s: Field%s,\n", role.member, fieldLiteral(field))
+	}
+}
+
+func writeSelfTest(b *bytes.Buffer, selfTest *SelfTest, field resolvedField) {
+	if selfTest.Comment != "" {
+		fmt.Fprintf(b, "// %s\n", selfTest.Comment)
+	}
+	fmt.Fprintf(b, "field: Field%s,\n", fieldLiteral(field))
+	fmt.Fprintf(b, "enable: %s,\n", selfTest.Enable)
+	fmt.Fprintf(b, "settle: %d * time.Millisecond,\n", selfTest.SettleMs)
+	fmt.Fprintf(b, "samples: %d,\n", selfTest.Samples)
+	fmt.Fprintf(b, "min: Vector{%v, %v, %v},\n", selfTest.Min[0], selfTest.Min[1], selfTest.Min[2])
+	fmt.Fprintf(b, "max: Vector{%v, %v, %v},\n", selfTest.Max[0], selfTest.Max[1], selfTest.Max[2])
+}
+
+func writeAccelerometerDriver(b *bytes.Buffer, variant Variant, driver *Driver) {
+	fmt.Fprintf(b, "\nvar %sAccelerometerDriver = accelerometerDriver{\n", variant.ident())
+	b.WriteString("fields: accelerometerFields{\n")
+	writeFields(b, driver, accelerometerRoles)
+	b.WriteString("},\n")
+	fmt.Fprintf(b, "rate100Hz: %s,\n", driver.Rate100Hz)
+	b.WriteString("fullScales: map[AccelerometerRange]uint8{\n")
+	for _, range_ := range accelerometerRanges {
+		if value, ok := driver.FullScales[range_.key]; ok {
+			fmt.Fprintf(b, "%s: %s,\n", range_.constant, value)
+		}
+	}
+	b.WriteString("},\n")
+	b.WriteString("selfTest: selfTestProcedure{\n")
+	writeSelfTest(b, driver.SelfTest, driver.fields["self_test"])
+	b.WriteString("},\n")
+	b.WriteString("}\n")
+}
+
+func writeMagnetometerDriver(b *bytes.Buffer, variant Variant, driver *Driver) {
+	fmt.Fprintf(b, "\nvar %sMagnetometerDriver = magnetometerDriver{\n", variant.ident())
+	b.WriteString("fields: magnetometerFields{\n")
+	writeFields(b, driver, magnetometerRoles)
+	b.WriteString("},\n")
+	rates := make([]string, len(driver.Rates))
+	for i, rate := range driver.Rates {
+		rates[i] = fmt.Sprint(rate)
+	}
+	fmt.Fprintf(b, "rates: []float64{%s},\n", strings.Join(rates, ", "))
+	fmt.Fprintf(b, "defaultRate: %d, // %v Hz\n", driver.defaultRate, driver.DefaultRate)
+	if driver.MgaussPerLsb != 0 {
+		fmt.Fprintf(b, "lsbPerGauss: [][2]float64{{1000 / %v, 1000 / %v}},\n", driver.MgaussPerLsb, driver.MgaussPerLsb)
+	} else {
+		lsb := make([]string, len(driver.LsbPerGauss))
+		for i, xyz := range driver.LsbPerGauss {
+			lsb[i] = fmt.Sprintf("{%v, %v}", xyz[0], xyz[1])
+		}
+		fmt.Fprintf(b, "lsbPerGauss: [][2]float64{%s},\n", strings.Join(lsb, ", "))
+	}
+	if driver.SelfTest != nil {
+		b.WriteString("selfTest: &selfTestProcedure{\n")
+		writeSelfTest(b, driver.SelfTest, driver.fields["self_test"])
+		b.WriteString("},\n")
+	}
+	b.WriteString("}\n")
+}
+
+func writeLookupReturn(b *bytes.Buffer, ident string, copied bool) {
+	if copied {
+		fmt.Fprintf(b, "datasheet := %s\nreturn &datasheet\n", ident)
+	} else {
+		fmt.Fprintf(b, "return %s\n", ident)
+	}
+}
+
+// generateDoc renders the register maps as Markdown tables.
+func generateDoc(variants []Variant) []byte {
+	var b bytes.Buffer
+	b.WriteString("<!-- Code generated by datasheetgen from datasheets/*.json. DO NOT EDIT. -->\n\n")
+	b.WriteString("# LSM303 registers\n\n")
+	b.WriteString("Registers the driver knows about, per variant. Data output registers aren't listed. ")
+	b.WriteString("Bitfields are given most significant first as `name[msb:lsb]`, self-clearing ones are marked with `*`.\n")
+
+	for _, variant := range variants {
+		fmt.Fprintf(&b, "\n## %s\n\n", variant.SensorType)
+		if variant.Description != "" {
+			fmt.Fprintf(&b, "%s\n", variant.Description)
+		}
+		for _, device := range []struct {
+			name string
+			Device
+		}{{"Accelerometer", variant.Accelerometer}, {"Magnetometer", variant.Magnetometer}} {
+			fmt.Fprintf(&b, "\n### %s\n\n", device.name)
+			for _, value := range device.Datasheet {
+				if value.Field == "ADDRESS" {
+					fmt.Fprintf(&b, "I2C address %s.\n\n", value.Value)
+				}
+			}
+			b.WriteString("| Register | Address | Access | Bitfields | Description |\n")
+			b.WriteString("|---|---|---|---|---|\n")
+			for _, register := range device.Registers {
+				access := "r"
+				if register.Writable {
+					access = "rw"
+				}
+				var fields []string
+				for _, field := range register.Fields {
+					name := fmt.Sprintf("%s[%d]", field.Name, field.Shift)
+					if field.Width > 1 {
+						name = fmt.Sprintf("%s[%d:%d]", field.Name, field.Shift+field.Width-1, field.Shift)
+					}
+					if field.SelfClearing {
+						name += "*"
+					}
+					fields = append(fields, "`"+name+"`")
+				}
+				fmt.Fprintf(&b, "| %s | 0x%02X | %s | %s | %s |\n", register.Name, register.address, access, strings.Join(fields, " "), register.Description)
+			}
+		}
+	}
+	return b.Bytes()
+}
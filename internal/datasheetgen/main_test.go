@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The checked in files must match the descriptions, run go generate if this
+// fails.
+func TestGeneratedFilesAreUpToDate(t *testing.T) {
+	variants, err := load("../../datasheets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := generateGo(variants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for file, generated := range map[string][]byte{
+		"../../datasheet_generated.go":  source,
+		"../../datasheets/REGISTERS.md": generateDoc(variants),
+	} {
+		current, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(current, generated) {
+			t.Errorf("%s is out of date, run go generate", file)
+		}
+	}
+}
+
+func TestLoadRejectsBadDescriptions(t *testing.T) {
+	const (
+		register           = `"registers": [{"name": "A", "address": "0x01", "fields": [{"name": "F", "shift": 0, "width": 3}]}]`
+		magnetometerFields = `{"data_rate": "A.F", "temperature": "A.F", "mode": "A.F", "data_ready": "A.F"}`
+	)
+	tests := []struct {
+		name        string
+		description string
+		error       string
+	}{
+		{"unknown key", `{"sensor_type": "X", "default": true, "colour": "red"}`, "unknown field"},
+		{"no sensor type", `{"default": true}`, "sensor_type is missing"},
+		{"bad value", `{"sensor_type": "X", "default": true, "accelerometer": {"datasheet": [{"field": "CHIP_ID", "value": "0xZZ"}]}}`, "CHIP_ID"},
+		{"address order", `{"sensor_type": "X", "default": true, "magnetometer": {"registers": [
+			{"name": "B", "address": "0x02"}, {"name": "A", "address": "0x01"}]}}`, "out of address order"},
+		{"overlap", `{"sensor_type": "X", "default": true, "magnetometer": {"registers": [
+			{"name": "A", "address": "0x01", "fields": [{"name": "F", "shift": 0, "width": 3}, {"name": "G", "shift": 2, "width": 1}]}]}}`, "A.G overlaps"},
+		{"too wide", `{"sensor_type": "X", "default": true, "magnetometer": {"registers": [
+			{"name": "A", "address": "0x01", "fields": [{"name": "F", "shift": 6, "width": 3}]}]}}`, "doesn't fit"},
+		{"no default", `{"sensor_type": "X"}`, "need exactly one"},
+		{"no default driver", `{"sensor_type": "X", "default": true}`, "needs an accelerometer and a magnetometer driver"},
+		{"unknown role", `{"sensor_type": "X", "default": true, "magnetometer": {` + register + `,
+			"driver": {"fields": {"colour": "A.F"}}}}`, "fields.colour isn't a field"},
+		{"missing role", `{"sensor_type": "X", "default": true, "magnetometer": {` + register + `,
+			"driver": {"fields": {"data_rate": "A.F"}}}}`, "fields.temperature is missing"},
+		{"no such field", `{"sensor_type": "X", "default": true, "magnetometer": {` + register + `,
+			"driver": {"fields": {"data_rate": "A.G", "temperature": "A.F", "mode": "A.F", "data_ready": "A.F"}}}}`, "no bitfield A.G"},
+		{"default rate", `{"sensor_type": "X", "default": true, "magnetometer": {` + register + `,
+			"driver": {"fields": ` + magnetometerFields + `, "rates_hz": [1, 2], "default_rate_hz": 3, "mgauss_per_lsb": 1}}}`, "default_rate_hz 3"},
+		{"gain without a table", `{"sensor_type": "X", "default": true, "magnetometer": {` + register + `,
+			"driver": {"fields": ` + strings.Replace(magnetometerFields, "{", `{"gain": "A.F", `, 1) + `, "rates_hz": [1], "default_rate_hz": 1, "mgauss_per_lsb": 1}}}`, "needs lsb_per_gauss"},
+		{"wrong device", `{"sensor_type": "X", "default": true, "magnetometer": {` + register + `,
+			"driver": {"fields": ` + magnetometerFields + `, "rates_hz": [1], "default_rate_hz": 1, "mgauss_per_lsb": 1, "rate_100hz": "1"}}}`, "accelerometer settings"},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "x.json"), []byte(test.description), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := load(dir)
+		if err == nil || !strings.Contains(err.Error(), test.error) {
+			t.Errorf("%s: got error %v, want one containing %q", test.name, err, test.error)
+		}
+	}
+}
+
+func TestGenerateGoFallsBackToDefault(t *testing.T) {
+	// The default needs drivers, borrow the real ones
+	dlhc, err := os.ReadFile("../../datasheets/lsm303dlhc.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for file, description := range map[string]string{
+		"a.json": strings.Replace(string(dlhc), `"LSM303DLHC"`, `"LSM303A"`, 1),
+		"b.json": `{"sensor_type": "LSM303B", "accelerometer": {"datasheet": [{"field": "ADDRESS", "value": "0x19"}]}}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(description), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	variants, err := load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := generateGo(variants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"case LSM303B:\n\t\tdatasheet := lsm303bAccelerometerDatasheet",
+		"default:\n\t\tdatasheet := lsm303aAccelerometerDatasheet",
+		"ADDRESS: 0x19,",
+		"default:\n\t\treturn &lsm303aMagnetometerDriver",
+		"var detectionOrder = []SensorType{LSM303A}",
+	} {
+		if !strings.Contains(string(source), expected) {
+			t.Errorf("expected %q in\n%s", expected, source)
+		}
+	}
+	// B has no driver of its own
+	if strings.Contains(string(source), "lsm303bMagnetometerDriver") {
+		t.Errorf("LSM303B got a driver in\n%s", source)
+	}
+}
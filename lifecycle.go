@@ -0,0 +1,174 @@
+package lsm303
+
+import "time"
+
+// How long the chips take to reload their trimming values after a reboot.
+const bootTime = 10 * time.Millisecond
+
+// Halt puts the accelerometer in power-down mode by clearing the output data
+// rate. Reset brings it back with the previous configuration.
+func (a *Accelerometer) Halt() error {
+	field := a.fields().dataRate
+	value, err := a.mmr.ReadUint8(field.Register)
+	if err != nil {
+		return err
+	}
+	if rate := field.Get(value); rate != 0 {
+		a.dataRate = rate
+	}
+	return a.mmr.WriteUint8(field.Register, field.Set(value, 0))
+}
+
+// Reset reboots the memory content, which restores the factory trimming and
+// register defaults, then re-applies the mode, range and data rate the
+// accelerometer was running at, or ran at before Halt.
+func (a *Accelerometer) Reset() error {
+	rate, err := readField(&a.mmr, a.fields().dataRate)
+	if err != nil {
+		return err
+	}
+	if rate != 0 {
+		a.dataRate = rate
+	}
+	if err := a.reboot(); err != nil {
+		return err
+	}
+	return a.configure()
+}
+
+// Close leaves the accelerometer rebooted and powered down. The bus is left
+// open, it belongs to the caller.
+func (a *Accelerometer) Close() error {
+	if err := a.reboot(); err != nil {
+		return err
+	}
+	return a.Halt()
+}
+
+func (a *Accelerometer) reboot() error {
+	if err := writeField(&a.mmr, a.fields().boot, 1); err != nil {
+		return err
+	}
+	time.Sleep(bootTime)
+	return nil
+}
+
+// Re-applies the configuration NewAccelerometer sets up.
+func (a *Accelerometer) configure() error {
+	if a.dataRate == 0 {
+		a.dataRate = accelerometerDriverFor(a.sensorType).rate100Hz
+	}
+	if err := a.enable(); err != nil {
+		return err
+	}
+	if err := a.SetRange(a.range_); err != nil {
+		return err
+	}
+	return a.SetMode(a.mode)
+}
+
+// Reads the range, mode and data rate the chip runs with into the handle. The
+// data rate of a powered down chip isn't taken, Reset restarts the last one.
+func (a *Accelerometer) readConfiguration() error {
+	range_, err := a.GetRange()
+	if err != nil {
+		return err
+	}
+	mode, err := a.GetMode()
+	if err != nil {
+		return err
+	}
+	rate, err := readField(&a.mmr, a.fields().dataRate)
+	if err != nil {
+		return err
+	}
+	a.range_, a.mode = range_, mode
+	if rate != 0 {
+		a.dataRate = rate
+	}
+	return nil
+}
+
+// Writes the data rate with every axis enabled to the control register, and
+// clears the rest of it for SetMode to fill in.
+func (a *Accelerometer) enable() error {
+	fields := a.fields()
+	value := fields.dataRate.Set(0, a.dataRate)
+	for _, axis := range fields.axisEnable {
+		value = axis.Set(value, 1)
+	}
+	return a.mmr.WriteUint8(fields.dataRate.Register, value)
+}
+
+// Halt puts the magnetometer in sleep (DLHC) or idle/power-down (AGR, C)
+// mode. Reset brings it back with the previous configuration.
+func (m *Magnetometer) Halt() error {
+	return writeField(&m.mmr, m.fields().mode, 0b11)
+}
+
+// Reset reboots the memory content and soft resets the AGR and C
+// magnetometers, then re-applies the gain and data rate the driver was
+// configured with. The DLHC has no reset, its configuration is just written
+// again.
+func (m *Magnetometer) Reset() error {
+	if err := m.reboot(); err != nil {
+		return err
+	}
+	return m.configure()
+}
+
+// Close leaves the magnetometer reset and powered down. The bus is left open,
+// it belongs to the caller.
+func (m *Magnetometer) Close() error {
+	if err := m.reboot(); err != nil {
+		return err
+	}
+	return m.Halt()
+}
+
+func (m *Magnetometer) reboot() error {
+	fields := m.fields()
+	if fields.reboot.Width == 0 {
+		return nil
+	}
+
+	if err := writeField(&m.mmr, fields.reboot, 1); err != nil {
+		return err
+	}
+	time.Sleep(bootTime)
+	if err := writeField(&m.mmr, fields.softReset, 1); err != nil {
+		return err
+	}
+	time.Sleep(bootTime)
+	return nil
+}
+
+// Reads the gain and data rate the chip runs with into the handle.
+func (m *Magnetometer) readConfiguration() error {
+	if m.fields().gain.Width != 0 {
+		gain, err := m.GetGain()
+		if err != nil {
+			return err
+		}
+		m.gain = gain
+	}
+	rate, err := m.GetRate()
+	if err != nil {
+		return err
+	}
+	m.rate = rate
+	return nil
+}
+
+// Re-applies the configuration NewMagnetometer sets up.
+func (m *Magnetometer) configure() error {
+	if err := writeField(&m.mmr, m.fields().mode, 0); err != nil {
+		return err
+	}
+	if m.fields().gain.Width != 0 {
+		if err := m.SetGain(m.gain); err != nil {
+			return err
+		}
+	}
+	return m.SetRate(m.rate)
+}
@@ -0,0 +1,221 @@
+package lsm303
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/mmr"
+)
+
+var (
+	_ conn.Resource = (*Accelerometer)(nil)
+	_ conn.Resource = (*Magnetometer)(nil)
+)
+
+func TestAccelerometerHalt(t *testing.T) {
+	d := accelerometerDatasheet
+	scenario := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A}, R: []byte{0x57}},
+			// Power-down keeps the axes enabled
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A, 0x07}},
+		},
+	}
+	accelerometer := &Accelerometer{
+		mmr:        mmr.Dev8{Conn: &i2c.Dev{Bus: scenario, Addr: d.ADDRESS}, Order: binary.BigEndian},
+		sensorType: LSM303DLHC,
+		datasheet:  d,
+	}
+
+	if err := accelerometer.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccelerometerReset(t *testing.T) {
+	d := accelerometerDatasheet
+	scenario := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			// Running at 10 Hz
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A}, R: []byte{0x27}},
+			// BOOT
+			{Addr: d.ADDRESS, W: []byte{0x24}, R: []byte{0x00}},
+			{Addr: d.ADDRESS, W: []byte{0x24, 0x80}},
+			// Back to 10 Hz, ±8G, low power
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A, 0x27}},
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG4_A}, R: []byte{0}},
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG4_A, 0x20}},
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A}, R: []byte{0x27}},
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A, 0x2F}},
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG4_A}, R: []byte{0x20}},
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG4_A, 0x20}},
+		},
+	}
+	accelerometer := &Accelerometer{
+		mmr:        mmr.Dev8{Conn: &i2c.Dev{Bus: scenario, Addr: d.ADDRESS}, Order: binary.BigEndian},
+		sensorType: LSM303DLHC,
+		datasheet:  d,
+		range_:     ACCELEROMETER_RANGE_8G,
+		mode:       ACCELEROMETER_MODE_LOW_POWER,
+	}
+
+	if err := accelerometer.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccelerometerHaltAndResetLSM303C(t *testing.T) {
+	d := datasheetForAccelerometer(LSM303C)
+	scenario := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			// Halted at 50 Hz with HR and BDU
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A}, R: []byte{0xAF}},
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A, 0x8F}},
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A}, R: []byte{0x8F}},
+			{Addr: d.ADDRESS, W: []byte{0x25}, R: []byte{0x00}},
+			{Addr: d.ADDRESS, W: []byte{0x25, 0x80}},
+			// The 3-bit ODR back at 50 Hz, then ±2G and high resolution
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A, 0x27}},
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG4_A}, R: []byte{0x04}},
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG4_A, 0x04}},
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A}, R: []byte{0x27}},
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A, 0xA7}},
+		},
+	}
+	accelerometer := &Accelerometer{
+		mmr:        mmr.Dev8{Conn: &i2c.Dev{Bus: scenario, Addr: d.ADDRESS}, Order: binary.BigEndian},
+		sensorType: LSM303C,
+		datasheet:  d,
+		range_:     ACCELEROMETER_RANGE_2G,
+		mode:       ACCELEROMETER_MODE_HIGH_RESOLUTION,
+	}
+
+	if err := accelerometer.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if err := accelerometer.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccelerometerCloseLSM303C(t *testing.T) {
+	d := datasheetForAccelerometer(LSM303C)
+	scenario := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: d.ADDRESS, W: []byte{0x25}, R: []byte{0x00}},
+			{Addr: d.ADDRESS, W: []byte{0x25, 0x80}},
+			// HR is left alone
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A}, R: []byte{0x87}},
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A, 0x87}},
+		},
+	}
+	accelerometer := &Accelerometer{
+		mmr:        mmr.Dev8{Conn: &i2c.Dev{Bus: scenario, Addr: d.ADDRESS}, Order: binary.BigEndian},
+		sensorType: LSM303C,
+		datasheet:  d,
+	}
+
+	if err := accelerometer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMagnetometerHalt(t *testing.T) {
+	d := magnetometerDatasheet
+	scenario := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: d.ADDRESS, W: []byte{d.MR_REG_M}, R: []byte{0x00}},
+			{Addr: d.ADDRESS, W: []byte{d.MR_REG_M, 0x03}},
+		},
+	}
+	magnetometer := &Magnetometer{
+		mmr:        mmr.Dev8{Conn: &i2c.Dev{Bus: scenario, Addr: d.ADDRESS}, Order: binary.BigEndian},
+		sensorType: LSM303DLHC,
+		datasheet:  d,
+	}
+
+	if err := magnetometer.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMagnetometerResetAGR(t *testing.T) {
+	d := datasheetForMagnetometer(LSM303AGR)
+	scenario := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			// REBOOT, then SOFT_RST
+			{Addr: d.ADDRESS, W: []byte{d.CRA_REG_M}, R: []byte{0x03}},
+			{Addr: d.ADDRESS, W: []byte{d.CRA_REG_M, 0x43}},
+			{Addr: d.ADDRESS, W: []byte{d.CRA_REG_M}, R: []byte{0x03}},
+			{Addr: d.ADDRESS, W: []byte{d.CRA_REG_M, 0x23}},
+			// Continuous mode, the gain is fixed, then ODR and COMP_TEMP_EN
+			{Addr: d.ADDRESS, W: []byte{d.CRA_REG_M}, R: []byte{0x03}},
+			{Addr: d.ADDRESS, W: []byte{d.CRA_REG_M, 0x00}},
+			{Addr: d.ADDRESS, W: []byte{d.CRA_REG_M}, R: []byte{0x00}},
+			{Addr: d.ADDRESS, W: []byte{d.CRA_REG_M, 0x04}},
+			{Addr: d.ADDRESS, W: []byte{d.CRA_REG_M}, R: []byte{0x04}},
+			{Addr: d.ADDRESS, W: []byte{d.CRA_REG_M, 0x84}},
+		},
+	}
+	magnetometer := &Magnetometer{
+		mmr:        mmr.Dev8{Conn: &i2c.Dev{Bus: scenario, Addr: d.ADDRESS}, Order: binary.BigEndian},
+		sensorType: LSM303AGR,
+		datasheet:  d,
+		gain:       MAGNETOMETER_GAIN_4_0,
+		rate:       magnetometerDriverFor(LSM303AGR).defaultRate,
+	}
+
+	if err := magnetometer.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewAccelerometerCurrentConfiguration(t *testing.T) {
+	// Running at 100 Hz in low power mode at ±8G, nothing is written
+	d := accelerometerDatasheet
+	const (
+		ctrlReg1 = 0b01011111
+		ctrlReg4 = 0b00100000
+	)
+	scenario := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A}, R: []byte{ctrlReg1}},
+			{Addr: d.ADDRESS, W: []byte{d.WHO_AM_I_A}, R: []byte{d.CHIP_ID}},
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG4_A}, R: []byte{ctrlReg4}},
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A}, R: []byte{ctrlReg1}},
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG4_A}, R: []byte{ctrlReg4}},
+			{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A}, R: []byte{ctrlReg1}},
+		},
+	}
+	accelerometer, err := NewAccelerometer(scenario, WithAccelerometerCurrentConfiguration(), WithRange(ACCELEROMETER_RANGE_2G))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accelerometer.range_ != ACCELEROMETER_RANGE_8G || accelerometer.mode != ACCELEROMETER_MODE_LOW_POWER || accelerometer.dataRate != 0b0101 {
+		t.Errorf("range %s, mode %d and rate %04b taken from the chip", accelerometer.range_, accelerometer.mode, accelerometer.dataRate)
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
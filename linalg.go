@@ -0,0 +1,189 @@
+package lsm303
+
+import (
+	"errors"
+	"math"
+)
+
+// Small dense linear algebra helpers used by the calibration code. The
+// problems solved here are tiny (at most 12 unknowns), so clarity wins over
+// speed.
+
+type matrix3 [3][3]float64
+
+func identity3() matrix3 {
+	return matrix3{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+}
+
+func (m matrix3) mulVec(v Vector) Vector {
+	return Vector{
+		m[0][0]*v.X + m[0][1]*v.Y + m[0][2]*v.Z,
+		m[1][0]*v.X + m[1][1]*v.Y + m[1][2]*v.Z,
+		m[2][0]*v.X + m[2][1]*v.Y + m[2][2]*v.Z,
+	}
+}
+
+func (m matrix3) mul(n matrix3) matrix3 {
+	var r matrix3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				r[i][j] += m[i][k] * n[k][j]
+			}
+		}
+	}
+	return r
+}
+
+func (m matrix3) transpose() matrix3 {
+	var r matrix3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			r[i][j] = m[j][i]
+		}
+	}
+	return r
+}
+
+func (m matrix3) det() float64 {
+	return m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+}
+
+func (m matrix3) inverse() (matrix3, error) {
+	d := m.det()
+	if math.Abs(d) < 1e-300 {
+		return matrix3{}, errors.New("singular matrix")
+	}
+	var r matrix3
+	r[0][0] = (m[1][1]*m[2][2] - m[1][2]*m[2][1]) / d
+	r[0][1] = (m[0][2]*m[2][1] - m[0][1]*m[2][2]) / d
+	r[0][2] = (m[0][1]*m[1][2] - m[0][2]*m[1][1]) / d
+	r[1][0] = (m[1][2]*m[2][0] - m[1][0]*m[2][2]) / d
+	r[1][1] = (m[0][0]*m[2][2] - m[0][2]*m[2][0]) / d
+	r[1][2] = (m[0][2]*m[1][0] - m[0][0]*m[1][2]) / d
+	r[2][0] = (m[1][0]*m[2][1] - m[1][1]*m[2][0]) / d
+	r[2][1] = (m[0][1]*m[2][0] - m[0][0]*m[2][1]) / d
+	r[2][2] = (m[0][0]*m[1][1] - m[0][1]*m[1][0]) / d
+	return r, nil
+}
+
+// Eigen decomposition of a symmetric matrix using cyclic Jacobi rotations.
+// Returns the eigenvalues and a matrix whose columns are the eigenvectors.
+func (m matrix3) symmetricEigen() (Vector, matrix3) {
+	a := m
+	v := identity3()
+
+	for sweep := 0; sweep < 50; sweep++ {
+		off := a[0][1]*a[0][1] + a[0][2]*a[0][2] + a[1][2]*a[1][2]
+		if off < 1e-30 {
+			break
+		}
+		for p := 0; p < 2; p++ {
+			for q := p + 1; q < 3; q++ {
+				if a[p][q] == 0 {
+					continue
+				}
+				theta := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := math.Copysign(1, theta) / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+
+				var rotation matrix3 = identity3()
+				rotation[p][p] = c
+				rotation[q][q] = c
+				rotation[p][q] = s
+				rotation[q][p] = -s
+				a = rotation.transpose().mul(a).mul(rotation)
+				v = v.mul(rotation)
+			}
+		}
+	}
+
+	return Vector{a[0][0], a[1][1], a[2][2]}, v
+}
+
+// Square root of a symmetric positive definite matrix.
+func (m matrix3) sqrt() (matrix3, error) {
+	values, vectors := m.symmetricEigen()
+	var d matrix3
+	for i, value := range [3]float64{values.X, values.Y, values.Z} {
+		if value <= 0 {
+			return matrix3{}, errors.New("matrix is not positive definite")
+		}
+		d[i][i] = math.Sqrt(value)
+	}
+	return vectors.mul(d).mul(vectors.transpose()), nil
+}
+
+// Solves the least squares problem rows * x = rhs through the normal
+// equations.
+func leastSquares(rows [][]float64, rhs []float64) ([]float64, error) {
+	if len(rows) == 0 {
+		return nil, errors.New("no equations")
+	}
+	n := len(rows[0])
+	if len(rows) < n {
+		return nil, errors.New("underdetermined system")
+	}
+
+	ata := make([][]float64, n)
+	atb := make([]float64, n)
+	for i := range ata {
+		ata[i] = make([]float64, n)
+	}
+	for r, row := range rows {
+		for i := 0; i < n; i++ {
+			atb[i] += row[i] * rhs[r]
+			for j := 0; j < n; j++ {
+				ata[i][j] += row[i] * row[j]
+			}
+		}
+	}
+
+	return solveLinear(ata, atb)
+}
+
+// Gaussian elimination with partial pivoting. The inputs are modified.
+func solveLinear(a [][]float64, b []float64) ([]float64, error) {
+	n := len(b)
+	scale := 0.0
+	for i := range a {
+		for j := range a[i] {
+			scale = math.Max(scale, math.Abs(a[i][j]))
+		}
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) <= scale*1e-12 {
+			return nil, errors.New("singular system")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+
+		for row := col + 1; row < n; row++ {
+			factor := a[row][col] / a[col][col]
+			for k := col; k < n; k++ {
+				a[row][k] -= factor * a[col][k]
+			}
+			b[row] -= factor * b[col]
+		}
+	}
+
+	x := make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		sum := b[row]
+		for k := row + 1; k < n; k++ {
+			sum -= a[row][k] * x[k]
+		}
+		x[row] = sum / a[row][row]
+	}
+	return x, nil
+}
@@ -0,0 +1,110 @@
+package lsm303
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// GravityEstimator is anything that knows which way gravity points in the
+// sensor frame, e.g. an orientation.Filter fed from the same accelerometer.
+// Gravity returns a unit vector pointing the way the accelerometer reads at
+// rest, i.e. up.
+type GravityEstimator interface {
+	Gravity() Vector
+}
+
+// LinearAcceleration is an accelerometer reading split into the part due to
+// gravity and the part due to motion, both in units of standard gravity.
+type LinearAcceleration struct {
+	Gravity Vector
+	Linear  Vector
+}
+
+// LinearAccelerometer removes gravity from accelerometer readings. By default
+// gravity is tracked with a low-pass filter, which works well for vibration
+// but lags behind when the sensor turns. Use WithGravityEstimator to take it
+// from an orientation filter instead.
+type LinearAccelerometer struct {
+	accelerometer *Accelerometer
+	estimator     GravityEstimator
+	timeConstant  time.Duration
+
+	mu          sync.Mutex
+	gravity     Vector
+	last        time.Time
+	initialized bool
+}
+
+// NewLinearAccelerometer creates a linear accelerometer from an initialized
+// accelerometer.
+func NewLinearAccelerometer(accelerometer *Accelerometer, opts ...LinearAccelerometerOption) (*LinearAccelerometer, error) {
+	if accelerometer == nil {
+		return nil, errors.New("linear accelerometer requires an accelerometer")
+	}
+
+	linear := &LinearAccelerometer{
+		accelerometer: accelerometer,
+		timeConstant:  time.Second,
+	}
+
+	for i := range opts {
+		opts[i].Apply(linear)
+	}
+
+	if linear.estimator == nil && linear.timeConstant <= 0 {
+		return nil, errors.New("gravity time constant must be positive")
+	}
+
+	return linear, nil
+}
+
+// Sense reads the accelerometer and splits the reading into gravity and
+// motion.
+func (l *LinearAccelerometer) Sense() (LinearAcceleration, error) {
+	acceleration, err := l.accelerometer.senseCalibratedVector()
+	if err != nil {
+		return LinearAcceleration{}, err
+	}
+	return l.Update(acceleration, time.Now()), nil
+}
+
+// Update splits an accelerometer reading (in g) taken at the given time, for
+// when samples come from somewhere other than Sense.
+func (l *LinearAccelerometer) Update(acceleration Vector, at time.Time) LinearAcceleration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.estimator != nil {
+		l.gravity = l.estimator.Gravity()
+	} else if !l.initialized {
+		l.gravity = acceleration
+	} else if dt := at.Sub(l.last); dt > 0 {
+		alpha := float64(dt) / float64(l.timeConstant+dt)
+		l.gravity = l.gravity.Add(acceleration.Sub(l.gravity).Scale(alpha))
+	}
+	l.last = at
+	l.initialized = true
+
+	return LinearAcceleration{
+		Gravity: l.gravity,
+		Linear:  acceleration.Sub(l.gravity),
+	}
+}
+
+// Gravity returns the current gravity estimate in g, pointing up.
+func (l *LinearAccelerometer) Gravity() Vector {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.gravity
+}
+
+// Reset forgets the low-pass gravity estimate, the next reading starts it
+// over.
+func (l *LinearAccelerometer) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.gravity = Vector{}
+	l.last = time.Time{}
+	l.initialized = false
+}
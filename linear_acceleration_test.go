@@ -0,0 +1,76 @@
+package lsm303
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+type fixedGravity Vector
+
+func (g fixedGravity) Gravity() Vector {
+	return Vector(g)
+}
+
+func TestLinearAccelerationLowPass(t *testing.T) {
+	linear, err := NewLinearAccelerometer(playbackAccelerometer(), WithGravityTimeConstant(500*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 g up plus a 0.2 g, 10 Hz vibration along X, sampled at 200 Hz
+	start := time.Unix(0, 0)
+	var result LinearAcceleration
+	var worst float64
+	for i := 0; i < 2000; i++ {
+		elapsed := float64(i) / 200
+		vibration := Vector{X: 0.2 * math.Sin(2*math.Pi*10*elapsed)}
+		result = linear.Update(Vector{Z: 1}.Add(vibration), start.Add(time.Duration(i)*5*time.Millisecond))
+		if i > 1000 {
+			worst = math.Max(worst, result.Linear.Sub(vibration).Norm())
+		}
+	}
+	if result.Gravity.Sub(Vector{Z: 1}).Norm() > 0.01 {
+		t.Errorf("expected gravity straight up, got %+v", result.Gravity)
+	}
+	if worst > 0.01 {
+		t.Errorf("linear acceleration off by up to %v g", worst)
+	}
+}
+
+func TestLinearAccelerationEstimator(t *testing.T) {
+	linear, err := NewLinearAccelerometer(playbackAccelerometer(), WithGravityEstimator(fixedGravity{Y: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := linear.Update(Vector{0.5, 1, 0}, time.Now())
+	if result.Gravity != (Vector{Y: 1}) || result.Linear != (Vector{X: 0.5}) {
+		t.Fatalf("unexpected split %+v", result)
+	}
+}
+
+func TestLinearAccelerationSense(t *testing.T) {
+	// 16000 is 1 g at ±2G in normal mode, the first reading is all gravity
+	linear, err := NewLinearAccelerometer(playbackAccelerometer(Vector{0, 0, 16000}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := linear.Sense()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Linear.Norm() > 1e-9 || math.Abs(result.Gravity.Z-1) > 0.05 {
+		t.Fatalf("unexpected split %+v", result)
+	}
+}
+
+func TestLinearAccelerationOptions(t *testing.T) {
+	if _, err := NewLinearAccelerometer(nil); err == nil {
+		t.Error("expected an error without an accelerometer")
+	}
+	if _, err := NewLinearAccelerometer(playbackAccelerometer(), WithGravityTimeConstant(0)); err == nil {
+		t.Error("expected an error for a zero time constant")
+	}
+}
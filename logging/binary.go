@@ -0,0 +1,165 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+)
+
+// The binary format is the magic, the header as JSON prefixed with its length,
+// then the records, each prefixed with its length so readers can skip kinds
+// they don't know. Every kind starts with the time:
+//
+//	"LSM3" 0x01 | uvarint length | header JSON
+//	uvarint length | kind byte | varint time | 3 × float32 little endian
+//
+// The time of the first record is in nanoseconds since the Unix epoch, the
+// others are relative to the record before. float32 keeps about 7 digits,
+// well above the resolution of the sensor.
+var binaryMagic = []byte{'L', 'S', 'M', '3', 0x01}
+
+const (
+	binaryAccel = 1
+	binaryMag   = 2
+)
+
+type binaryEncoder struct {
+	w        *bufio.Writer
+	previous int64
+	record   []byte
+}
+
+// NewBinaryWriter writes the header to w and returns a Writer for the samples.
+func NewBinaryWriter(w io.Writer, header Header) (Writer, error) {
+	header.Version = version
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	encoder := &binaryEncoder{w: bufio.NewWriter(w)}
+	encoder.w.Write(binaryMagic)
+	encoder.w.Write(binary.AppendUvarint(nil, uint64(len(encoded))))
+	encoder.w.Write(encoded)
+	return writer{encoder}, nil
+}
+
+func (e *binaryEncoder) encode(kind string, at time.Time, v lsm303.Vector) error {
+	code := uint8(binaryAccel)
+	if kind == magKind {
+		code = binaryMag
+	}
+	now := at.UnixNano()
+
+	e.record = append(e.record[:0], code)
+	e.record = binary.AppendVarint(e.record, now-e.previous)
+	for _, value := range [3]float64{v.X, v.Y, v.Z} {
+		e.record = binary.LittleEndian.AppendUint32(e.record, math.Float32bits(float32(value)))
+	}
+	e.previous = now
+
+	e.w.Write(binary.AppendUvarint(nil, uint64(len(e.record))))
+	_, err := e.w.Write(e.record)
+	return err
+}
+
+func (e *binaryEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+type binaryReader struct {
+	header   Header
+	r        *bufio.Reader
+	previous int64
+	record   []byte
+}
+
+// NewBinaryReader reads the header from r and returns a Reader for the
+// samples.
+func NewBinaryReader(r io.Reader) (Reader, error) {
+	reader := &binaryReader{r: bufio.NewReader(r)}
+	magic := make([]byte, len(binaryMagic))
+	if _, err := io.ReadFull(reader.r, magic); err != nil || !bytes.Equal(magic, binaryMagic) {
+		return nil, errors.New("not a binary LSM303 log")
+	}
+	encoded, err := reader.next()
+	if err != nil {
+		return nil, fmt.Errorf("log header: %w", err)
+	}
+	var header Header
+	if err := json.Unmarshal(encoded, &header); err != nil {
+		return nil, fmt.Errorf("log header: %w", err)
+	}
+	if reader.header, err = checkHeader(header); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+func (r *binaryReader) Header() Header {
+	return r.header
+}
+
+func (r *binaryReader) Read() (Record, error) {
+	for {
+		block, err := r.next()
+		if err != nil {
+			return Record{}, err
+		}
+		// Every kind starts with the time, so the ones skipped still count
+		delta, n := binary.Varint(block[min(len(block), 1):])
+		if n <= 0 {
+			return Record{}, errors.New("truncated log record")
+		}
+		r.previous += delta
+
+		var kind string
+		switch block[0] {
+		case binaryAccel:
+			kind = accelKind
+		case binaryMag:
+			kind = magKind
+		default:
+			// A kind added later
+			continue
+		}
+		if len(block) < 1+n+12 {
+			return Record{}, errors.New("truncated log record")
+		}
+		var values [3]float64
+		for i := range values {
+			values[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(block[1+n+4*i:])))
+		}
+		return record(kind, time.Unix(0, r.previous).UTC(), lsm303.Vector{X: values[0], Y: values[1], Z: values[2]})
+	}
+}
+
+// next reads one length prefixed block. A stream ending between records is
+// io.EOF, in the middle of one io.ErrUnexpectedEOF.
+func (r *binaryReader) next() ([]byte, error) {
+	length, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, err
+	}
+	if length > 1<<20 {
+		return nil, fmt.Errorf("log record of %d bytes", length)
+	}
+	if cap(r.record) < int(length) {
+		r.record = make([]byte, length)
+	}
+	r.record = r.record[:length]
+	if _, err := io.ReadFull(r.r, r.record); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return r.record, nil
+}
@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+)
+
+// The CSV format starts with the header as JSON in a comment line, then the
+// column names:
+//
+//	# {"version":1,"sensor_type":"LSM303DLHC",...}
+//	type,time,x,y,z
+//	accel,2024-05-01T10:00:00.01Z,0.012,-0.003,0.998
+//	mag,2024-05-01T10:00:00.01Z,0.21,-0.05,-0.42
+var csvColumns = []string{"type", "time", "x", "y", "z"}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+// NewCSVWriter writes the header to w and returns a Writer for the samples.
+func NewCSVWriter(w io.Writer, header Header) (Writer, error) {
+	header.Version = version
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(w, "# %s\n", encoded); err != nil {
+		return nil, err
+	}
+	encoder := &csvEncoder{w: csv.NewWriter(w)}
+	if err := encoder.w.Write(csvColumns); err != nil {
+		return nil, err
+	}
+	return writer{encoder}, nil
+}
+
+func (e *csvEncoder) encode(kind string, at time.Time, v lsm303.Vector) error {
+	return e.w.Write([]string{
+		kind,
+		at.UTC().Format(time.RFC3339Nano),
+		strconv.FormatFloat(v.X, 'g', -1, 64),
+		strconv.FormatFloat(v.Y, 'g', -1, 64),
+		strconv.FormatFloat(v.Z, 'g', -1, 64),
+	})
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type csvReader struct {
+	header Header
+	r      *csv.Reader
+}
+
+// NewCSVReader reads the header from r and returns a Reader for the samples.
+func NewCSVReader(r io.Reader) (Reader, error) {
+	buffered := bufio.NewReader(r)
+	line, err := buffered.ReadString('\n')
+	if err != nil && line == "" {
+		return nil, fmt.Errorf("log header: %w", err)
+	}
+	if !strings.HasPrefix(line, "# ") {
+		return nil, fmt.Errorf("log doesn't start with a header")
+	}
+	var header Header
+	if err := json.Unmarshal([]byte(line[2:]), &header); err != nil {
+		return nil, fmt.Errorf("log header: %w", err)
+	}
+	if header, err = checkHeader(header); err != nil {
+		return nil, err
+	}
+
+	reader := &csvReader{header: header, r: csv.NewReader(buffered)}
+	reader.r.FieldsPerRecord = len(csvColumns)
+	columns, err := reader.r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("log columns: %w", err)
+	}
+	if strings.Join(columns, ",") != strings.Join(csvColumns, ",") {
+		return nil, fmt.Errorf("unexpected log columns %q", columns)
+	}
+	return reader, nil
+}
+
+func (r *csvReader) Header() Header {
+	return r.header
+}
+
+func (r *csvReader) Read() (Record, error) {
+	fields, err := r.r.Read()
+	if err != nil {
+		return Record{}, err
+	}
+	at, err := time.Parse(time.RFC3339Nano, fields[1])
+	if err != nil {
+		return Record{}, err
+	}
+	var values [3]float64
+	for i := range values {
+		if values[i], err = strconv.ParseFloat(fields[2+i], 64); err != nil {
+			return Record{}, err
+		}
+	}
+	return record(fields[0], at, lsm303.Vector{X: values[0], Y: values[1], Z: values[2]})
+}
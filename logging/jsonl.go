@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+)
+
+// The JSON Lines format has the header on the first line and a sample on each
+// of the following ones:
+//
+//	{"version":1,"sensor_type":"LSM303DLHC",...}
+//	{"type":"accel","time":"2024-05-01T10:00:00.01Z","x":0.012,"y":-0.003,"z":0.998}
+type jsonRecord struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	lsm303.Vector
+}
+
+type jsonEncoder struct {
+	w       *bufio.Writer
+	encoder *json.Encoder
+}
+
+// NewJSONLinesWriter writes the header to w and returns a Writer for the
+// samples.
+func NewJSONLinesWriter(w io.Writer, header Header) (Writer, error) {
+	header.Version = version
+	buffered := bufio.NewWriter(w)
+	encoder := &jsonEncoder{w: buffered, encoder: json.NewEncoder(buffered)}
+	if err := encoder.encoder.Encode(header); err != nil {
+		return nil, err
+	}
+	return writer{encoder}, nil
+}
+
+func (e *jsonEncoder) encode(kind string, at time.Time, v lsm303.Vector) error {
+	return e.encoder.Encode(jsonRecord{Type: kind, Time: at.UTC(), Vector: v})
+}
+
+func (e *jsonEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+type jsonReader struct {
+	header  Header
+	decoder *json.Decoder
+}
+
+// NewJSONLinesReader reads the header from r and returns a Reader for the
+// samples.
+func NewJSONLinesReader(r io.Reader) (Reader, error) {
+	reader := &jsonReader{decoder: json.NewDecoder(r)}
+	var header Header
+	if err := reader.decoder.Decode(&header); err != nil {
+		return nil, fmt.Errorf("log header: %w", err)
+	}
+	var err error
+	if reader.header, err = checkHeader(header); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+func (r *jsonReader) Header() Header {
+	return r.header
+}
+
+func (r *jsonReader) Read() (Record, error) {
+	var line jsonRecord
+	if err := r.decoder.Decode(&line); err != nil {
+		return Record{}, err
+	}
+	return record(line.Type, line.Time, line.Vector)
+}
@@ -0,0 +1,206 @@
+// Package logging writes accelerometer and magnetometer samples to files for
+// later analysis, and reads them back.
+//
+// Three formats are supported: CSV and JSON Lines, which are easy to load in
+// a spreadsheet or a notebook, and a compact binary format for long captures
+// on small devices. All of them start with a Header describing how the sensor
+// was configured and calibrated, and hold one sample per record.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+)
+
+// The current header version. Bump it whenever the meaning of a field changes,
+// and keep reading older versions.
+const version = 1
+
+type Format int
+
+const (
+	FORMAT_CSV Format = iota
+	FORMAT_JSON_LINES
+	FORMAT_BINARY
+)
+
+func (format Format) String() string {
+	return [...]string{"CSV", "JSON Lines", "binary"}[format]
+}
+
+// Header describes the sensor a log was taken from.
+type Header struct {
+	Version    int                       `json:"version"`
+	SensorType lsm303.SensorType         `json:"sensor_type"`
+	Range      lsm303.AccelerometerRange `json:"accelerometer_range"`
+	Mode       lsm303.AccelerometerMode  `json:"accelerometer_mode"`
+	Gain       lsm303.MagnetometerGain   `json:"magnetometer_gain"`
+	Rate       lsm303.MagnetometerRate   `json:"magnetometer_rate"`
+	// The calibration applied to the samples, if any
+	Calibration *lsm303.CalibrationProfile `json:"calibration,omitempty"`
+	Started     time.Time                  `json:"started"`
+}
+
+// HeaderFor describes the given sensors, either of them can be nil. The mode
+// and rate are read from the chip.
+func HeaderFor(accelerometer *lsm303.Accelerometer, magnetometer *lsm303.Magnetometer) (Header, error) {
+	profile, err := lsm303.NewCalibrationProfile(accelerometer, magnetometer)
+	if err != nil {
+		return Header{}, err
+	}
+	header := Header{
+		Version:    version,
+		SensorType: profile.SensorType,
+		Range:      profile.AccelerometerRange,
+		Gain:       profile.MagnetometerGain,
+		Started:    profile.Created,
+	}
+	if profile.Accelerometer != nil || profile.Magnetometer != nil {
+		header.Calibration = profile
+	}
+
+	if accelerometer != nil {
+		if header.Mode, err = accelerometer.GetMode(); err != nil {
+			return Header{}, err
+		}
+	}
+	if magnetometer != nil {
+		if header.Rate, err = magnetometer.GetRate(); err != nil {
+			return Header{}, err
+		}
+	}
+	return header, nil
+}
+
+func checkHeader(header Header) (Header, error) {
+	if header.Version < 1 || header.Version > version {
+		return Header{}, fmt.Errorf("unsupported log version %d", header.Version)
+	}
+	return header, nil
+}
+
+// Record is one logged sample, either Accel or Mag is set.
+type Record struct {
+	Accel *lsm303.AccelSample
+	Mag   *lsm303.MagSample
+}
+
+// Writer writes samples in one of the formats.
+type Writer interface {
+	WriteAccel(sample lsm303.AccelSample) error
+	WriteMag(sample lsm303.MagSample) error
+	// Flush writes out anything buffered. The underlying writer is left
+	// open, it belongs to the caller.
+	Flush() error
+}
+
+// Reader reads back what a Writer wrote.
+type Reader interface {
+	Header() Header
+	// Read returns the next record, and io.EOF after the last one.
+	Read() (Record, error)
+}
+
+// The record kinds, as written in the text formats.
+const (
+	accelKind = "accel"
+	magKind   = "mag"
+)
+
+// sampleEncoder is what a format implements, the samples are passed on as
+// a kind, a time and a vector.
+type sampleEncoder interface {
+	encode(kind string, at time.Time, v lsm303.Vector) error
+	Flush() error
+}
+
+type writer struct {
+	sampleEncoder
+}
+
+func (w writer) WriteAccel(sample lsm303.AccelSample) error {
+	return w.encode(accelKind, sample.Time, sample.Acceleration)
+}
+
+func (w writer) WriteMag(sample lsm303.MagSample) error {
+	return w.encode(magKind, sample.Time, sample.MagneticField)
+}
+
+func record(kind string, at time.Time, v lsm303.Vector) (Record, error) {
+	switch kind {
+	case accelKind:
+		return Record{Accel: &lsm303.AccelSample{Time: at, Acceleration: v}}, nil
+	case magKind:
+		return Record{Mag: &lsm303.MagSample{Time: at, MagneticField: v}}, nil
+	}
+	return Record{}, fmt.Errorf("unknown record type %q", kind)
+}
+
+// Capture reads the given sensors every interval and writes the samples to w
+// until the context is done. Either sensor can be nil. w is flushed before
+// returning.
+func Capture(ctx context.Context, w Writer, accelerometer *lsm303.Accelerometer, magnetometer *lsm303.Magnetometer, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if accelerometer != nil {
+			sample, err := accelerometer.SenseSample()
+			if err == nil {
+				err = w.WriteAccel(sample)
+			}
+			if err != nil {
+				w.Flush()
+				return err
+			}
+		}
+		if magnetometer != nil {
+			sample, err := magnetometer.SenseSample()
+			if err == nil {
+				err = w.WriteMag(sample)
+			}
+			if err != nil {
+				w.Flush()
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return w.Flush()
+		case <-ticker.C:
+		}
+	}
+}
+
+// NewWriter writes the header to w in the given format and returns a Writer
+// for the samples.
+func NewWriter(w io.Writer, format Format, header Header) (Writer, error) {
+	switch format {
+	case FORMAT_CSV:
+		return NewCSVWriter(w, header)
+	case FORMAT_JSON_LINES:
+		return NewJSONLinesWriter(w, header)
+	case FORMAT_BINARY:
+		return NewBinaryWriter(w, header)
+	}
+	return nil, fmt.Errorf("unknown log format %d", format)
+}
+
+// NewReader reads the header from r in the given format and returns a Reader
+// for the samples.
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	switch format {
+	case FORMAT_CSV:
+		return NewCSVReader(r)
+	case FORMAT_JSON_LINES:
+		return NewJSONLinesReader(r)
+	case FORMAT_BINARY:
+		return NewBinaryReader(r)
+	}
+	return nil, fmt.Errorf("unknown log format %d", format)
+}
@@ -0,0 +1,243 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+	"github.com/timoth-y/go-lsm303/simulator"
+)
+
+var started = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+var header = Header{
+	SensorType: lsm303.LSM303DLHC,
+	Range:      lsm303.ACCELEROMETER_RANGE_8G,
+	Mode:       lsm303.ACCELEROMETER_MODE_HIGH_RESOLUTION,
+	Gain:       lsm303.MAGNETOMETER_GAIN_1_9,
+	Rate:       lsm303.MAGNETOMETER_RATE_75,
+	Calibration: &lsm303.CalibrationProfile{
+		Version:    1,
+		SensorType: lsm303.LSM303DLHC,
+		Serial:     "board-7",
+		Magnetometer: &lsm303.MagnetometerCalibration{
+			Offset:   lsm303.Vector{X: 0.1},
+			SoftIron: [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}},
+		},
+	},
+	Started: started,
+}
+
+var records = []Record{
+	{Accel: &lsm303.AccelSample{Time: started.Add(10 * time.Millisecond), Acceleration: lsm303.Vector{X: 0.012, Y: -0.003, Z: 0.998}}},
+	{Mag: &lsm303.MagSample{Time: started.Add(10 * time.Millisecond), MagneticField: lsm303.Vector{X: 0.21, Y: -0.05, Z: -0.42}}},
+	{Accel: &lsm303.AccelSample{Time: started.Add(20*time.Millisecond + 1), Acceleration: lsm303.Vector{X: -1.5, Y: 7.25, Z: 0}}},
+}
+
+func write(t *testing.T, format Format) []byte {
+	var log bytes.Buffer
+	w, err := NewWriter(&log, format, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if r.Accel != nil {
+			err = w.WriteAccel(*r.Accel)
+		} else {
+			err = w.WriteMag(*r.Mag)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return log.Bytes()
+}
+
+func readAll(t *testing.T, r Reader) []Record {
+	var read []Record
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return read
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		read = append(read, record)
+	}
+}
+
+func near(a, b lsm303.Vector, tolerance float64) bool {
+	return math.Abs(a.X-b.X) <= tolerance && math.Abs(a.Y-b.Y) <= tolerance && math.Abs(a.Z-b.Z) <= tolerance
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []Format{FORMAT_CSV, FORMAT_JSON_LINES, FORMAT_BINARY} {
+		log := write(t, format)
+		r, err := NewReader(bytes.NewReader(log), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		read := r.Header()
+		if read.Version != version || read.SensorType != header.SensorType || read.Range != header.Range ||
+			read.Mode != header.Mode || read.Gain != header.Gain || read.Rate != header.Rate || !read.Started.Equal(started) {
+			t.Errorf("%s: header %+v", format, read)
+		}
+		if read.Calibration == nil || read.Calibration.Serial != "board-7" || read.Calibration.Magnetometer.Offset.X != 0.1 {
+			t.Errorf("%s: calibration %+v", format, read.Calibration)
+		}
+
+		// Only the binary format rounds to float32
+		tolerance := 0.0
+		if format == FORMAT_BINARY {
+			tolerance = 1e-6
+		}
+		all := readAll(t, r)
+		if len(all) != len(records) {
+			t.Fatalf("%s: read %d records, want %d", format, len(all), len(records))
+		}
+		for i, record := range all {
+			expected := records[i]
+			switch {
+			case expected.Accel != nil:
+				if record.Accel == nil || !record.Accel.Time.Equal(expected.Accel.Time) || !near(record.Accel.Acceleration, expected.Accel.Acceleration, tolerance) {
+					t.Errorf("%s: record %d is %+v, want %+v", format, i, record.Accel, expected.Accel)
+				}
+			case expected.Mag != nil:
+				if record.Mag == nil || !record.Mag.Time.Equal(expected.Mag.Time) || !near(record.Mag.MagneticField, expected.Mag.MagneticField, tolerance) {
+					t.Errorf("%s: record %d is %+v, want %+v", format, i, record.Mag, expected.Mag)
+				}
+			}
+		}
+	}
+}
+
+func TestTextFormats(t *testing.T) {
+	csv := string(write(t, FORMAT_CSV))
+	if !strings.HasPrefix(csv, `# {"version":1,"sensor_type":"LSM303DLHC","accelerometer_range":"8G"`) ||
+		!strings.Contains(csv, "\ntype,time,x,y,z\naccel,2024-05-01T10:00:00.01Z,0.012,-0.003,0.998\n") {
+		t.Errorf("unexpected CSV:\n%s", csv)
+	}
+
+	jsonLines := string(write(t, FORMAT_JSON_LINES))
+	if !strings.Contains(jsonLines, "\n"+`{"type":"mag","time":"2024-05-01T10:00:00.01Z","x":0.21,"y":-0.05,"z":-0.42}`+"\n") {
+		t.Errorf("unexpected JSON Lines:\n%s", jsonLines)
+	}
+}
+
+func TestBinaryIsCompact(t *testing.T) {
+	log, csv := write(t, FORMAT_BINARY), write(t, FORMAT_CSV)
+	headerLength := strings.Index(string(csv), "\n")
+	// The header is the same JSON, the records are a length, a kind, a time
+	// delta and three floats
+	if records := len(log) - len(binaryMagic) - 2 - (headerLength - 2); records > 3*(1+1+9+12) {
+		t.Errorf("%d bytes for 3 records", records)
+	}
+}
+
+func TestBinarySkipsUnknownRecords(t *testing.T) {
+	var log bytes.Buffer
+	w, err := NewBinaryWriter(&log, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteAccel(*records[0].Accel)
+	// A kind 9 record with only a time, 5ms later
+	e := w.(writer).sampleEncoder.(*binaryEncoder)
+	block := binary.AppendVarint([]byte{9}, int64(5*time.Millisecond))
+	e.w.Write(append([]byte{byte(len(block))}, block...))
+	e.previous += int64(5 * time.Millisecond)
+	w.WriteAccel(*records[2].Accel)
+	w.Flush()
+
+	r, err := NewBinaryReader(&log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := readAll(t, r)
+	if len(all) != 2 || !all[1].Accel.Time.Equal(records[2].Accel.Time) {
+		t.Errorf("read %+v", all)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	log := write(t, FORMAT_BINARY)
+	r, err := NewBinaryReader(bytes.NewReader(log[:len(log)-3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Read()
+	r.Read()
+	if _, err := r.Read(); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v reading a truncated record, want io.ErrUnexpectedEOF", err)
+	}
+
+	for format, log := range map[Format]string{
+		FORMAT_CSV:        `# {"version":2}` + "\ntype,time,x,y,z\n",
+		FORMAT_JSON_LINES: `{"version":0}`,
+		FORMAT_BINARY:     "LSM2",
+	} {
+		if _, err := NewReader(strings.NewReader(log), format); err == nil {
+			t.Errorf("%s: read %q", format, log)
+		}
+	}
+}
+
+func TestCapture(t *testing.T) {
+	bus, err := simulator.New(lsm303.LSM303C, simulator.Static(simulator.Level))
+	if err != nil {
+		t.Fatal(err)
+	}
+	accelerometer, err := lsm303.NewAccelerometer(bus, lsm303.WithAccelerometerSensorType(lsm303.LSM303C))
+	if err != nil {
+		t.Fatal(err)
+	}
+	magnetometer, err := lsm303.NewMagnetometer(bus, lsm303.WithMagnetometerSensorType(lsm303.LSM303C))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := HeaderFor(accelerometer, magnetometer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.SensorType != lsm303.LSM303C || header.Range != lsm303.ACCELEROMETER_RANGE_4G || header.Calibration != nil {
+		t.Errorf("unexpected header %+v", header)
+	}
+
+	var log bytes.Buffer
+	w, err := NewJSONLinesWriter(&log, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Millisecond)
+	defer cancel()
+	if err := Capture(ctx, w, accelerometer, magnetometer, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewJSONLinesReader(&log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := readAll(t, r)
+	// At 0, 50 and 100ms, a pair each
+	if len(all) < 4 || len(all)%2 != 0 {
+		t.Fatalf("captured %d records, want 6", len(all))
+	}
+	if accel := all[len(all)-2].Accel; accel == nil || !near(accel.Acceleration, simulator.Level.Acceleration, 0.01) {
+		t.Errorf("unexpected sample %+v", accel)
+	}
+	if mag := all[len(all)-1].Mag; mag == nil || !near(mag.MagneticField, simulator.Level.MagneticField, 0.01) {
+		t.Errorf("unexpected sample %+v", mag)
+	}
+}
@@ -0,0 +1,327 @@
+package lsm303
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+)
+
+// The coefficients published by NOAA/BGS for the World Magnetic Model, one
+// file per five-year release. A new release goes in models/ and is picked up
+// for the dates it covers. Another model can be loaded with
+// ParseMagneticModel and passed to the compass with WithMagneticModel.
+//
+//go:embed models/*.COF
+var embeddedModelFiles embed.FS
+
+var (
+	embeddedModelsOnce sync.Once
+	embeddedModels     []*MagneticModel
+)
+
+// MagneticModel is a spherical harmonic model of the Earth's main magnetic
+// field, as published in the World Magnetic Model .COF format.
+type MagneticModel struct {
+	Name  string
+	Epoch float64
+	// The model is released for five years after its epoch. Past that,
+	// Evaluate extrapolates its secular variation and the error grows until
+	// the next release replaces it.
+	ValidUntil float64
+
+	degree int
+	// Gauss coefficients and their secular variation, indexed [n][m], in nT
+	// and nT/year.
+	g, h, gDot, hDot [][]float64
+}
+
+// MagneticElements is the expected field at a point, as computed by
+// MagneticModel.Evaluate. Angles are in degrees, field components in nT.
+type MagneticElements struct {
+	// Angle between true and magnetic north, positive east.
+	Declination float64
+	// Angle of the field below the horizontal plane, positive down.
+	Inclination float64
+	// Total field strength.
+	Intensity  float64
+	Horizontal float64
+	North      float64
+	East       float64
+	Down       float64
+}
+
+// DefaultMagneticModel returns the newest World Magnetic Model embedded in the
+// package. MagneticModelFor picks the one for a date instead.
+func DefaultMagneticModel() *MagneticModel {
+	models := embeddedMagneticModels()
+	return models[len(models)-1]
+}
+
+// MagneticModelFor returns the embedded World Magnetic Model released for the
+// given date. Past the newest release it returns that one, extrapolated until
+// the next release is added to models/.
+func MagneticModelFor(date time.Time) (*MagneticModel, error) {
+	return selectMagneticModel(embeddedMagneticModels(), date)
+}
+
+// Picks the newest of the models, sorted oldest first, whose epoch is before
+// the date.
+func selectMagneticModel(models []*MagneticModel, date time.Time) (*MagneticModel, error) {
+	year := decimalYear(date)
+	for i := len(models) - 1; i >= 0; i-- {
+		if year >= models[i].Epoch {
+			return models[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no embedded magnetic model covers %.2f, the oldest starts at %.1f", year, models[0].Epoch)
+}
+
+// Parses the embedded models once, oldest first.
+func embeddedMagneticModels() []*MagneticModel {
+	embeddedModelsOnce.Do(func() {
+		files, err := fs.Glob(embeddedModelFiles, "models/*.COF")
+		if err != nil || len(files) == 0 {
+			panic(fmt.Sprintf("no embedded magnetic model: %v", err))
+		}
+		for _, name := range files {
+			file, err := embeddedModelFiles.Open(name)
+			if err != nil {
+				panic(err)
+			}
+			model, err := ParseMagneticModel(file)
+			file.Close()
+			if err != nil {
+				panic(fmt.Sprintf("embedded magnetic model %s is invalid: %v", name, err))
+			}
+			embeddedModels = append(embeddedModels, model)
+		}
+		sort.Slice(embeddedModels, func(i, j int) bool {
+			return embeddedModels[i].Epoch < embeddedModels[j].Epoch
+		})
+	})
+	return embeddedModels
+}
+
+// ParseMagneticModel reads a World Magnetic Model coefficient file (WMM.COF).
+func ParseMagneticModel(r io.Reader) (*MagneticModel, error) {
+	model := &MagneticModel{}
+	scanner := bufio.NewScanner(r)
+
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("magnetic model: missing header")
+	}
+	header := strings.Fields(scanner.Text())
+	if len(header) < 2 {
+		return nil, fmt.Errorf("magnetic model: malformed header %q", scanner.Text())
+	}
+	epoch, err := strconv.ParseFloat(header[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("magnetic model: bad epoch: %v", err)
+	}
+	model.Name = header[1]
+	model.Epoch = epoch
+	model.ValidUntil = epoch + 5
+
+	type term struct {
+		n, m             int
+		g, h, gDot, hDot float64
+	}
+	var terms []term
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "9999") {
+			break
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("magnetic model: malformed line %q", line)
+		}
+		var values [6]float64
+		for i := range values {
+			if values[i], err = strconv.ParseFloat(fields[i], 64); err != nil {
+				return nil, fmt.Errorf("magnetic model: malformed line %q: %v", line, err)
+			}
+		}
+		n, m := int(values[0]), int(values[1])
+		if n < 1 || m < 0 || m > n {
+			return nil, fmt.Errorf("magnetic model: bad degree/order in line %q", line)
+		}
+		terms = append(terms, term{n, m, values[2], values[3], values[4], values[5]})
+		if n > model.degree {
+			model.degree = n
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if model.degree == 0 {
+		return nil, fmt.Errorf("magnetic model: no coefficients")
+	}
+
+	model.g = triangle(model.degree)
+	model.h = triangle(model.degree)
+	model.gDot = triangle(model.degree)
+	model.hDot = triangle(model.degree)
+	for _, t := range terms {
+		model.g[t.n][t.m] = t.g
+		model.h[t.n][t.m] = t.h
+		model.gDot[t.n][t.m] = t.gDot
+		model.hDot[t.n][t.m] = t.hDot
+	}
+
+	return model, nil
+}
+
+// Evaluate computes the expected magnetic field at the given geodetic position
+// (degrees, height above the WGS84 ellipsoid) and date. Dates past ValidUntil
+// are extrapolated.
+func (model *MagneticModel) Evaluate(latitude, longitude float64, altitude physic.Distance, date time.Time) (MagneticElements, error) {
+	if latitude < -90 || latitude > 90 {
+		return MagneticElements{}, fmt.Errorf("latitude %v out of range", latitude)
+	}
+	year := decimalYear(date)
+	if year < model.Epoch {
+		return MagneticElements{}, fmt.Errorf("%s starts at %.1f, got %.2f", model.Name, model.Epoch, year)
+	}
+
+	const (
+		// WGS84 ellipsoid and the geomagnetic reference radius, in km
+		semiMajorAxis   = 6378.137
+		flattening      = 1 / 298.257223563
+		referenceRadius = 6371.2
+	)
+	eccentricity2 := flattening * (2 - flattening)
+	height := float64(altitude) / float64(physic.KiloMetre)
+
+	// Geodetic to geocentric spherical coordinates
+	sinLat, cosLat := math.Sincos(radians(latitude))
+	primeVertical := semiMajorAxis / math.Sqrt(1-eccentricity2*sinLat*sinLat)
+	rho := (primeVertical + height) * cosLat
+	z := (primeVertical*(1-eccentricity2) + height) * sinLat
+	r := math.Hypot(rho, z)
+	geocentricLat := math.Asin(z / r)
+
+	// Legendre functions are evaluated in terms of the geocentric colatitude
+	cosTheta, sinTheta := math.Sincos(geocentricLat)
+	p, dp := schmidtLegendre(model.degree, sinTheta, cosTheta)
+
+	dt := year - model.Epoch
+	lambda := radians(longitude)
+	var xPrime, yPrime, zPrime float64
+	ratio := referenceRadius / r
+	power := ratio * ratio
+	for n := 1; n <= model.degree; n++ {
+		power *= ratio
+		for m := 0; m <= n; m++ {
+			g := model.g[n][m] + dt*model.gDot[n][m]
+			h := model.h[n][m] + dt*model.hDot[n][m]
+			sinM, cosM := math.Sincos(float64(m) * lambda)
+
+			xPrime -= power * (g*cosM + h*sinM) * dp[n][m]
+			yPrime += power * float64(m) * (g*sinM - h*cosM) * p[n][m]
+			zPrime -= power * float64(n+1) * (g*cosM + h*sinM) * p[n][m]
+		}
+	}
+	// The east component is singular at the poles
+	if sinTheta > 1e-10 {
+		yPrime /= sinTheta
+	}
+
+	// Rotate back from the geocentric to the geodetic frame
+	sinDelta, cosDelta := math.Sincos(geocentricLat - radians(latitude))
+	north := xPrime*cosDelta - zPrime*sinDelta
+	east := yPrime
+	down := xPrime*sinDelta + zPrime*cosDelta
+
+	horizontal := math.Hypot(north, east)
+	return MagneticElements{
+		Declination: degrees(math.Atan2(east, north)),
+		Inclination: degrees(math.Atan2(down, horizontal)),
+		Intensity:   math.Hypot(horizontal, down),
+		Horizontal:  horizontal,
+		North:       north,
+		East:        east,
+		Down:        down,
+	}, nil
+}
+
+// Computes the Schmidt semi-normalized associated Legendre functions of
+// sin(latitude) and their derivatives with respect to latitude. The arguments
+// are the sine and cosine of the geocentric colatitude.
+func schmidtLegendre(degree int, sinTheta, cosTheta float64) ([][]float64, [][]float64) {
+	p := triangle(degree)
+	dp := triangle(degree)
+
+	// Gauss normalized recursion, derivatives with respect to colatitude
+	p[0][0] = 1
+	for n := 1; n <= degree; n++ {
+		for m := 0; m <= n; m++ {
+			switch {
+			case n == m:
+				p[n][m] = sinTheta * p[n-1][m-1]
+				dp[n][m] = sinTheta*dp[n-1][m-1] + cosTheta*p[n-1][m-1]
+			case n == 1:
+				p[n][m] = cosTheta * p[n-1][m]
+				dp[n][m] = cosTheta*dp[n-1][m] - sinTheta*p[n-1][m]
+			default:
+				k := float64((n-1)*(n-1)-m*m) / float64((2*n-1)*(2*n-3))
+				var pPrev, dpPrev float64
+				if m <= n-2 {
+					pPrev, dpPrev = p[n-2][m], dp[n-2][m]
+				}
+				p[n][m] = cosTheta*p[n-1][m] - k*pPrev
+				dp[n][m] = cosTheta*dp[n-1][m] - sinTheta*p[n-1][m] - k*dpPrev
+			}
+		}
+	}
+
+	// Convert to Schmidt semi-normalization, and to latitude derivatives
+	schmidt := 1.0
+	for n := 1; n <= degree; n++ {
+		schmidt *= float64(2*n-1) / float64(n)
+		factor := schmidt
+		for m := 0; m <= n; m++ {
+			if m > 0 {
+				k := float64(n-m+1) / float64(n+m)
+				if m == 1 {
+					k *= 2
+				}
+				factor *= math.Sqrt(k)
+			}
+			p[n][m] *= factor
+			dp[n][m] *= -factor
+		}
+	}
+
+	return p, dp
+}
+
+func triangle(degree int) [][]float64 {
+	t := make([][]float64, degree+1)
+	for n := range t {
+		t[n] = make([]float64, n+1)
+	}
+	return t
+}
+
+func decimalYear(date time.Time) float64 {
+	date = date.UTC()
+	start := time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+	return float64(date.Year()) + float64(date.Sub(start))/float64(end.Sub(start))
+}
@@ -0,0 +1,183 @@
+package lsm303
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+)
+
+func TestMagneticModelTestValues(t *testing.T) {
+	// Test values from the WMM2020 technical report
+	cases := []struct {
+		latitude, longitude float64
+		altitude            physic.Distance
+		north, east, down   float64
+		declination         float64
+		inclination         float64
+	}{
+		{80, 0, 0, 6570.4, -146.3, 54606.0, -1.28, 83.14},
+		{0, 120, 0, 39624.3, 109.9, -10932.5, 0.16, -15.42},
+		{-80, 240, 0, 5940.6, 15772.1, -52480.8, 69.36, -72.20},
+		{80, 0, 100 * physic.KiloMetre, 6261.8, -185.5, 52429.1, -1.70, 83.19},
+	}
+
+	epoch := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	model, err := MagneticModelFor(epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		elements, err := model.Evaluate(c.latitude, c.longitude, c.altitude, epoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(elements.North-c.north) > 0.1 || math.Abs(elements.East-c.east) > 0.1 || math.Abs(elements.Down-c.down) > 0.1 {
+			t.Errorf("(%v, %v, %v) expected field %v %v %v, got %v %v %v", c.latitude, c.longitude, c.altitude, c.north, c.east, c.down, elements.North, elements.East, elements.Down)
+		}
+		if math.Abs(elements.Declination-c.declination) > 0.01 {
+			t.Errorf("(%v, %v, %v) expected declination %v, got %v", c.latitude, c.longitude, c.altitude, c.declination, elements.Declination)
+		}
+		if math.Abs(elements.Inclination-c.inclination) > 0.01 {
+			t.Errorf("(%v, %v, %v) expected inclination %v, got %v", c.latitude, c.longitude, c.altitude, c.inclination, elements.Inclination)
+		}
+	}
+}
+
+func TestMagneticModelValidity(t *testing.T) {
+	model, err := MagneticModelFor(time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil || model.Name != "WMM-2020" {
+		t.Fatalf("picked %v, %v for 2021", model, err)
+	}
+	if _, err := model.Evaluate(0, 0, 0, time.Date(2019, time.June, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("expected error before the model epoch")
+	}
+	if _, err := model.Evaluate(91, 0, 0, time.Date(2021, time.June, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("expected error for invalid latitude")
+	}
+}
+
+func TestMagneticModelNow(t *testing.T) {
+	model, err := MagneticModelFor(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model != DefaultMagneticModel() {
+		t.Errorf("picked %s for today, the newest is %s", model.Name, DefaultMagneticModel().Name)
+	}
+
+	// Boulder, Colorado has been 7° to 9° east for decades
+	compass, err := NewCompass(&Accelerometer{}, &Magnetometer{}, WithLocation(40, -105.25, 1650*physic.Metre))
+	if err != nil {
+		t.Fatal(err)
+	}
+	declination, err := compass.Declination(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if declination < 6 || declination > 10 {
+		t.Errorf("declination today is %v", declination)
+	}
+}
+
+func TestSelectMagneticModel(t *testing.T) {
+	var models []*MagneticModel
+	for _, epoch := range []string{"2030.0", "2035.0"} {
+		model, err := ParseMagneticModel(strings.NewReader(epoch + " TEST-" + epoch[:4] + "\n  1  0  -30000.0  0.0  0.0  0.0\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		models = append(models, model)
+	}
+	// Past the newest release it is extrapolated
+	for year, expected := range map[int]string{2030: "TEST-2030", 2034: "TEST-2030", 2035: "TEST-2035", 2039: "TEST-2035", 2042: "TEST-2035"} {
+		if model, err := selectMagneticModel(models, time.Date(year, time.June, 1, 0, 0, 0, 0, time.UTC)); err != nil || model.Name != expected {
+			t.Errorf("picked %v, %v for %d", model, err, year)
+		}
+	}
+	if _, err := selectMagneticModel(models, time.Date(2029, time.June, 1, 0, 0, 0, 0, time.UTC)); err == nil || !strings.Contains(err.Error(), "starts at 2030.0") {
+		t.Errorf("picked a model for 2029: %v", err)
+	}
+}
+
+func TestParseMagneticModel(t *testing.T) {
+	// A dipole-only model pointing straight at geographic north
+	cof := "    2030.0            TEST-DIPOLE     01/01/2030\n" +
+		"  1  0  -30000.0       0.0        0.0        0.0\n" +
+		"999999999999999999999999999999999999999999999999\n"
+	model, err := ParseMagneticModel(strings.NewReader(cof))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.Name != "TEST-DIPOLE" || model.Epoch != 2030 || model.ValidUntil != 2035 {
+		t.Fatalf("bad header: %+v", model)
+	}
+
+	elements, err := model.Evaluate(0, 45, 0, time.Date(2031, time.January, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(elements.Declination) > 1e-9 || math.Abs(elements.Inclination) > 1e-9 {
+		t.Errorf("dipole field should be horizontal and point north at the equator: %+v", elements)
+	}
+	if math.Abs(elements.North-30000*math.Pow(6371.2/6378.137, 3)) > 1e-6 {
+		t.Errorf("unexpected dipole strength: %v", elements.North)
+	}
+
+	if _, err := ParseMagneticModel(strings.NewReader("2030.0 BROKEN\n  1  0  x\n")); err == nil {
+		t.Error("expected parse error")
+	}
+}
+
+func TestCompassDeclination(t *testing.T) {
+	compass, err := NewCompass(&Accelerometer{}, &Magnetometer{}, WithDeclination(-3.5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if declination, err := compass.Declination(time.Now()); err != nil || declination != -3.5 {
+		t.Fatalf("expected fixed declination, got %v %v", declination, err)
+	}
+
+	compass, err = NewCompass(&Accelerometer{}, &Magnetometer{}, WithLocation(-80, 240, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	declination, err := compass.Declination(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(declination-69.36) > 0.01 {
+		t.Fatalf("expected declination from the model, got %v", declination)
+	}
+
+	compass, err = NewCompass(&Accelerometer{}, &Magnetometer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := compass.Declination(time.Now()); err == nil {
+		t.Fatal("expected error without declination source")
+	}
+}
+
+func TestCompassDeclinationConcurrent(t *testing.T) {
+	compass, err := NewCompass(&Accelerometer{}, &Magnetometer{}, WithLocation(-80, 240, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each goroutine asks for another day, so they all refresh the cache
+	var wg sync.WaitGroup
+	for day := 0; day < 8; day++ {
+		wg.Add(1)
+		go func(day int) {
+			defer wg.Done()
+			if _, err := compass.Declination(time.Date(2021, time.January, 1+day, 0, 0, 0, 0, time.UTC)); err != nil {
+				t.Error(err)
+			}
+		}(day)
+	}
+	wg.Wait()
+}
@@ -3,6 +3,7 @@ package lsm303
 import (
 	"encoding/binary"
 	"fmt"
+	"math"
 	"time"
 
 	"periph.io/x/periph/conn/i2c"
@@ -18,6 +19,26 @@ type Magnetometer struct {
 	addr *uint16
 	rate MagnetometerRate
 	gain MagnetometerGain
+	calibration *MagnetometerCalibration
+	remap *AxisRemap
+	// Take the configuration from the chip instead of writing it
+	current bool
+}
+
+// MagneticField is a measurement of magnetic flux density, stored in
+// nanotesla. periph.io has units for many things, but not for this one.
+type MagneticField int64
+
+const (
+	NanoTesla  MagneticField = 1
+	MicroTesla MagneticField = 1000 * NanoTesla
+	MilliTesla MagneticField = 1000 * MicroTesla
+	Tesla      MagneticField = 1000 * MilliTesla
+	Gauss      MagneticField = 100 * MicroTesla
+)
+
+func (f MagneticField) String() string {
+	return fmt.Sprintf("%.3fµT", float64(f)/float64(MicroTesla))
 }
 
 // New magnetometer opens a handle to an LSM303 magnetometer sensor.
@@ -25,7 +46,8 @@ func NewMagnetometer(bus i2c.Bus, opts ...MagnetometerOption) (*Magnetometer, er
 	device := &Magnetometer{
 		sensorType: LSM303DLHC,
 		gain: MAGNETOMETER_GAIN_4_0,
-		rate: MAGNETOMETER_RATE_30,
+		// Unset, chosen per variant below
+		rate: -1,
 	}
 
 	for i := range opts {
@@ -40,6 +62,10 @@ func NewMagnetometer(bus i2c.Bus, opts ...MagnetometerOption) (*Magnetometer, er
 		device.addr = &device.datasheet.ADDRESS
 	}
 
+	if device.rate < 0 {
+		device.rate = magnetometerDriverFor(device.sensorType).defaultRate
+	}
+
 	device.mmr = mmr.Dev8{
 		Conn: &i2c.Dev{Bus: bus, Addr: *device.addr},
 		// I don't think we ever access more than 1 byte at once, so
@@ -47,10 +73,19 @@ func NewMagnetometer(bus i2c.Bus, opts ...MagnetometerOption) (*Magnetometer, er
 		Order: binary.BigEndian,
 	}
 
-	// Enable the magnetometer
-	err := device.mmr.WriteUint8(device.datasheet.MR_REG_M, 0x00)
-	if err != nil {
-		return nil, err
+	// Enable the magnetometer, continuous conversion
+	running := false
+	if device.current {
+		mode, err := readField(&device.mmr, device.fields().mode)
+		if err != nil {
+			return nil, err
+		}
+		running = mode == 0
+	}
+	if !running {
+		if err := writeField(&device.mmr, device.fields().mode, 0); err != nil {
+			return nil, err
+		}
 	}
 
 	// Validate sensor
@@ -60,16 +95,39 @@ func NewMagnetometer(bus i2c.Bus, opts ...MagnetometerOption) (*Magnetometer, er
 		return nil, fmt.Errorf("no %s detected", device.sensorType)
 	}
 
+	if running {
+		if err := device.readConfiguration(); err != nil {
+			return nil, err
+		}
+		return device, nil
+	}
+
 	// Init magnetometer configuration
 	device.SetGain(device.gain)
-	device.SetRate(device.rate)
+	if err := device.SetRate(device.rate); err != nil {
+		return nil, err
+	}
 
 	return device, nil
 }
 
 
 
+// SenseRaw returns the raw output counts, rotated into the body frame if
+// WithMagnetometerAxisRemap was given. Note the DLHC Z axis has a different
+// sensitivity than X and Y, so remapped counts from it are best scaled with
+// Sense.
 func (m *Magnetometer) SenseRaw() (int16, int16, int16, error) {
+	xValue, yValue, zValue, err := m.readRaw()
+	if err != nil || m.remap == nil {
+		return xValue, yValue, zValue, err
+	}
+	xValue, yValue, zValue = m.remap.applyRaw(xValue, yValue, zValue)
+	return xValue, yValue, zValue, nil
+}
+
+// Reads the output counts in the sensor frame.
+func (m *Magnetometer) readRaw() (int16, int16, int16, error) {
 	xLow, err := m.mmr.ReadUint8(m.datasheet.OUT_X_L_M)
 	if err != nil {
 		return 0, 0, 0, err
@@ -102,59 +160,102 @@ func (m *Magnetometer) SenseRaw() (int16, int16, int16, error) {
 	return xValue, yValue, zValue, nil
 }
 
-func (m *Magnetometer) SetRate(mode MagnetometerRate) error {
-	const bits = 3
-	const shift = 2
+// Sense returns the magnetic field, with the calibration from
+// WithMagnetometerCalibration applied if there is one.
+func (m *Magnetometer) Sense() (MagneticField, MagneticField, MagneticField, error) {
+	field, err := m.senseCalibratedField()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return gaussToField(field.X), gaussToField(field.Y), gaussToField(field.Z), nil
+}
+
+// SetCalibration replaces the calibration applied by Sense, nil disables it.
+func (m *Magnetometer) SetCalibration(calibration *MagnetometerCalibration) {
+	m.calibration = calibration
+}
 
-	// The only bit in here that matters is the bit 7, temperature
-	// enabled, so just always set it to 1
-	previous := uint8(0)
-	data := uint8(mode)
-	mask := uint8((1 << bits) - 1)
-	data &= mask
-	mask <<= shift
-	previous &= (^mask)
-	previous |= data << shift
-	// Enable temperature
-	previous |= 0b10000000
+// Reads the field in gauss, with the calibration applied if there is one.
+func (m *Magnetometer) senseCalibratedField() (Vector, error) {
+	field, err := m.senseField()
+	if err != nil {
+		return Vector{}, err
+	}
+	if m.calibration != nil {
+		field = m.calibration.Apply(field)
+	}
+	return field, nil
+}
 
-	err := m.mmr.WriteUint8(m.datasheet.CRA_REG_M, previous)
+// Reads the uncalibrated field in gauss, in the body frame. The axes are
+// scaled before remapping since their sensitivities can differ.
+func (m *Magnetometer) senseField() (Vector, error) {
+	xValue, yValue, zValue, err := m.readRaw()
 	if err != nil {
+		return Vector{}, err
+	}
+	xyLsb, zLsb := getMagnetometerLsb(m.sensorType, m.gain)
+	field := Vector{
+		float64(xValue) / xyLsb,
+		float64(yValue) / xyLsb,
+		float64(zValue) / zLsb,
+	}
+	if m.remap != nil {
+		field = m.remap.Apply(field)
+	}
+	return field, nil
+}
+
+func gaussToField(gauss float64) MagneticField {
+	return MagneticField(math.Round(gauss * float64(Gauss)))
+}
+
+// Gets the LSB/gauss sensitivity of the X/Y and Z axes. Variants with a fixed
+// gain have a single entry, and gains past the table get the last one.
+func getMagnetometerLsb(sensorType SensorType, gain MagnetometerGain) (float64, float64) {
+	lsb := magnetometerDriverFor(sensorType).lsbPerGauss
+	if gain < 0 || int(gain) >= len(lsb) {
+		gain = MagnetometerGain(len(lsb) - 1)
+	}
+	return lsb[gain][0], lsb[gain][1]
+}
+
+// SetRate sets the output data rate. The setting must be one the variant has,
+// see MagnetometerRate.Hertz.
+func (m *Magnetometer) SetRate(rate MagnetometerRate) error {
+	if _, ok := rate.Hertz(m.sensorType); !ok {
+		return fmt.Errorf("%s magnetometer has no rate setting %d, its rates are %v Hz", m.sensorType, rate, magnetometerDriverFor(m.sensorType).rates)
+	}
+	fields := m.fields()
+	if err := writeField(&m.mmr, fields.dataRate, uint8(rate)); err != nil {
+		return err
+	}
+	// Always keep the temperature sensor enabled
+	if err := writeField(&m.mmr, fields.temperature, 1); err != nil {
 		return err
 	}
 	time.Sleep(time.Millisecond * 20)
 
+	m.rate = rate
+
 	return nil
 }
 
 func (m *Magnetometer) GetRate() (MagnetometerRate, error) {
-	value, err := m.mmr.ReadUint8(m.datasheet.CRA_REG_M)
+	rate, err := readField(&m.mmr, m.fields().dataRate)
 	if err != nil {
 		return MAGNETOMETER_RATE_30, err
 	}
-	const bits = 3
-	const shift = 2
-	range_ := ((uint32(value)) >> shift) & ((1 << bits) - 1)
-	return MagnetometerRate(range_), nil
+	return MagnetometerRate(rate), nil
 }
 
+// SetGain sets the DLHC gain, the AGR and C have a fixed full scale.
 func (m *Magnetometer) SetGain(gain MagnetometerGain) error {
-	const bits = 3
-	const shift = 5
-
-	data := uint8(gain)
-	currentGain, err := m.mmr.ReadUint8(m.datasheet.CRB_REG_M)
-	if err != nil {
-		return err
+	field := m.fields().gain
+	if field.Width == 0 {
+		return unsupported(m.sensorType, "gain")
 	}
-
-	mask := uint8((1 << bits) - 1)
-	data &= mask
-	mask <<= shift
-	currentGain &= (^mask)
-	currentGain |= data << shift
-	err = m.mmr.WriteUint8(m.datasheet.CRB_REG_M, currentGain)
-	if err != nil {
+	if err := writeField(&m.mmr, field, uint8(gain)); err != nil {
 		return err
 	}
 	time.Sleep(time.Millisecond * 20)
@@ -165,13 +266,14 @@ func (m *Magnetometer) SetGain(gain MagnetometerGain) error {
 }
 
 func (m *Magnetometer) GetGain() (MagnetometerGain, error) {
-	value, err := m.mmr.ReadUint8(m.datasheet.CRA_REG_M)
+	field := m.fields().gain
+	if field.Width == 0 {
+		return m.gain, unsupported(m.sensorType, "gain")
+	}
+	gain, err := readField(&m.mmr, field)
 	if err != nil {
 		return MAGNETOMETER_GAIN_4_0, err
 	}
-	const bits = 3
-	const shift = 5
-	gain := ((uint32(value)) >> shift) & ((1 << bits) - 1)
 	return MagnetometerGain(gain), nil
 }
 
@@ -201,3 +303,14 @@ func (m *Magnetometer) senseRelativeTemperatureRaw() (int16, error) {
 	degreesEighths := ((int16(high) << 8) | int16(uint16(low))) >> 4
 	return degreesEighths, nil
 }
+
+// String names the magnetometer and where it is, e.g.
+// "LSM303DLHC magnetometer on I2C1 at 0x1E".
+func (m *Magnetometer) String() string {
+	return describe(m.sensorType, "magnetometer", m.mmr.Conn)
+}
+
+// SensorType returns the variant the magnetometer was created for.
+func (m *Magnetometer) SensorType() SensorType {
+	return m.sensorType
+}
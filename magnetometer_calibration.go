@@ -0,0 +1,206 @@
+package lsm303
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// MagnetometerCalibration corrects hard-iron and soft-iron distortion. The
+// corrected field is SoftIron * (raw - Offset), all in gauss.
+type MagnetometerCalibration struct {
+	// Hard-iron offset, the center of the fitted ellipsoid
+	Offset Vector `json:"offset"`
+	// Soft-iron matrix mapping the ellipsoid back onto a sphere
+	SoftIron [3][3]float64 `json:"soft_iron"`
+}
+
+// Apply corrects a field reading in gauss.
+func (c MagnetometerCalibration) Apply(field Vector) Vector {
+	return matrix3(c.SoftIron).mulVec(field.Sub(c.Offset))
+}
+
+// MagnetometerCalibrationReport describes how well a calibration fits the
+// collected samples.
+type MagnetometerCalibrationReport struct {
+	Samples int
+	// Fraction of the sphere of directions the samples cover, from 0 to 1.
+	// Anything below ~0.7 usually means the device wasn't rotated enough.
+	Coverage float64
+	// Radius of the corrected sphere, i.e. the local field strength in gauss
+	FieldStrength float64
+	// RMS deviation of the corrected samples from the sphere, relative to
+	// FieldStrength.
+	Residual float64
+}
+
+func (r MagnetometerCalibrationReport) String() string {
+	return fmt.Sprintf("%d samples, %.0f%% coverage, field %.3f gauss, residual %.2f%%",
+		r.Samples, r.Coverage*100, r.FieldStrength, r.Residual*100)
+}
+
+// MagnetometerCalibrator collects samples while the device is rotated in all
+// directions and fits a calibration to them.
+type MagnetometerCalibrator struct {
+	magnetometer *Magnetometer
+	samples      []Vector
+}
+
+// NewMagnetometerCalibrator creates a calibrator for the given magnetometer.
+// The magnetometer can be nil if samples are only added with AddSample.
+func NewMagnetometerCalibrator(magnetometer *Magnetometer) *MagnetometerCalibrator {
+	return &MagnetometerCalibrator{magnetometer: magnetometer}
+}
+
+// AddSample adds an uncalibrated reading in gauss.
+func (c *MagnetometerCalibrator) AddSample(field Vector) {
+	c.samples = append(c.samples, field)
+}
+
+// Collect reads the magnetometer every interval until the context is done.
+// The device should be slowly rotated through every orientation meanwhile.
+func (c *MagnetometerCalibrator) Collect(ctx context.Context, interval time.Duration) error {
+	if c.magnetometer == nil {
+		return errors.New("calibrator has no magnetometer")
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		field, err := c.magnetometer.senseField()
+		if err != nil {
+			return err
+		}
+		c.AddSample(field)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Samples returns the number of collected samples.
+func (c *MagnetometerCalibrator) Samples() int {
+	return len(c.samples)
+}
+
+// Reset drops all collected samples.
+func (c *MagnetometerCalibrator) Reset() {
+	c.samples = nil
+}
+
+// Fit computes the calibration from the collected samples by fitting an
+// ellipsoid to them.
+func (c *MagnetometerCalibrator) Fit() (MagnetometerCalibration, MagnetometerCalibrationReport, error) {
+	report := MagnetometerCalibrationReport{Samples: len(c.samples)}
+	if len(c.samples) < 12 {
+		return MagnetometerCalibration{}, report, fmt.Errorf("need at least 12 samples, have %d", len(c.samples))
+	}
+
+	// Normalize the samples to keep the normal equations well conditioned
+	var mean Vector
+	for _, s := range c.samples {
+		mean = mean.Add(s)
+	}
+	mean = mean.Scale(1 / float64(len(c.samples)))
+	spread := 0.0
+	for _, s := range c.samples {
+		spread += s.Sub(mean).Dot(s.Sub(mean))
+	}
+	spread = math.Sqrt(spread / float64(len(c.samples)))
+	if spread == 0 {
+		return MagnetometerCalibration{}, report, errors.New("all samples are identical")
+	}
+
+	// Fit a x² + b y² + c z² + 2f yz + 2g xz + 2h xy + 2p x + 2q y + 2r z = 1
+	rows := make([][]float64, len(c.samples))
+	ones := make([]float64, len(c.samples))
+	for i, s := range c.samples {
+		u := s.Sub(mean).Scale(1 / spread)
+		rows[i] = []float64{u.X * u.X, u.Y * u.Y, u.Z * u.Z, 2 * u.Y * u.Z, 2 * u.X * u.Z, 2 * u.X * u.Y, 2 * u.X, 2 * u.Y, 2 * u.Z}
+		ones[i] = 1
+	}
+	v, err := leastSquares(rows, ones)
+	if err != nil {
+		return MagnetometerCalibration{}, report, fmt.Errorf("ellipsoid fit failed: %v", err)
+	}
+
+	quadric := matrix3{
+		{v[0], v[5], v[4]},
+		{v[5], v[1], v[3]},
+		{v[4], v[3], v[2]},
+	}
+	inverse, err := quadric.inverse()
+	if err != nil {
+		return MagnetometerCalibration{}, report, fmt.Errorf("ellipsoid fit failed: %v", err)
+	}
+	center := inverse.mulVec(Vector{v[6], v[7], v[8]}).Scale(-1)
+
+	// (u - center)ᵀ shape (u - center) = 1
+	k := 1 + center.Dot(quadric.mulVec(center))
+	var shape matrix3
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			shape[i][j] = quadric[i][j] / k / (spread * spread)
+		}
+	}
+	root, err := shape.sqrt()
+	if err != nil {
+		return MagnetometerCalibration{}, report, errors.New("samples do not describe an ellipsoid, rotate the device in more directions")
+	}
+
+	// Scale the result so the sphere keeps the geometric mean radius of the
+	// ellipsoid, which is the best guess of the real field strength.
+	radius := math.Pow(shape.det(), -1.0/6)
+	var softIron [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			softIron[i][j] = root[i][j] * radius
+		}
+	}
+
+	calibration := MagnetometerCalibration{
+		Offset:   mean.Add(center.Scale(spread)),
+		SoftIron: softIron,
+	}
+
+	report.FieldStrength = radius
+	report.Coverage, report.Residual = c.evaluate(calibration, radius)
+	return calibration, report, nil
+}
+
+// Computes the direction coverage and the relative RMS residual of the
+// corrected samples.
+func (c *MagnetometerCalibrator) evaluate(calibration MagnetometerCalibration, radius float64) (float64, float64) {
+	// Equal area bins: 4 bands of constant height times 8 sectors of longitude
+	const bands = 4
+	const sectors = 8
+	var hit [bands * sectors]bool
+
+	sumSquares := 0.0
+	for _, s := range c.samples {
+		corrected := calibration.Apply(s)
+		deviation := corrected.Norm()/radius - 1
+		sumSquares += deviation * deviation
+
+		direction := corrected.Normalize()
+		band := int((direction.Z + 1) / 2 * bands)
+		sector := int((math.Atan2(direction.Y, direction.X) + math.Pi) / (2 * math.Pi) * sectors)
+		band = int(math.Min(math.Max(float64(band), 0), bands-1))
+		sector = int(math.Min(math.Max(float64(sector), 0), sectors-1))
+		hit[band*sectors+sector] = true
+	}
+
+	covered := 0
+	for _, h := range hit {
+		if h {
+			covered++
+		}
+	}
+
+	return float64(covered) / float64(len(hit)), math.Sqrt(sumSquares / float64(len(c.samples)))
+}
@@ -0,0 +1,137 @@
+package lsm303
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/mmr"
+)
+
+// Distorts a sphere of directions the way a board with hard and soft iron
+// would.
+func distortedSamples(n int, noise float64, directions func(i int) Vector) []Vector {
+	const fieldStrength = 0.5
+	offset := Vector{0.21, -0.13, 0.4}
+	distortion := matrix3{
+		{1.2, 0.05, -0.02},
+		{0.05, 0.9, 0.03},
+		{-0.02, 0.03, 1.05},
+	}
+
+	random := rand.New(rand.NewSource(1))
+	samples := make([]Vector, n)
+	for i := range samples {
+		field := directions(i).Normalize().Scale(fieldStrength)
+		jitter := Vector{random.NormFloat64(), random.NormFloat64(), random.NormFloat64()}.Scale(noise)
+		samples[i] = distortion.mulVec(field).Add(offset).Add(jitter)
+	}
+	return samples
+}
+
+func randomDirections(seed int64) func(int) Vector {
+	random := rand.New(rand.NewSource(seed))
+	return func(int) Vector {
+		return Vector{random.NormFloat64(), random.NormFloat64(), random.NormFloat64()}
+	}
+}
+
+func TestMagnetometerCalibrationFit(t *testing.T) {
+	calibrator := NewMagnetometerCalibrator(nil)
+	for _, s := range distortedSamples(500, 0.002, randomDirections(2)) {
+		calibrator.AddSample(s)
+	}
+
+	calibration, report, err := calibrator.Fit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calibration.Offset.Sub(Vector{0.21, -0.13, 0.4}).Norm() > 0.01 {
+		t.Errorf("bad offset %+v", calibration.Offset)
+	}
+	if report.Coverage < 0.95 {
+		t.Errorf("expected full coverage, got %v", report.Coverage)
+	}
+	if report.Residual > 0.01 {
+		t.Errorf("residual too large: %v", report.Residual)
+	}
+	if report.Samples != 500 {
+		t.Errorf("expected 500 samples, got %d", report.Samples)
+	}
+	// The geometric mean radius of the distortion is 0.5 * cbrt(det)
+	if math.Abs(report.FieldStrength-0.5*math.Cbrt(1.2*0.9*1.05)) > 0.01 {
+		t.Errorf("unexpected field strength %v", report.FieldStrength)
+	}
+
+	for _, s := range distortedSamples(20, 0, randomDirections(3)) {
+		if math.Abs(calibration.Apply(s).Norm()/report.FieldStrength-1) > 0.01 {
+			t.Fatalf("corrected sample %+v is not on the sphere", calibration.Apply(s))
+		}
+	}
+}
+
+func TestMagnetometerCalibrationCoverage(t *testing.T) {
+	// Only rotate around Z, like someone spinning the board on a table
+	calibrator := NewMagnetometerCalibrator(nil)
+	samples := distortedSamples(200, 0, func(i int) Vector {
+		angle := float64(i) * 2 * math.Pi / 200
+		return Vector{math.Cos(angle), math.Sin(angle), 0.3 * math.Sin(3*angle)}
+	})
+	for _, s := range samples {
+		calibrator.AddSample(s)
+	}
+
+	_, report, err := calibrator.Fit()
+	if err == nil && report.Coverage > 0.7 {
+		t.Fatalf("planar rotation should report poor coverage, got %v", report)
+	}
+}
+
+func TestMagnetometerCalibrationNeedsSamples(t *testing.T) {
+	calibrator := NewMagnetometerCalibrator(nil)
+	calibrator.AddSample(Vector{1, 2, 3})
+	if _, _, err := calibrator.Fit(); err == nil {
+		t.Fatal("expected error with too few samples")
+	}
+}
+
+func TestMagnetometerSenseCalibrated(t *testing.T) {
+	scenario := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_X_L_M}, R: []byte{0xC2}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_X_H_M}, R: []byte{0x01}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_Y_L_M}, R: []byte{0}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_Y_H_M}, R: []byte{0}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_Z_L_M}, R: []byte{0x38}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_Z_H_M}, R: []byte{0xFF}},
+		},
+	}
+
+	magnetometer := &Magnetometer{
+		mmr: mmr.Dev8{
+			Conn:  &i2c.Dev{Bus: scenario, Addr: magnetometerDatasheet.ADDRESS},
+			Order: binary.BigEndian,
+		},
+		sensorType: LSM303DLHC,
+		datasheet:  magnetometerDatasheet,
+		gain:       MAGNETOMETER_GAIN_4_0,
+		rate:       MAGNETOMETER_RATE_30,
+	}
+	WithMagnetometerCalibration(MagnetometerCalibration{
+		Offset:   Vector{0.5, 0, 0},
+		SoftIron: [3][3]float64{{2, 0, 0}, {0, 1, 0}, {0, 0, 1}},
+	}).Apply(magnetometer)
+
+	// Raw 450 on X is 1 gauss at this gain, -200 on Z is -0.5 gauss
+	x, y, z, err := magnetometer.Sense()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x != Gauss || y != 0 || z != -Gauss/2 {
+		t.Fatalf("unexpected field %v %v %v", x, y, z)
+	}
+}
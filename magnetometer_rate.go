@@ -0,0 +1,26 @@
+package lsm303
+
+import "fmt"
+
+// Hertz returns the output data rate the setting selects on the given variant,
+// or false if the variant has no such setting. The AGR and C have other rates
+// than the DLHC the settings are named after, and the AGR only four of them.
+func (rate MagnetometerRate) Hertz(sensorType SensorType) (float64, bool) {
+	rates := magnetometerDriverFor(sensorType).rates
+	if rate < 0 || int(rate) >= len(rates) {
+		return 0, false
+	}
+	return rates[rate], true
+}
+
+// MagnetometerRateOf returns the rate setting that selects the given output
+// data rate on the variant.
+func MagnetometerRateOf(sensorType SensorType, hz float64) (MagnetometerRate, error) {
+	rates := magnetometerDriverFor(sensorType).rates
+	for i, rate := range rates {
+		if rate == hz {
+			return MagnetometerRate(i), nil
+		}
+	}
+	return 0, fmt.Errorf("%s magnetometer rate must be one of %v Hz", sensorType, rates)
+}
@@ -0,0 +1,76 @@
+package lsm303
+
+import (
+	"testing"
+
+	"periph.io/x/periph/conn/i2c/i2ctest"
+)
+
+func TestMagnetometerRateHertz(t *testing.T) {
+	for _, test := range []struct {
+		sensorType SensorType
+		rate       MagnetometerRate
+		hz         float64
+	}{
+		{LSM303DLHC, MAGNETOMETER_RATE_30, 30},
+		{LSM303AGR, MagnetometerRate(2), 50},
+		{LSM303C, MAGNETOMETER_RATE_0_75, 0.625},
+	} {
+		if hz, ok := test.rate.Hertz(test.sensorType); !ok || hz != test.hz {
+			t.Errorf("%s setting %d is %v Hz, want %v", test.sensorType, test.rate, hz, test.hz)
+		}
+		if rate, err := MagnetometerRateOf(test.sensorType, test.hz); err != nil || rate != test.rate {
+			t.Errorf("%s %v Hz is setting %d, %v, want %d", test.sensorType, test.hz, rate, err, test.rate)
+		}
+	}
+
+	if _, ok := MAGNETOMETER_RATE_75.Hertz(LSM303AGR); ok {
+		t.Error("the AGR has a seventh rate")
+	}
+	if _, err := MagnetometerRateOf(LSM303AGR, 75); err == nil {
+		t.Error("the AGR has a 75 Hz rate")
+	}
+}
+
+func TestDefaultMagnetometerRate(t *testing.T) {
+	for sensorType, want := range map[SensorType]float64{LSM303DLHC: 30, LSM303AGR: 20, LSM303C: 20} {
+		if hz, ok := magnetometerDriverFor(sensorType).defaultRate.Hertz(sensorType); !ok || hz != want {
+			t.Errorf("%s defaults to %v Hz, want %v", sensorType, hz, want)
+		}
+	}
+}
+
+func TestMagnetometerSetRateUnknown(t *testing.T) {
+	// Nothing must be written
+	magnetometer, scenario := scriptedMagnetometer(LSM303AGR)
+
+	if err := magnetometer.SetRate(MAGNETOMETER_RATE_30); err == nil {
+		t.Error("SetRate wrote a rate the AGR doesn't have")
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMagnetometerSetRateOtherVariant(t *testing.T) {
+	// A variant without a rate table of its own gets the DLHC one, along with
+	// its register map
+	const sensorType = SensorType("LSM303DLM")
+	if hz, ok := MAGNETOMETER_RATE_75.Hertz(sensorType); !ok || hz != 75 {
+		t.Errorf("%s setting %d is %v Hz, want 75", sensorType, MAGNETOMETER_RATE_75, hz)
+	}
+
+	d := datasheetForMagnetometer(sensorType)
+	magnetometer, scenario := scriptedMagnetometer(sensorType,
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{d.CRA_REG_M}, R: []byte{0}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{d.CRA_REG_M, uint8(MAGNETOMETER_RATE_75) << 2}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{d.CRA_REG_M}, R: []byte{uint8(MAGNETOMETER_RATE_75) << 2}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{d.CRA_REG_M, uint8(MAGNETOMETER_RATE_75)<<2 | 0x80}},
+	)
+	if err := magnetometer.SetRate(MAGNETOMETER_RATE_75); err != nil {
+		t.Fatal(err)
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
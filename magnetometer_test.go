@@ -17,13 +17,19 @@ var (
 func TestNewMagnetometer(t *testing.T) {
 	scenario := &i2ctest.Playback{
 		Ops: []i2ctest.IO{
+			// Continuous conversion mode
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.MR_REG_M}, R: []byte{0b11}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.MR_REG_M, 0}},
 			// Read the chip ID (not a real ID, just a constant)
 			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.IRA_REG_M}, R: []byte{0b01001000}},
 			// Read gain
 			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.CRB_REG_M}, R: []byte{0}},
 			// Write new gain
 			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.CRB_REG_M, uint8(MAGNETOMETER_GAIN_4_0) << 5}, R: []byte{}},
-			// Write new rate
+			// Write new rate, then enable the temperature sensor
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.CRA_REG_M}, R: []byte{0}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.CRA_REG_M, uint8(MAGNETOMETER_RATE_30) << 2}, R: []byte{}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.CRA_REG_M}, R: []byte{uint8(MAGNETOMETER_RATE_30) << 2}},
 			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.CRA_REG_M, (uint8(MAGNETOMETER_RATE_30) << 2) | 0b10000000}, R: []byte{}},
 		},
 	}
@@ -0,0 +1,120 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+)
+
+type message struct {
+	topic   string
+	payload []byte
+	qos     QoS
+	retain  bool
+}
+
+// broker is an in-process stand-in for an MQTT broker, it accepts any
+// connection and keeps what is published.
+type broker struct {
+	listener net.Listener
+	messages chan message
+	// The CONNACK return code
+	refuse byte
+	// Whether to leave publishes unacknowledged
+	dropAcks bool
+
+	mu      sync.Mutex
+	connect []byte
+	pings   int
+	serving sync.WaitGroup
+}
+
+func newBroker(t *testing.T) *broker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &broker{listener: listener, messages: make(chan message, 1000)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			b.serving.Add(1)
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *broker) address() string {
+	return b.listener.Addr().String()
+}
+
+// closed waits for the clients to disconnect, and closes messages.
+func (b *broker) closed() {
+	b.serving.Wait()
+	close(b.messages)
+}
+
+func (b *broker) serve(conn net.Conn) {
+	defer b.serving.Done()
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		p, err := readPacket(reader)
+		if err != nil {
+			return
+		}
+
+		var reply *packet
+		switch p.kind {
+		case packetConnect:
+			b.mu.Lock()
+			b.connect = p.body
+			b.mu.Unlock()
+			reply = &packet{kind: packetConnack, body: []byte{0, b.refuse}}
+		case packetPublish:
+			topic, rest, err := readString(p.body)
+			if err != nil {
+				return
+			}
+			qos := QoS(p.flags >> 1 & 0b11)
+			var id uint16
+			if qos > QOS_AT_MOST_ONCE {
+				id, rest = binary.BigEndian.Uint16(rest), rest[2:]
+			}
+			b.messages <- message{topic: topic, payload: rest, qos: qos, retain: p.flags&1 != 0}
+			switch {
+			case b.dropAcks:
+			case qos == QOS_AT_LEAST_ONCE:
+				ack := ackPacket(packetPuback, id)
+				reply = &ack
+			case qos == QOS_EXACTLY_ONCE:
+				ack := ackPacket(packetPubrec, id)
+				reply = &ack
+			}
+		case packetPubrel:
+			ack := ackPacket(packetPubcomp, binary.BigEndian.Uint16(p.body))
+			reply = &ack
+		case packetPingreq:
+			b.mu.Lock()
+			b.pings++
+			b.mu.Unlock()
+			reply = &packet{kind: packetPingresp}
+		case packetDisconnect:
+			return
+		}
+
+		if reply != nil {
+			if _, err := conn.Write(reply.encode()); err != nil {
+				return
+			}
+		}
+	}
+}
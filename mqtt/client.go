@@ -0,0 +1,322 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// QoS is the delivery guarantee of a published message.
+type QoS byte
+
+const (
+	QOS_AT_MOST_ONCE QoS = iota
+	QOS_AT_LEAST_ONCE
+	QOS_EXACTLY_ONCE
+)
+
+func (qos QoS) String() string {
+	names := [...]string{"at most once", "at least once", "exactly once"}
+	if int(qos) >= len(names) {
+		return fmt.Sprintf("QoS(%d)", qos)
+	}
+	return names[qos]
+}
+
+// Client is a minimal MQTT 3.1.1 client that can only publish, with a clean
+// session. It is safe for concurrent use.
+type Client struct {
+	conn      net.Conn
+	clientID  string
+	username  *string
+	password  *string
+	keepAlive time.Duration
+	timeout   time.Duration
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  uint16
+	// Waiting publishers by packet identifier, the acknowledgements are
+	// passed on as their packet type
+	pending map[uint16]chan byte
+	done    chan struct{}
+	err     error
+}
+
+// Dial connects to the broker at address over TCP.
+func Dial(address string, opts ...ClientOption) (*Client, error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewClient(conn, opts...)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewClient connects to the broker on conn, which can be a TLS connection or
+// an in-process pipe.
+func NewClient(conn net.Conn, opts ...ClientOption) (*Client, error) {
+	c := &Client{
+		conn:      conn,
+		keepAlive: 60 * time.Second,
+		timeout:   10 * time.Second,
+		pending:   map[uint16]chan byte{},
+		done:      make(chan struct{}),
+	}
+	for i := range opts {
+		opts[i].Apply(c)
+	}
+
+	// Protocol name and level, flags for a clean session and the
+	// credentials, then the keep-alive in seconds
+	body := appendString(nil, "MQTT")
+	flags := byte(0b0000_0010)
+	if c.username != nil {
+		flags |= 0b1000_0000
+	}
+	if c.password != nil {
+		flags |= 0b0100_0000
+	}
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(c.keepAlive/time.Second))
+	body = appendString(body, c.clientID)
+	if c.username != nil {
+		body = appendString(body, *c.username)
+	}
+	if c.password != nil {
+		body = appendString(body, *c.password)
+	}
+
+	reader := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := conn.Write(packet{kind: packetConnect, body: body}.encode()); err != nil {
+		return nil, err
+	}
+	connack, err := readPacket(reader)
+	if err != nil {
+		return nil, fmt.Errorf("mqtt: connecting: %w", err)
+	}
+	if connack.kind != packetConnack || len(connack.body) != 2 {
+		return nil, errors.New("mqtt: broker didn't acknowledge the connection")
+	}
+	if code := connack.body[1]; code != 0 {
+		return nil, fmt.Errorf("mqtt: connection refused: %s", connectionRefused(code))
+	}
+	conn.SetDeadline(time.Time{})
+
+	go c.read(reader)
+	if c.keepAlive > 0 {
+		go c.ping()
+	}
+	return c, nil
+}
+
+func connectionRefused(code byte) string {
+	switch code {
+	case 1:
+		return "unacceptable protocol version"
+	case 2:
+		return "identifier rejected"
+	case 3:
+		return "server unavailable"
+	case 4:
+		return "bad user name or password"
+	case 5:
+		return "not authorized"
+	}
+	return fmt.Sprintf("return code %d", code)
+}
+
+// Publish sends a message and, for QoS 1 and 2, waits until the broker has
+// acknowledged it. A retained message is kept by the broker and sent to
+// whoever subscribes to the topic later.
+func (c *Client) Publish(topic string, payload []byte, qos QoS, retain bool) error {
+	if qos > QOS_EXACTLY_ONCE {
+		return fmt.Errorf("mqtt: invalid QoS %d", qos)
+	}
+	if qos == QOS_AT_MOST_ONCE {
+		return c.write(publishPacket(topic, payload, qos, retain, 0))
+	}
+
+	id, acks := c.register()
+	defer c.unregister(id)
+	if err := c.write(publishPacket(topic, payload, qos, retain, id)); err != nil {
+		return err
+	}
+	if qos == QOS_AT_LEAST_ONCE {
+		return c.wait(acks, packetPuback)
+	}
+	if err := c.wait(acks, packetPubrec); err != nil {
+		return err
+	}
+	if err := c.write(ackPacket(packetPubrel, id)); err != nil {
+		return err
+	}
+	return c.wait(acks, packetPubcomp)
+}
+
+// register allocates a packet identifier for a publish.
+func (c *Client) register() (uint16, chan byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		// Zero isn't a valid identifier
+		c.nextID++
+		if _, ok := c.pending[c.nextID]; c.nextID != 0 && !ok {
+			break
+		}
+	}
+	acks := make(chan byte, 2)
+	c.pending[c.nextID] = acks
+	return c.nextID, acks
+}
+
+func (c *Client) unregister(id uint16) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, id)
+}
+
+func (c *Client) wait(acks chan byte, kind byte) error {
+	timeout := time.NewTimer(c.timeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case ack := <-acks:
+			if ack == kind {
+				return nil
+			}
+		case <-c.done:
+			return c.Err()
+		case <-timeout.C:
+			return errors.New("mqtt: timed out waiting for the broker")
+		}
+	}
+}
+
+func (c *Client) write(p packet) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	select {
+	case <-c.done:
+		return c.Err()
+	default:
+	}
+	_, err := c.conn.Write(p.encode())
+	return err
+}
+
+// read passes the acknowledgements on until the connection ends.
+func (c *Client) read(reader *bufio.Reader) {
+	for {
+		p, err := readPacket(reader)
+		if err != nil {
+			c.mu.Lock()
+			c.err = err
+			c.mu.Unlock()
+			close(c.done)
+			return
+		}
+
+		switch p.kind {
+		case packetPuback, packetPubrec, packetPubcomp:
+			if len(p.body) < 2 {
+				continue
+			}
+			c.mu.Lock()
+			acks := c.pending[binary.BigEndian.Uint16(p.body)]
+			c.mu.Unlock()
+			if acks != nil {
+				select {
+				case acks <- p.kind:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// ping keeps the connection alive while nothing is published.
+func (c *Client) ping() {
+	ticker := time.NewTicker(c.keepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.write(packet{kind: packetPingreq})
+		}
+	}
+}
+
+// Err returns why the connection ended, or nil while it is up.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close disconnects from the broker.
+func (c *Client) Close() error {
+	err := c.write(packet{kind: packetDisconnect})
+	if closeErr := c.conn.Close(); err == nil {
+		err = closeErr
+	}
+	<-c.done
+	return err
+}
+
+type (
+	// ClientOption configures a Client.
+	ClientOption interface {
+		Apply(*Client)
+	}
+	// ClientOptionFunc is a function that configures a client.
+	ClientOptionFunc func(*Client)
+)
+
+// Apply calls ClientOptionFunc on client instance
+func (f ClientOptionFunc) Apply(c *Client) {
+	f(c)
+}
+
+// WithClientID sets the identifier the broker knows the client by.
+// Default is empty, for the broker to assign one.
+func WithClientID(id string) ClientOption {
+	return ClientOptionFunc(func(c *Client) {
+		c.clientID = id
+	})
+}
+
+// WithCredentials sets the user name and password to connect with.
+func WithCredentials(username, password string) ClientOption {
+	return ClientOptionFunc(func(c *Client) {
+		c.username, c.password = &username, &password
+	})
+}
+
+// WithKeepAlive sets how long the broker waits for a packet before dropping
+// the connection, the client pings twice as often. Zero turns it off.
+// Default is a minute.
+func WithKeepAlive(keepAlive time.Duration) ClientOption {
+	return ClientOptionFunc(func(c *Client) {
+		c.keepAlive = keepAlive
+	})
+}
+
+// WithTimeout sets how long to wait for the broker to acknowledge the
+// connection and messages. Default is 10 seconds.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return ClientOptionFunc(func(c *Client) {
+		c.timeout = timeout
+	})
+}
@@ -0,0 +1,107 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPacketEncoding(t *testing.T) {
+	// The remaining length takes a byte per 7 bits
+	for length, header := range map[int]int{0: 2, 127: 2, 128: 3, 16383: 3, 16384: 4} {
+		p := packet{kind: packetPublish, flags: 0b0011, body: bytes.Repeat([]byte{7}, length)}
+		encoded := p.encode()
+		read, err := readPacket(bufio.NewReader(bytes.NewReader(encoded)))
+		if err != nil || read.kind != p.kind || read.flags != p.flags || !bytes.Equal(read.body, p.body) {
+			t.Errorf("%d bytes: read %d bytes, %v", length, len(read.body), err)
+		}
+		if len(encoded)-length != header {
+			t.Errorf("%d bytes: %d bytes of fixed header, want %d", length, len(encoded)-length, header)
+		}
+	}
+
+	if _, err := readPacket(bufio.NewReader(bytes.NewReader([]byte{0x30, 0xFF, 0xFF, 0xFF, 0xFF}))); err == nil {
+		t.Error("read a remaining length of 5 bytes")
+	}
+}
+
+func TestPublish(t *testing.T) {
+	b := newBroker(t)
+	client, err := Dial(b.address(), WithClientID("gateway-7"), WithCredentials("user", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.mu.Lock()
+	connect := b.connect
+	b.mu.Unlock()
+	// Protocol name and level, user name, password and clean session flags,
+	// a minute of keep-alive, then the payload
+	expected := append([]byte{0, 4, 'M', 'Q', 'T', 'T', 4, 0b1100_0010, 0, 60}, "\x00\x09gateway-7\x00\x04user\x00\x06secret"...)
+	if !bytes.Equal(connect, expected) {
+		t.Errorf("CONNECT is % X, want % X", connect, expected)
+	}
+
+	for _, qos := range []QoS{QOS_AT_MOST_ONCE, QOS_AT_LEAST_ONCE, QOS_EXACTLY_ONCE} {
+		if err := client.Publish("a/"+qos.String(), []byte(qos.String()), qos, qos == QOS_AT_LEAST_ONCE); err != nil {
+			t.Fatalf("%s: %v", qos, err)
+		}
+		m := <-b.messages
+		if m.topic != "a/"+qos.String() || string(m.payload) != qos.String() || m.qos != qos || m.retain != (qos == QOS_AT_LEAST_ONCE) {
+			t.Errorf("%s: broker got %+v", qos, m)
+		}
+	}
+	if err := client.Publish("a", nil, 3, false); err == nil {
+		t.Error("published with QoS 3")
+	}
+
+	if err := client.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := client.Publish("a", nil, QOS_AT_MOST_ONCE, false); err == nil {
+		t.Error("published after closing")
+	}
+}
+
+func TestConnectionRefused(t *testing.T) {
+	b := newBroker(t)
+	b.refuse = 5
+	if _, err := Dial(b.address()); err == nil || !strings.Contains(err.Error(), "not authorized") {
+		t.Errorf("connecting: %v", err)
+	}
+}
+
+func TestPublishTimeout(t *testing.T) {
+	b := newBroker(t)
+	b.dropAcks = true
+	client, err := Dial(b.address(), WithTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if err := client.Publish("a", nil, QOS_AT_MOST_ONCE, false); err != nil {
+		t.Error(err)
+	}
+	if err := client.Publish("a", nil, QOS_AT_LEAST_ONCE, false); err == nil {
+		t.Error("published without an acknowledgement")
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	b := newBroker(t)
+	client, err := Dial(b.address(), WithKeepAlive(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	time.Sleep(50 * time.Millisecond)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pings == 0 {
+		t.Error("no ping within the keep-alive")
+	}
+}
@@ -0,0 +1,147 @@
+package mqtt
+
+import (
+	"fmt"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+)
+
+type EventType int
+
+const (
+	// The acceleration changed sharply, and hadn't for a while before
+	EVENT_TAP EventType = iota
+	// The acceleration stayed near zero, the device is falling
+	EVENT_FREE_FALL
+	// A Threshold was crossed, either way
+	EVENT_THRESHOLD
+)
+
+func (eventType EventType) String() string {
+	names := [...]string{"tap", "free-fall", "threshold"}
+	if eventType < 0 || int(eventType) >= len(names) {
+		return fmt.Sprintf("EventType(%d)", eventType)
+	}
+	return names[eventType]
+}
+
+func (eventType EventType) MarshalText() ([]byte, error) {
+	if eventType < EVENT_TAP || eventType > EVENT_THRESHOLD {
+		return nil, fmt.Errorf("unknown event type %d", eventType)
+	}
+	return []byte(eventType.String()), nil
+}
+
+func (eventType *EventType) UnmarshalText(text []byte) error {
+	for t := EVENT_TAP; t <= EVENT_THRESHOLD; t++ {
+		if t.String() == string(text) {
+			*eventType = t
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown event type %q", text)
+}
+
+// Event is what is published to the events topic.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	// The change in acceleration of a tap, the acceleration of a free-fall,
+	// both in units of standard gravity, or the measure of a threshold
+	Value float64 `json:"value"`
+	// The name of the threshold crossed, and whether it is now "above" or
+	// "below" it
+	Threshold string `json:"threshold,omitempty"`
+	Direction string `json:"direction,omitempty"`
+}
+
+// Reading is what thresholds are measured on. The magnetic field and heading
+// are only there when the publisher has a magnetometer.
+type Reading struct {
+	Acceleration  lsm303.Vector
+	MagneticField lsm303.Vector
+	Heading       float64
+}
+
+// Threshold publishes an event whenever Measure crosses Level.
+type Threshold struct {
+	Name    string
+	Measure func(Reading) float64
+	Level   float64
+}
+
+// motionDetector finds taps and free-falls in a stream of accelerometer
+// samples, which has to be fast enough to see them. A tap lasts a few ms,
+// polling the accelerometer at its data rate is best.
+type motionDetector struct {
+	tapLevel         float64
+	tapQuiet         time.Duration
+	freeFallLevel    float64
+	freeFallDuration time.Duration
+
+	previous *lsm303.Vector
+	lastTap  time.Time
+	falling  time.Time
+	reported bool
+}
+
+func (d *motionDetector) update(sample lsm303.AccelSample) []Event {
+	var events []Event
+	acceleration := sample.Acceleration
+
+	if d.tapLevel > 0 && d.previous != nil {
+		change := acceleration.Sub(*d.previous).Norm()
+		if change >= d.tapLevel {
+			if sample.Time.Sub(d.lastTap) >= d.tapQuiet {
+				events = append(events, Event{Type: EVENT_TAP, Time: sample.Time, Value: change})
+			}
+			// The ringing after a tap doesn't count as another one
+			d.lastTap = sample.Time
+		}
+	}
+	d.previous = &acceleration
+
+	if d.freeFallLevel > 0 {
+		magnitude := acceleration.Norm()
+		switch {
+		case magnitude >= d.freeFallLevel:
+			d.falling, d.reported = time.Time{}, false
+		case d.falling.IsZero():
+			d.falling = sample.Time
+		}
+		if !d.falling.IsZero() && !d.reported && sample.Time.Sub(d.falling) >= d.freeFallDuration {
+			events = append(events, Event{Type: EVENT_FREE_FALL, Time: sample.Time, Value: magnitude})
+			d.reported = true
+		}
+	}
+	return events
+}
+
+// thresholdDetector remembers which side of each threshold the last reading
+// was on.
+type thresholdDetector struct {
+	thresholds []Threshold
+	above      []*bool
+}
+
+func (d *thresholdDetector) update(reading Reading, at time.Time) []Event {
+	if d.above == nil {
+		d.above = make([]*bool, len(d.thresholds))
+	}
+	var events []Event
+	for i, threshold := range d.thresholds {
+		value := threshold.Measure(reading)
+		above := value > threshold.Level
+		// The first reading only tells the side it starts on
+		if d.above[i] != nil && *d.above[i] != above {
+			direction := "below"
+			if above {
+				direction = "above"
+			}
+			events = append(events, Event{Type: EVENT_THRESHOLD, Time: at, Value: value, Threshold: threshold.Name, Direction: direction})
+		}
+		d.above[i] = &above
+	}
+	return events
+}
@@ -0,0 +1,82 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+)
+
+func TestMotionDetector(t *testing.T) {
+	d := motionDetector{tapLevel: 1.5, tapQuiet: 200 * time.Millisecond, freeFallLevel: 0.3, freeFallDuration: 50 * time.Millisecond}
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	rest, spike, falling := lsm303.Vector{Z: 1}, lsm303.Vector{X: 0.5, Z: 2.8}, lsm303.Vector{Z: 0.05}
+
+	// Every 10ms: a tap with some ringing, another one after the quiet time,
+	// then a fall for 100ms
+	accelerations := []lsm303.Vector{rest, spike, rest, spike, rest}
+	for i := 0; i < 20; i++ {
+		accelerations = append(accelerations, rest)
+	}
+	accelerations = append(accelerations, spike, rest)
+	for i := 0; i < 10; i++ {
+		accelerations = append(accelerations, falling)
+	}
+	accelerations = append(accelerations, rest)
+
+	var events []Event
+	for i, acceleration := range accelerations {
+		events = append(events, d.update(lsm303.AccelSample{Time: start.Add(time.Duration(i) * 10 * time.Millisecond), Acceleration: acceleration})...)
+	}
+
+	expected := []Event{
+		{Type: EVENT_TAP, Time: start.Add(10 * time.Millisecond)},
+		{Type: EVENT_TAP, Time: start.Add(250 * time.Millisecond)},
+		{Type: EVENT_FREE_FALL, Time: start.Add(320 * time.Millisecond), Value: 0.05},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("detected %+v", events)
+	}
+	for i, event := range events {
+		if event.Type != expected[i].Type || !event.Time.Equal(expected[i].Time) || event.Type == EVENT_FREE_FALL && event.Value != expected[i].Value {
+			t.Errorf("event %d is %+v, want %+v", i, event, expected[i])
+		}
+	}
+	if events[0].Value < 1.5 {
+		t.Errorf("tap of %vg", events[0].Value)
+	}
+}
+
+func TestThresholdDetector(t *testing.T) {
+	d := thresholdDetector{thresholds: []Threshold{
+		{Name: "heading", Measure: func(r Reading) float64 { return r.Heading }, Level: 90},
+		{Name: "field", Measure: func(r Reading) float64 { return r.MagneticField.Norm() }, Level: 0.6},
+	}}
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	// Starting above doesn't count as a crossing
+	if events := d.update(Reading{Heading: 100, MagneticField: lsm303.Vector{X: 0.5}}, at); len(events) != 0 {
+		t.Errorf("detected %+v", events)
+	}
+	events := d.update(Reading{Heading: 80, MagneticField: lsm303.Vector{X: 0.7}}, at)
+	if len(events) != 2 || events[0] != (Event{Type: EVENT_THRESHOLD, Time: at, Value: 80, Threshold: "heading", Direction: "below"}) ||
+		events[1] != (Event{Type: EVENT_THRESHOLD, Time: at, Value: 0.7, Threshold: "field", Direction: "above"}) {
+		t.Errorf("detected %+v", events)
+	}
+	if events := d.update(Reading{Heading: 85, MagneticField: lsm303.Vector{X: 0.7}}, at); len(events) != 0 {
+		t.Errorf("detected %+v", events)
+	}
+}
+
+func TestEventTypeText(t *testing.T) {
+	var decoded EventType
+	if text, err := EVENT_FREE_FALL.MarshalText(); err != nil || decoded.UnmarshalText(text) != nil || decoded != EVENT_FREE_FALL {
+		t.Errorf("free-fall marshalled to %q, %v, and back to %s", text, err, decoded)
+	}
+	if name := EventType(7).String(); name != "EventType(7)" {
+		t.Errorf("unknown event type is %q", name)
+	}
+	if _, err := EventType(7).MarshalText(); err == nil {
+		t.Error("marshalled an unknown event type")
+	}
+}
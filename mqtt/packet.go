@@ -0,0 +1,118 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types, the high nibble of the first byte.
+const (
+	packetConnect    = 1
+	packetConnack    = 2
+	packetPublish    = 3
+	packetPuback     = 4
+	packetPubrec     = 5
+	packetPubrel     = 6
+	packetPubcomp    = 7
+	packetPingreq    = 12
+	packetPingresp   = 13
+	packetDisconnect = 14
+)
+
+// packet is a control packet, the fixed header flags and the rest of it.
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+func (p packet) encode() []byte {
+	encoded := []byte{p.kind<<4 | p.flags}
+	// The remaining length is 7 bits per byte, least significant first
+	length := len(p.body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		encoded = append(encoded, digit)
+		if length == 0 {
+			break
+		}
+	}
+	return append(encoded, p.body...)
+}
+
+func readPacket(r *bufio.Reader) (packet, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		digit, err := r.ReadByte()
+		if err != nil {
+			return packet{}, unexpected(err)
+		}
+		length += int(digit&0x7F) * multiplier
+		if digit&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return packet{}, errors.New("mqtt: malformed remaining length")
+		}
+		multiplier *= 128
+	}
+	p := packet{kind: first >> 4, flags: first & 0x0F, body: make([]byte, length)}
+	if _, err := io.ReadFull(r, p.body); err != nil {
+		return packet{}, unexpected(err)
+	}
+	return p, nil
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// readString splits a length prefixed string off b.
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 || len(b) < 2+int(binary.BigEndian.Uint16(b)) {
+		return "", nil, errors.New("mqtt: truncated string")
+	}
+	length := 2 + int(binary.BigEndian.Uint16(b))
+	return string(b[2:length]), b[length:], nil
+}
+
+// ackPacket is a PUBACK, PUBREC, PUBREL or PUBCOMP, which are only a packet
+// identifier.
+func ackPacket(kind byte, id uint16) packet {
+	p := packet{kind: kind, body: binary.BigEndian.AppendUint16(nil, id)}
+	if kind == packetPubrel {
+		p.flags = 0b0010
+	}
+	return p
+}
+
+func publishPacket(topic string, payload []byte, qos QoS, retain bool, id uint16) packet {
+	p := packet{kind: packetPublish, flags: byte(qos) << 1}
+	if retain {
+		p.flags |= 1
+	}
+	p.body = appendString(nil, topic)
+	if qos > QOS_AT_MOST_ONCE {
+		p.body = binary.BigEndian.AppendUint16(p.body, id)
+	}
+	p.body = append(p.body, payload...)
+	return p
+}
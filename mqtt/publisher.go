@@ -0,0 +1,342 @@
+// Package mqtt publishes the samples, heading and motion events of an LSM303
+// to an MQTT broker. It has its own minimal MQTT 3.1.1 client, which can only
+// publish, so there are no dependencies to vendor on a gateway.
+//
+// Messages are JSON, on these topics by default:
+//
+//	lsm303/config   the sensor type and settings, retained
+//	lsm303/samples  acceleration and magnetic field every sample interval
+//	lsm303/heading  the tilt-compensated heading, with the samples
+//	lsm303/events   taps, free-falls and threshold crossings
+//
+//	client, _ := mqtt.Dial("broker:1883", mqtt.WithClientID("gateway-7"))
+//	publisher, _ := mqtt.NewPublisher(client, accelerometer, magnetometer,
+//		mqtt.WithTopics(mqtt.DefaultTopics("gateways/7")))
+//	publisher.Run(ctx)
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+)
+
+// Topic is where a kind of message is published, and how.
+type Topic struct {
+	Name string
+	QoS  QoS
+}
+
+// Topics are the topics of each kind of message. Leaving a name empty turns
+// that kind off. The config is always retained.
+type Topics struct {
+	Config  Topic
+	Samples Topic
+	Heading Topic
+	Events  Topic
+}
+
+// DefaultTopics puts the topics under prefix. Samples and heading are sent at
+// most once, as there is another one soon, events and the config at least
+// once.
+func DefaultTopics(prefix string) Topics {
+	return Topics{
+		Config:  Topic{Name: prefix + "/config", QoS: QOS_AT_LEAST_ONCE},
+		Samples: Topic{Name: prefix + "/samples", QoS: QOS_AT_MOST_ONCE},
+		Heading: Topic{Name: prefix + "/heading", QoS: QOS_AT_MOST_ONCE},
+		Events:  Topic{Name: prefix + "/events", QoS: QOS_AT_LEAST_ONCE},
+	}
+}
+
+// Config is what is published to the config topic. Settings of a missing
+// sensor, and the gain where it is fixed, are left out.
+type Config struct {
+	SensorType lsm303.SensorType          `json:"sensor_type"`
+	Range      *lsm303.AccelerometerRange `json:"range,omitempty"`
+	Mode       string                     `json:"mode,omitempty"`
+	Gain       *lsm303.MagnetometerGain   `json:"gain,omitempty"`
+	// The magnetometer output data rate in Hz
+	Rate float64 `json:"rate,omitempty"`
+}
+
+// Sample is what is published to the samples topic.
+type Sample struct {
+	Time time.Time `json:"time"`
+	// In units of standard gravity
+	Acceleration *lsm303.Vector `json:"acceleration,omitempty"`
+	// In gauss
+	MagneticField *lsm303.Vector `json:"magnetic_field,omitempty"`
+}
+
+// Heading is what is published to the heading topic.
+type Heading struct {
+	Time time.Time `json:"time"`
+	// Tilt-compensated, in degrees clockwise from magnetic north
+	Heading float64 `json:"heading"`
+}
+
+// Publisher reads the sensors and publishes what they measure.
+type Publisher struct {
+	client         *Client
+	accelerometer  *lsm303.Accelerometer
+	magnetometer   *lsm303.Magnetometer
+	compass        *lsm303.Compass
+	topics         Topics
+	sampleInterval time.Duration
+	pollInterval   time.Duration
+	motion         motionDetector
+	thresholds     thresholdDetector
+}
+
+// NewPublisher creates a publisher for the given sensors, either can be nil.
+// When both are given the heading is published too, from a default compass
+// unless WithCompass is used. Taps and free-falls need the accelerometer.
+func NewPublisher(client *Client, accelerometer *lsm303.Accelerometer, magnetometer *lsm303.Magnetometer, opts ...PublisherOption) (*Publisher, error) {
+	if accelerometer == nil && magnetometer == nil {
+		return nil, errors.New("no sensor to publish")
+	}
+	p := &Publisher{
+		client:         client,
+		accelerometer:  accelerometer,
+		magnetometer:   magnetometer,
+		topics:         DefaultTopics("lsm303"),
+		sampleInterval: time.Second,
+		pollInterval:   10 * time.Millisecond,
+		motion: motionDetector{
+			tapLevel:         1.5,
+			tapQuiet:         200 * time.Millisecond,
+			freeFallLevel:    0.3,
+			freeFallDuration: 50 * time.Millisecond,
+		},
+	}
+	for i := range opts {
+		opts[i].Apply(p)
+	}
+
+	if p.compass == nil && accelerometer != nil && magnetometer != nil {
+		compass, err := lsm303.NewCompass(accelerometer, magnetometer)
+		if err != nil {
+			return nil, err
+		}
+		p.compass = compass
+	}
+	return p, nil
+}
+
+// Run publishes the config, then samples and events until the context is
+// done. It stops at the first failed read or publish.
+func (p *Publisher) Run(ctx context.Context) error {
+	if err := p.PublishConfig(); err != nil {
+		return err
+	}
+	if err := p.publishSample(); err != nil {
+		return err
+	}
+
+	samples := time.NewTicker(p.sampleInterval)
+	defer samples.Stop()
+	var poll <-chan time.Time
+	if p.accelerometer != nil && p.pollInterval > 0 && (p.motion.tapLevel > 0 || p.motion.freeFallLevel > 0) {
+		ticker := time.NewTicker(p.pollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-samples.C:
+			err = p.publishSample()
+		case <-poll:
+			err = p.detectMotion()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// PublishConfig publishes the settings read from the sensors, retained. Call
+// it after changing them while Run is publishing.
+func (p *Publisher) PublishConfig() error {
+	if p.topics.Config.Name == "" {
+		return nil
+	}
+
+	var config Config
+	if p.accelerometer != nil {
+		config.SensorType = p.accelerometer.SensorType()
+		range_, err := p.accelerometer.GetRange()
+		if err != nil {
+			return err
+		}
+		mode, err := p.accelerometer.GetMode()
+		if err != nil {
+			return err
+		}
+		config.Range, config.Mode = &range_, strings.ReplaceAll(mode.String(), " ", "-")
+	}
+	if p.magnetometer != nil {
+		config.SensorType = p.magnetometer.SensorType()
+		// The gain is fixed on the AGR and C
+		if config.SensorType == lsm303.LSM303DLHC {
+			gain, err := p.magnetometer.GetGain()
+			if err != nil {
+				return err
+			}
+			config.Gain = &gain
+		}
+		rate, err := p.magnetometer.GetRate()
+		if err != nil {
+			return err
+		}
+		config.Rate, _ = rate.Hertz(config.SensorType)
+	}
+	return p.publish(p.topics.Config, config, true)
+}
+
+func (p *Publisher) publishSample() error {
+	var sample Sample
+	var reading Reading
+	if p.accelerometer != nil {
+		accel, err := p.accelerometer.SenseSample()
+		if err != nil {
+			return err
+		}
+		sample.Time, sample.Acceleration = accel.Time, &accel.Acceleration
+		reading.Acceleration = accel.Acceleration
+	}
+	if p.magnetometer != nil {
+		mag, err := p.magnetometer.SenseSample()
+		if err != nil {
+			return err
+		}
+		if sample.Time.IsZero() {
+			sample.Time = mag.Time
+		}
+		sample.MagneticField = &mag.MagneticField
+		reading.MagneticField = mag.MagneticField
+	}
+	if err := p.publish(p.topics.Samples, sample, false); err != nil {
+		return err
+	}
+
+	if p.compass != nil {
+		heading, err := p.compass.Heading()
+		if err != nil {
+			return err
+		}
+		reading.Heading = heading
+		if err := p.publish(p.topics.Heading, Heading{Time: sample.Time, Heading: heading}, false); err != nil {
+			return err
+		}
+	}
+	return p.publishEvents(p.thresholds.update(reading, sample.Time))
+}
+
+func (p *Publisher) detectMotion() error {
+	sample, err := p.accelerometer.SenseSample()
+	if err != nil {
+		return err
+	}
+	return p.publishEvents(p.motion.update(sample))
+}
+
+func (p *Publisher) publishEvents(events []Event) error {
+	for _, event := range events {
+		if err := p.publish(p.topics.Events, event, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Publisher) publish(topic Topic, message any, retain bool) error {
+	if topic.Name == "" {
+		return nil
+	}
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return p.client.Publish(topic.Name, payload, topic.QoS, retain)
+}
+
+type (
+	// PublisherOption configures a Publisher.
+	PublisherOption interface {
+		Apply(*Publisher)
+	}
+	// PublisherOptionFunc is a function that configures a publisher.
+	PublisherOptionFunc func(*Publisher)
+)
+
+// Apply calls PublisherOptionFunc on publisher instance
+func (f PublisherOptionFunc) Apply(p *Publisher) {
+	f(p)
+}
+
+// WithTopics sets the topics to publish to.
+// Default is DefaultTopics("lsm303").
+func WithTopics(topics Topics) PublisherOption {
+	return PublisherOptionFunc(func(p *Publisher) {
+		p.topics = topics
+	})
+}
+
+// WithSampleInterval sets how often the samples and heading are published,
+// and thresholds checked. Default is a second.
+func WithSampleInterval(interval time.Duration) PublisherOption {
+	return PublisherOptionFunc(func(p *Publisher) {
+		p.sampleInterval = interval
+	})
+}
+
+// WithPollInterval sets how often the accelerometer is read to look for taps
+// and free-falls, which should be about its data rate. Default is 10ms.
+func WithPollInterval(interval time.Duration) PublisherOption {
+	return PublisherOptionFunc(func(p *Publisher) {
+		p.pollInterval = interval
+	})
+}
+
+// WithTap sets the change in acceleration between two polls, in units of
+// standard gravity, that is a tap, and how long after one another one isn't
+// counted. Zero turns taps off. Default is 1.5g and 200ms.
+func WithTap(level float64, quiet time.Duration) PublisherOption {
+	return PublisherOptionFunc(func(p *Publisher) {
+		p.motion.tapLevel, p.motion.tapQuiet = level, quiet
+	})
+}
+
+// WithFreeFall sets the acceleration, in units of standard gravity, below
+// which the device is falling, and for how long it has to stay there. Zero
+// turns free-falls off. Default is 0.3g for 50ms.
+func WithFreeFall(level float64, duration time.Duration) PublisherOption {
+	return PublisherOptionFunc(func(p *Publisher) {
+		p.motion.freeFallLevel, p.motion.freeFallDuration = level, duration
+	})
+}
+
+// WithThreshold publishes an event whenever the threshold is crossed. Can be
+// used several times.
+func WithThreshold(threshold Threshold) PublisherOption {
+	return PublisherOptionFunc(func(p *Publisher) {
+		p.thresholds.thresholds = append(p.thresholds.thresholds, threshold)
+	})
+}
+
+// WithCompass publishes the heading of a compass built elsewhere, such as
+// one over remapped or calibrated handles other than those given to
+// NewPublisher. The heading stays magnetic.
+func WithCompass(compass *lsm303.Compass) PublisherOption {
+	return PublisherOptionFunc(func(p *Publisher) {
+		p.compass = compass
+	})
+}
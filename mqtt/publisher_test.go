@@ -0,0 +1,136 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+	"github.com/timoth-y/go-lsm303/simulator"
+)
+
+func TestPublisher(t *testing.T) {
+	// Still for 100ms, dropped for 100ms, then tapped on the table
+	falling := simulator.Level
+	falling.Acceleration = lsm303.Vector{}
+	tapped := simulator.Level
+	tapped.Acceleration = lsm303.Vector{Z: 3}
+	script := []simulator.Sample{}
+	for i := 0; i < 10; i++ {
+		script = append(script, simulator.Level)
+	}
+	for i := 0; i < 10; i++ {
+		script = append(script, falling)
+	}
+	for i := 0; i < 10; i++ {
+		script = append(script, simulator.Level)
+	}
+	script = append(script, tapped, tapped, simulator.Level)
+
+	// From when the publisher starts rather than when the bus is created
+	var started time.Time
+	source := simulator.Script(10*time.Millisecond, script...)
+	bus, err := simulator.New(lsm303.LSM303DLHC, simulator.SourceFunc(func(time.Duration) simulator.Sample {
+		return source.Sample(time.Since(started))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	accelerometer, err := lsm303.NewAccelerometer(bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	magnetometer, err := lsm303.NewMagnetometer(bus, lsm303.WithRate(lsm303.MAGNETOMETER_RATE_220))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := newBroker(t)
+	client, err := Dial(b.address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher, err := NewPublisher(client, accelerometer, magnetometer,
+		WithTopics(DefaultTopics("gateways/7")),
+		WithSampleInterval(20*time.Millisecond),
+		WithPollInterval(2*time.Millisecond),
+		WithThreshold(Threshold{Name: "level", Measure: func(r Reading) float64 { return r.Acceleration.Z }, Level: 0.5}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 450*time.Millisecond)
+	defer cancel()
+	started = time.Now()
+	if err := publisher.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	b.closed()
+
+	config := <-b.messages
+	var decoded Config
+	if err := json.Unmarshal(config.payload, &decoded); err != nil || config.topic != "gateways/7/config" || !config.retain || config.qos != QOS_AT_LEAST_ONCE {
+		t.Fatalf("first message %+v, %v", config, err)
+	}
+	if decoded.SensorType != lsm303.LSM303DLHC || decoded.Mode != "normal" || decoded.Gain == nil || decoded.Rate != 220 {
+		t.Errorf("unexpected config %s", config.payload)
+	}
+
+	var samples, headings int
+	var events []Event
+	for m := range b.messages {
+		switch m.topic {
+		case "gateways/7/samples":
+			var sample Sample
+			if err := json.Unmarshal(m.payload, &sample); err != nil || sample.Acceleration == nil || sample.MagneticField == nil || m.retain {
+				t.Errorf("unexpected sample %s", m.payload)
+			}
+			samples++
+		case "gateways/7/heading":
+			var heading Heading
+			if err := json.Unmarshal(m.payload, &heading); err != nil || math.Abs(math.Mod(heading.Heading+180, 360)-180) > 1 {
+				t.Errorf("unexpected heading %s", m.payload)
+			}
+			headings++
+		case "gateways/7/events":
+			var event Event
+			if err := json.Unmarshal(m.payload, &event); err != nil || m.qos != QOS_AT_LEAST_ONCE {
+				t.Errorf("unexpected event %s", m.payload)
+			}
+			events = append(events, event)
+		default:
+			t.Errorf("published to %s", m.topic)
+		}
+	}
+
+	if samples < 10 || headings != samples {
+		t.Errorf("%d samples and %d headings in 450ms", samples, headings)
+	}
+	var types []EventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	expected := []EventType{EVENT_THRESHOLD, EVENT_FREE_FALL, EVENT_THRESHOLD, EVENT_TAP}
+	if len(types) != len(expected) {
+		t.Fatalf("events %+v", events)
+	}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Errorf("events %+v", events)
+			break
+		}
+	}
+	if events[0].Direction != "below" || events[2].Direction != "above" {
+		t.Errorf("threshold events %+v", events)
+	}
+}
+
+func TestNewPublisherWithoutSensors(t *testing.T) {
+	if _, err := NewPublisher(nil, nil, nil); err == nil {
+		t.Error("created a publisher without sensors")
+	}
+}
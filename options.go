@@ -1,5 +1,12 @@
 package lsm303
 
+import (
+	"fmt"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+)
+
 type (
 	// AccelerometerOption configures a LSM303 accelerometer.
 	AccelerometerOption interface {
@@ -14,6 +21,35 @@ type (
 	}
 	// MagnetometerOptionFunc is a function that configures a device.
 	MagnetometerOptionFunc func(*Magnetometer)
+
+	// CompassOption configures a Compass.
+	CompassOption interface {
+		Apply(*Compass)
+	}
+	// CompassOptionFunc is a function that configures a compass.
+	CompassOptionFunc func(*Compass)
+
+	// InclinometerOption configures an Inclinometer.
+	InclinometerOption interface {
+		Apply(*Inclinometer)
+	}
+	// InclinometerOptionFunc is a function that configures an inclinometer.
+	InclinometerOptionFunc func(*Inclinometer)
+
+	// LinearAccelerometerOption configures a LinearAccelerometer.
+	LinearAccelerometerOption interface {
+		Apply(*LinearAccelerometer)
+	}
+	// LinearAccelerometerOptionFunc is a function that configures a linear
+	// accelerometer.
+	LinearAccelerometerOptionFunc func(*LinearAccelerometer)
+
+	// DeviceOption configures a Device.
+	DeviceOption interface {
+		Apply(*Device)
+	}
+	// DeviceOptionFunc is a function that configures a device.
+	DeviceOptionFunc func(*Device)
 )
 
 type SensorType string
@@ -46,9 +82,29 @@ func (mode AccelerometerMode) String() string {
 }
 
 func (range_ AccelerometerRange) String() string {
+	if range_ < ACCELEROMETER_RANGE_2G || range_ > ACCELEROMETER_RANGE_16G {
+		return fmt.Sprintf("AccelerometerRange(%d)", range_)
+	}
 	return [...]string{"2G", "4G", "8G", "16G"}[range_]
 }
 
+func (range_ AccelerometerRange) MarshalText() ([]byte, error) {
+	if range_ < ACCELEROMETER_RANGE_2G || range_ > ACCELEROMETER_RANGE_16G {
+		return nil, fmt.Errorf("unknown accelerometer range %d", range_)
+	}
+	return []byte(range_.String()), nil
+}
+
+func (range_ *AccelerometerRange) UnmarshalText(text []byte) error {
+	for r := ACCELEROMETER_RANGE_2G; r <= ACCELEROMETER_RANGE_16G; r++ {
+		if r.String() == string(text) {
+			*range_ = r
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown accelerometer range %q", text)
+}
+
 
 // Apply calls OptionFunc on device instance
 func (f AccelerometerOptionFunc) Apply(dev *Accelerometer) {
@@ -60,6 +116,26 @@ func (f MagnetometerOptionFunc) Apply(dev *Magnetometer) {
 	f(dev)
 }
 
+// Apply calls OptionFunc on compass instance
+func (f CompassOptionFunc) Apply(c *Compass) {
+	f(c)
+}
+
+// Apply calls OptionFunc on inclinometer instance
+func (f InclinometerOptionFunc) Apply(i *Inclinometer) {
+	f(i)
+}
+
+// Apply calls OptionFunc on linear accelerometer instance
+func (f LinearAccelerometerOptionFunc) Apply(l *LinearAccelerometer) {
+	f(l)
+}
+
+// Apply calls OptionFunc on device instance
+func (f DeviceOptionFunc) Apply(d *Device) {
+	f(d)
+}
+
 // WithAccelerometerSensorType can be used to specify LSM303 family sensor type.
 // Default is LSM303DLHC.
 func WithAccelerometerSensorType(sensorType SensorType) AccelerometerOption {
@@ -92,6 +168,34 @@ func WithRange(rng AccelerometerRange) AccelerometerOption {
 	})
 }
 
+// WithAccelerometerCurrentConfiguration can be used to open an accelerometer
+// that is already running without changing it: the range, mode and data rate
+// are read from the chip instead of written, and WithRange and WithMode don't
+// apply. A powered down accelerometer is set up as usual.
+func WithAccelerometerCurrentConfiguration() AccelerometerOption {
+	return AccelerometerOptionFunc(func(d *Accelerometer) {
+		d.current = true
+	})
+}
+
+// WithAccelerometerCalibration can be used to apply bias and scale
+// corrections, see AccelerometerCalibrator.
+func WithAccelerometerCalibration(calibration AccelerometerCalibration) AccelerometerOption {
+	return AccelerometerOptionFunc(func(d *Accelerometer) {
+		d.calibration = &calibration
+	})
+}
+
+// WithAccelerometerAxisRemap can be used to rotate readings from the sensor
+// axes into the body frame, when the board isn't mounted X forward, Z up.
+// Calibrations are taken in the remapped frame, so keep the remap fixed once
+// calibrated.
+func WithAccelerometerAxisRemap(remap AxisRemap) AccelerometerOption {
+	return AccelerometerOptionFunc(func(d *Accelerometer) {
+		d.remap = &remap
+	})
+}
+
 type MagnetometerGain int
 
 const (
@@ -108,6 +212,23 @@ func (mode MagnetometerGain) String() string {
 	return [...]string{"1.3", "1.9", "2.5", "4.0", "4.7", "5.6", "8.1"}[mode]
 }
 
+func (gain MagnetometerGain) MarshalText() ([]byte, error) {
+	if gain < MAGNETOMETER_GAIN_1_3 || gain > MAGNETOMETER_GAIN_8_1 {
+		return nil, fmt.Errorf("unknown magnetometer gain %d", gain)
+	}
+	return []byte(gain.String()), nil
+}
+
+func (gain *MagnetometerGain) UnmarshalText(text []byte) error {
+	for g := MAGNETOMETER_GAIN_1_3; g <= MAGNETOMETER_GAIN_8_1; g++ {
+		if g.String() == string(text) {
+			*gain = g
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown magnetometer gain %q", text)
+}
+
 type MagnetometerRate int
 
 const (
@@ -149,16 +270,47 @@ func WithGain(gain MagnetometerGain) MagnetometerOption {
 	})
 }
 
-// WithRange can be used to specify magnetometer rate.
-// Default is MAGNETOMETER_RATE_30.
+// WithRange can be used to specify magnetometer rate. The setting must be one
+// the variant has, see MagnetometerRateOf.
+// Default is 30 Hz on the LSM303DLHC, 20 Hz on the LSM303AGR and LSM303C.
 func WithRate(rate MagnetometerRate) MagnetometerOption {
 	return MagnetometerOptionFunc(func(d *Magnetometer) {
 		d.rate = rate
 	})
 }
 
+// WithMagnetometerCurrentConfiguration can be used to open a magnetometer in
+// continuous conversion without changing it: the gain and rate are read from
+// the chip instead of written, and WithGain and WithRate don't apply. An idle
+// magnetometer is set up as usual.
+func WithMagnetometerCurrentConfiguration() MagnetometerOption {
+	return MagnetometerOptionFunc(func(d *Magnetometer) {
+		d.current = true
+	})
+}
+
+// WithMagnetometerCalibration can be used to apply hard-iron and soft-iron
+// corrections, see MagnetometerCalibrator.
+func WithMagnetometerCalibration(calibration MagnetometerCalibration) MagnetometerOption {
+	return MagnetometerOptionFunc(func(d *Magnetometer) {
+		d.calibration = &calibration
+	})
+}
+
+// WithMagnetometerAxisRemap can be used to rotate readings from the sensor
+// axes into the body frame, when the board isn't mounted X forward, Z up.
+// Calibrations are taken in the remapped frame, so keep the remap fixed once
+// calibrated.
+func WithMagnetometerAxisRemap(remap AxisRemap) MagnetometerOption {
+	return MagnetometerOptionFunc(func(d *Magnetometer) {
+		d.remap = &remap
+	})
+}
+
 // WithDatasheet can be used to specify datasheet addresses,
 // in case new LSM family device appears.
+// The bitfields the driver uses move with the control and status registers,
+// and the data ready bit follows DRDY_M.
 func WithDatasheet(datasheet MagnetometerDatasheet) MagnetometerOption {
 	return MagnetometerOptionFunc(func(d *Magnetometer) {
 		d.datasheet = &datasheet
@@ -190,7 +342,8 @@ type MagnetometerOpts struct {
 	Rate MagnetometerRate
 }
 
-// DefaultMagnetometerOpts is the recommended default options.
+// DefaultMagnetometerOpts is the recommended default options. The rate is a
+// LSM303DLHC one, use WithRate on the other variants.
 var DefaultMagnetometerOpts = MagnetometerOpts{
 	Gain: MAGNETOMETER_GAIN_4_0,
 	Rate: MAGNETOMETER_RATE_30,
@@ -200,3 +353,118 @@ func (mo MagnetometerOpts) Apply(magnetometer *Magnetometer) {
 	magnetometer.gain = mo.Gain
 	magnetometer.rate = mo.Rate
 }
+
+// AxisConvention names a body frame, to convert vectors between them. The
+// sensors read in the NWU frame, once their axis remap is applied: a board
+// mounted upside down is AXIS_REMAP_X_FORWARD_Z_DOWN rather than NED.
+type AxisConvention int
+
+const (
+	// X forward, Y left, Z up. This is how the LSM303 axes are printed on
+	// most breakout boards when the chip faces up, and the body frame.
+	AXIS_CONVENTION_NWU AxisConvention = iota
+	// X forward, Y right, Z down, the usual aerospace convention.
+	AXIS_CONVENTION_NED
+	// X right, Y forward, Z up, common in mobile and AR code.
+	AXIS_CONVENTION_ENU
+)
+
+func (axes AxisConvention) String() string {
+	names := [...]string{"NWU", "NED", "ENU"}
+	if axes < 0 || int(axes) >= len(names) {
+		return fmt.Sprintf("AxisConvention(%d)", axes)
+	}
+	return names[axes]
+}
+
+// WithDeclination can be used to specify a fixed magnetic declination in
+// degrees (positive east) used by TrueHeading.
+func WithDeclination(declination float64) CompassOption {
+	return CompassOptionFunc(func(c *Compass) {
+		c.declination = &declination
+	})
+}
+
+// WithLocation can be used to let the compass compute the declination from the
+// World Magnetic Model for the given latitude, longitude (degrees) and altitude.
+func WithLocation(latitude, longitude float64, altitude physic.Distance) CompassOption {
+	return CompassOptionFunc(func(c *Compass) {
+		c.location = &geoLocation{latitude, longitude, altitude}
+	})
+}
+
+// WithMagneticModel can be used to specify a magnetic model other than the
+// embedded ones, e.g. a newer WMM.COF loaded with ParseMagneticModel. Default
+// is the embedded model for the date.
+func WithMagneticModel(model *MagneticModel) CompassOption {
+	return CompassOptionFunc(func(c *Compass) {
+		c.model = model
+	})
+}
+
+// WithAveraging can be used to specify how many fresh accelerometer readings
+// are averaged for each tilt reading. Default is 1.
+func WithAveraging(samples int) InclinometerOption {
+	return InclinometerOptionFunc(func(i *Inclinometer) {
+		i.samples = samples
+	})
+}
+
+// WithTiltReference can be used to restore a reference captured by Zero on a
+// previous run: down is the direction of gravity in the sensor frame that
+// should count as level, the opposite of what the accelerometer reads at rest.
+func WithTiltReference(down Vector) InclinometerOption {
+	return InclinometerOptionFunc(func(i *Inclinometer) {
+		i.referenceDown = &down
+	})
+}
+
+// WithTiltThreshold can be used to get alert called whenever the tilt angle
+// goes beyond threshold degrees.
+func WithTiltThreshold(threshold float64, alert func(Tilt)) InclinometerOption {
+	return InclinometerOptionFunc(func(i *Inclinometer) {
+		i.threshold = threshold
+		i.alert = alert
+	})
+}
+
+// WithGravityTimeConstant can be used to specify how fast the low-pass gravity
+// estimate follows the accelerometer. Motion slower than this is taken for
+// gravity. Default is 1s.
+func WithGravityTimeConstant(timeConstant time.Duration) LinearAccelerometerOption {
+	return LinearAccelerometerOptionFunc(func(l *LinearAccelerometer) {
+		l.timeConstant = timeConstant
+	})
+}
+
+// WithGravityEstimator can be used to take gravity from an orientation filter
+// instead of low-pass tracking, e.g. an orientation.Filter.
+func WithGravityEstimator(estimator GravityEstimator) LinearAccelerometerOption {
+	return LinearAccelerometerOptionFunc(func(l *LinearAccelerometer) {
+		l.estimator = estimator
+	})
+}
+
+// WithDeviceSensorType can be used to specify the LSM303 variant of a Device.
+// Default is to Detect it.
+func WithDeviceSensorType(sensorType SensorType) DeviceOption {
+	return DeviceOptionFunc(func(d *Device) {
+		d.sensorType = sensorType
+	})
+}
+
+// WithAccelerometerOptions can be used to configure the accelerometer of a
+// Device. The sensor type is the device's.
+func WithAccelerometerOptions(opts ...AccelerometerOption) DeviceOption {
+	return DeviceOptionFunc(func(d *Device) {
+		d.accelerometerOptions = append(d.accelerometerOptions, opts...)
+	})
+}
+
+// WithMagnetometerOptions can be used to configure the magnetometer of a
+// Device. The sensor type is the device's.
+func WithMagnetometerOptions(opts ...MagnetometerOption) DeviceOption {
+	return DeviceOptionFunc(func(d *Device) {
+		d.magnetometerOptions = append(d.magnetometerOptions, opts...)
+	})
+}
@@ -0,0 +1,346 @@
+// Package orientation estimates the attitude of an LSM303 board from its
+// accelerometer and magnetometer alone, using Madgwick, Mahony or a simple
+// complementary filter.
+//
+// There is no gyroscope, so the filters can only smooth the absolute
+// orientation given by gravity and the magnetic field; they don't reject
+// linear acceleration the way a full AHRS would.
+//
+// The samples are taken in the body frame the sensors read in, X forward, Y
+// left and Z up once their axis remap is applied. Everything is expressed in
+// the NED frame: the quaternion rotates the body frame (x forward, y right, z
+// down) into the earth frame (north, east, down).
+package orientation
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+)
+
+// Algorithm selects how a Filter blends new measurements into its estimate.
+type Algorithm int
+
+const (
+	MADGWICK Algorithm = iota
+	MAHONY
+	COMPLEMENTARY
+)
+
+func (algorithm Algorithm) String() string {
+	return [...]string{"Madgwick", "Mahony", "complementary"}[algorithm]
+}
+
+// Updates further apart than this are treated as a restart rather than
+// integrated over.
+const maxStep = time.Second
+
+// Filter fuses accelerometer and magnetometer samples into an orientation.
+// Samples of each sensor can arrive at their own rate, the filter always uses
+// the latest one of the other sensor. It is safe for concurrent use.
+type Filter struct {
+	mu sync.Mutex
+
+	algorithm    Algorithm
+	beta         float64
+	kp           float64
+	ki           float64
+	timeConstant time.Duration
+
+	q        Quaternion
+	integral lsm303.Vector
+
+	acceleration lsm303.Vector
+	field        lsm303.Vector
+	hasAccel     bool
+	hasField     bool
+	// Set once the estimate was seeded from a full accelerometer and
+	// magnetometer measurement.
+	initialized bool
+	last        time.Time
+}
+
+// NewMadgwick creates a Madgwick gradient descent filter. beta is the
+// convergence rate in rad/s, 0.1 to 1 are sensible values.
+func NewMadgwick(beta float64, opts ...Option) *Filter {
+	return newFilter(&Filter{algorithm: MADGWICK, beta: beta}, opts)
+}
+
+// NewMahony creates a Mahony filter with proportional gain kp and integral
+// gain ki (both in 1/s).
+func NewMahony(kp, ki float64, opts ...Option) *Filter {
+	return newFilter(&Filter{algorithm: MAHONY, kp: kp, ki: ki}, opts)
+}
+
+// NewComplementary creates a filter that low-passes the orientation computed
+// directly from each measurement, with the given time constant.
+func NewComplementary(timeConstant time.Duration, opts ...Option) *Filter {
+	return newFilter(&Filter{algorithm: COMPLEMENTARY, timeConstant: timeConstant}, opts)
+}
+
+func newFilter(f *Filter, opts []Option) *Filter {
+	f.q = Identity
+	for i := range opts {
+		opts[i].Apply(f)
+	}
+	return f
+}
+
+// UpdateAccelerometer feeds an accelerometer sample taken at the given time.
+// Only the direction matters, so any unit works.
+func (f *Filter) UpdateAccelerometer(acceleration lsm303.Vector, at time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.acceleration = lsm303.AXIS_CONVENTION_NWU.ToNED(acceleration).Normalize()
+	f.hasAccel = f.acceleration != lsm303.Vector{}
+	f.update(at)
+}
+
+// UpdateMagnetometer feeds a magnetometer sample taken at the given time.
+func (f *Filter) UpdateMagnetometer(field lsm303.Vector, at time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.field = lsm303.AXIS_CONVENTION_NWU.ToNED(field).Normalize()
+	f.hasField = f.field != lsm303.Vector{}
+	f.update(at)
+}
+
+// Quaternion returns the current orientation estimate.
+func (f *Filter) Quaternion() Quaternion {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.q
+}
+
+// Euler returns the current roll, pitch and yaw estimate in degrees.
+func (f *Filter) Euler() (float64, float64, float64) {
+	return f.Quaternion().Euler()
+}
+
+// Gravity returns the estimated direction of gravity in the body frame, as a
+// unit vector. It points the way the accelerometer reads at rest, i.e. up.
+func (f *Filter) Gravity() lsm303.Vector {
+	up := f.Quaternion().Conjugate().Rotate(lsm303.Vector{Z: -1})
+	return lsm303.AXIS_CONVENTION_NWU.FromNED(up)
+}
+
+// Reset forgets the current estimate.
+func (f *Filter) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.q = Identity
+	f.integral = lsm303.Vector{}
+	f.hasAccel, f.hasField, f.initialized = false, false, false
+	f.last = time.Time{}
+}
+
+// Run reads both sensors at their own intervals and feeds the filter until the
+// context is done or a read fails.
+func (f *Filter) Run(ctx context.Context, accelerometer *lsm303.Accelerometer, accelerometerInterval time.Duration, magnetometer *lsm303.Magnetometer, magnetometerInterval time.Duration) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, 2)
+
+	poll := func(interval time.Duration, read func() error) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := read(); err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case <-ctx.Done():
+				errs <- nil
+				return
+			case <-ticker.C:
+			}
+		}
+	}
+
+	go poll(accelerometerInterval, func() error {
+		x, y, z, err := accelerometer.Sense()
+		if err != nil {
+			return err
+		}
+		f.UpdateAccelerometer(lsm303.Vector{X: float64(x), Y: float64(y), Z: float64(z)}, time.Now())
+		return nil
+	})
+	go poll(magnetometerInterval, func() error {
+		x, y, z, err := magnetometer.Sense()
+		if err != nil {
+			return err
+		}
+		f.UpdateMagnetometer(lsm303.Vector{X: float64(x), Y: float64(y), Z: float64(z)}, time.Now())
+		return nil
+	})
+
+	err := <-errs
+	cancel()
+	if second := <-errs; err == nil {
+		err = second
+	}
+	return err
+}
+
+func (f *Filter) update(at time.Time) {
+	if !f.hasAccel {
+		return
+	}
+
+	// Seed the estimate so the filters don't have to crawl from identity
+	if !f.initialized {
+		f.q = measuredOrientation(f.acceleration, f.field, f.hasField)
+		f.initialized = f.hasField
+		f.last = at
+		return
+	}
+
+	dt := at.Sub(f.last)
+	f.last = at
+	if dt <= 0 {
+		return
+	}
+	if dt > maxStep {
+		dt = maxStep
+	}
+
+	switch f.algorithm {
+	case MADGWICK:
+		f.madgwick(dt.Seconds())
+	case MAHONY:
+		f.mahony(dt.Seconds())
+	case COMPLEMENTARY:
+		k := 1 - math.Exp(-dt.Seconds()/f.timeConstant.Seconds())
+		f.q = f.q.Slerp(measuredOrientation(f.acceleration, f.field, f.hasField), k)
+	}
+}
+
+// The specific force measured at rest, in the earth frame.
+var up = lsm303.Vector{Z: -1}
+
+// Reference direction of the magnetic field in the earth frame: the current
+// field rotated into the earth frame, with its horizontal part moved onto
+// north. This makes the filters insensitive to inclination.
+func (f *Filter) fieldReference() lsm303.Vector {
+	h := f.q.Rotate(f.field)
+	return lsm303.Vector{X: math.Hypot(h.X, h.Y), Z: h.Z}
+}
+
+func (f *Filter) madgwick(dt float64) {
+	gradient := objectiveGradient(f.q, up, f.acceleration)
+	if f.hasField {
+		g := objectiveGradient(f.q, f.fieldReference(), f.field)
+		gradient = Quaternion{gradient.W + g.W, gradient.X + g.X, gradient.Y + g.Y, gradient.Z + g.Z}
+	}
+	if n := gradient.Norm(); n > 0 {
+		step := f.beta * dt / n
+		f.q = Quaternion{
+			f.q.W - step*gradient.W,
+			f.q.X - step*gradient.X,
+			f.q.Y - step*gradient.Y,
+			f.q.Z - step*gradient.Z,
+		}.Normalize()
+	}
+}
+
+func (f *Filter) mahony(dt float64) {
+	// Error is the rotation that would bring the estimated directions onto the
+	// measured ones.
+	estimated := f.q.Conjugate().Rotate(up)
+	e := f.acceleration.Cross(estimated)
+	if f.hasField {
+		estimated = f.q.Conjugate().Rotate(f.fieldReference())
+		e = e.Add(f.field.Cross(estimated))
+	}
+
+	if f.ki > 0 {
+		f.integral = f.integral.Add(e.Scale(dt))
+	}
+	omega := e.Scale(f.kp).Add(f.integral.Scale(f.ki))
+
+	rate := f.q.Mul(Quaternion{0, omega.X, omega.Y, omega.Z})
+	f.q = Quaternion{
+		f.q.W + 0.5*rate.W*dt,
+		f.q.X + 0.5*rate.X*dt,
+		f.q.Y + 0.5*rate.Y*dt,
+		f.q.Z + 0.5*rate.Z*dt,
+	}.Normalize()
+}
+
+// Computes Jᵀ f for the objective f(q) = q* ⊗ d ⊗ q - s, i.e. the direction d
+// in the earth frame rotated into the body frame minus its measurement s.
+// This is the gradient of ½|f|² that Madgwick's filter descends.
+func objectiveGradient(q Quaternion, d, s lsm303.Vector) Quaternion {
+	q0, q1, q2, q3 := q.W, q.X, q.Y, q.Z
+	f := objective(q, d, s)
+
+	j := [3][4]float64{
+		{
+			2*d.Y*q3 - 2*d.Z*q2,
+			2*d.Y*q2 + 2*d.Z*q3,
+			-4*d.X*q2 + 2*d.Y*q1 - 2*d.Z*q0,
+			-4*d.X*q3 + 2*d.Y*q0 + 2*d.Z*q1,
+		},
+		{
+			-2*d.X*q3 + 2*d.Z*q1,
+			2*d.X*q2 - 4*d.Y*q1 + 2*d.Z*q0,
+			2*d.X*q1 + 2*d.Z*q3,
+			-2*d.X*q0 - 4*d.Y*q3 + 2*d.Z*q2,
+		},
+		{
+			2*d.X*q2 - 2*d.Y*q1,
+			2*d.X*q3 - 2*d.Y*q0 - 4*d.Z*q1,
+			2*d.X*q0 + 2*d.Y*q3 - 4*d.Z*q2,
+			2*d.X*q1 + 2*d.Y*q2,
+		},
+	}
+
+	residual := [3]float64{f.X, f.Y, f.Z}
+	var gradient [4]float64
+	for i := 0; i < 3; i++ {
+		for k := 0; k < 4; k++ {
+			gradient[k] += j[i][k] * residual[i]
+		}
+	}
+	return Quaternion{gradient[0], gradient[1], gradient[2], gradient[3]}
+}
+
+// The objective function written out the way Madgwick does, which matches
+// q* ⊗ d ⊗ q - s for unit quaternions and is what the Jacobian is derived from.
+func objective(q Quaternion, d, s lsm303.Vector) lsm303.Vector {
+	q0, q1, q2, q3 := q.W, q.X, q.Y, q.Z
+	return lsm303.Vector{
+		X: 2*d.X*(0.5-q2*q2-q3*q3) + 2*d.Y*(q0*q3+q1*q2) + 2*d.Z*(q1*q3-q0*q2) - s.X,
+		Y: 2*d.X*(q1*q2-q0*q3) + 2*d.Y*(0.5-q1*q1-q3*q3) + 2*d.Z*(q0*q1+q2*q3) - s.Y,
+		Z: 2*d.X*(q0*q2+q1*q3) + 2*d.Y*(q2*q3-q0*q1) + 2*d.Z*(0.5-q1*q1-q2*q2) - s.Z,
+	}
+}
+
+// Computes the orientation directly from one pair of measurements (TRIAD).
+// Without a field the heading is arbitrary and the body x axis is taken as
+// north.
+func measuredOrientation(acceleration, field lsm303.Vector, hasField bool) Quaternion {
+	down := acceleration.Scale(-1).Normalize()
+	if !hasField {
+		field = lsm303.Vector{X: 1}
+	}
+	east := down.Cross(field).Normalize()
+	if east == (lsm303.Vector{}) {
+		// Field parallel to gravity, e.g. at the magnetic pole
+		east = down.Cross(lsm303.Vector{Y: 1}).Normalize()
+	}
+	north := east.Cross(down)
+
+	return fromMatrix([3][3]float64{
+		{north.X, north.Y, north.Z},
+		{east.X, east.Y, east.Z},
+		{down.X, down.Y, down.Z},
+	})
+}
@@ -0,0 +1,157 @@
+package orientation
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+)
+
+// Readings of an ideal sensor (NWU axes, like the LSM303) in the given
+// attitude, with a field inclined 60° below the horizon.
+func syntheticReadings(roll, pitch, yaw float64) (lsm303.Vector, lsm303.Vector) {
+	q := FromEuler(roll, pitch, yaw)
+	field := lsm303.Vector{X: math.Cos(radians(60)), Z: math.Sin(radians(60))}.Scale(0.5)
+
+	acceleration := q.Conjugate().Rotate(lsm303.Vector{Z: -1})
+	magnetic := q.Conjugate().Rotate(field)
+	return lsm303.AXIS_CONVENTION_NWU.FromNED(acceleration), lsm303.AXIS_CONVENTION_NWU.FromNED(magnetic)
+}
+
+func angleDiff(a, b float64) float64 {
+	return math.Abs(math.Mod(a-b+540, 360) - 180)
+}
+
+func assertAttitude(t *testing.T, f *Filter, roll, pitch, yaw, tolerance float64) {
+	t.Helper()
+	r, p, y := f.Euler()
+	if angleDiff(r, roll) > tolerance || angleDiff(p, pitch) > tolerance || angleDiff(y, yaw) > tolerance {
+		t.Errorf("%s: expected %.1f %.1f %.1f, got %.1f %.1f %.1f", f.algorithm, roll, pitch, yaw, r, p, y)
+	}
+}
+
+func filters() []*Filter {
+	return []*Filter{
+		NewMadgwick(0.5),
+		NewMahony(5, 0),
+		NewComplementary(200 * time.Millisecond),
+	}
+}
+
+func TestFiltersInitializeFromMeasurement(t *testing.T) {
+	attitudes := [][3]float64{{0, 0, 0}, {10, -20, 45}, {-30, 15, 200}, {5, 60, 300}}
+	for _, f := range filters() {
+		for _, attitude := range attitudes {
+			f.Reset()
+			a, m := syntheticReadings(attitude[0], attitude[1], attitude[2])
+			start := time.Unix(0, 0)
+			f.UpdateMagnetometer(m, start)
+			f.UpdateAccelerometer(a, start)
+			assertAttitude(t, f, attitude[0], attitude[1], attitude[2], 1e-6)
+		}
+	}
+}
+
+func TestFiltersTrackRotation(t *testing.T) {
+	for _, f := range filters() {
+		start := time.Unix(0, 0)
+		a, m := syntheticReadings(0, 0, 0)
+		f.UpdateMagnetometer(m, start)
+		f.UpdateAccelerometer(a, start)
+
+		// Rotate to a new attitude, with the accelerometer at 100 Hz and the
+		// magnetometer at 30 Hz.
+		a, m = syntheticReadings(20, -10, 90)
+		for i := 1; i <= 1000; i++ {
+			at := start.Add(time.Duration(i) * 10 * time.Millisecond)
+			f.UpdateAccelerometer(a, at)
+			if i%3 == 0 {
+				f.UpdateMagnetometer(m, at)
+			}
+		}
+		assertAttitude(t, f, 20, -10, 90, 1)
+	}
+}
+
+func TestFilterSmoothsNoise(t *testing.T) {
+	// A single corrupted sample should barely move the estimate
+	for _, f := range filters() {
+		start := time.Unix(0, 0)
+		a, m := syntheticReadings(0, 0, 0)
+		f.UpdateMagnetometer(m, start)
+		f.UpdateAccelerometer(a, start)
+
+		wrong, _ := syntheticReadings(0, 45, 0)
+		f.UpdateAccelerometer(wrong, start.Add(10*time.Millisecond))
+		_, pitch, _ := f.Euler()
+		if math.Abs(pitch) > 5 {
+			t.Errorf("%s: single sample moved pitch to %v", f.algorithm, pitch)
+		}
+	}
+}
+
+func TestRemappedSamples(t *testing.T) {
+	// A board mounted upside down reads the body frame in NED
+	f := NewMahony(2, 0)
+	a, m := syntheticReadings(10, 20, 30)
+	remap := lsm303.AXIS_REMAP_X_FORWARD_Z_DOWN
+	start := time.Unix(0, 0)
+	f.UpdateMagnetometer(remap.Apply(lsm303.AXIS_CONVENTION_NWU.ToNED(m)), start)
+	f.UpdateAccelerometer(remap.Apply(lsm303.AXIS_CONVENTION_NWU.ToNED(a)), start)
+	assertAttitude(t, f, 10, 20, 30, 1e-6)
+
+	if gravity := f.Gravity(); gravity.Sub(a.Normalize()).Norm() > 1e-9 {
+		t.Errorf("expected gravity %+v, got %+v", a.Normalize(), gravity)
+	}
+}
+
+func TestObjectiveGradient(t *testing.T) {
+	// Compare Jᵀ f against finite differences of ½|f|²
+	q := FromEuler(12, -34, 56)
+	d := lsm303.Vector{X: 0.3, Y: 0, Z: 0.8}
+	s := lsm303.Vector{X: 0.1, Y: -0.5, Z: 0.7}
+
+	cost := func(q Quaternion) float64 {
+		f := objective(q, d, s)
+		return f.Dot(f) / 2
+	}
+	if objective(q, d, s).Sub(q.Conjugate().Rotate(d).Sub(s)).Norm() > 1e-9 {
+		t.Fatal("objective doesn't match the quaternion rotation")
+	}
+
+	gradient := objectiveGradient(q, d, s)
+	const h = 1e-7
+	numeric := [4]float64{
+		(cost(Quaternion{q.W + h, q.X, q.Y, q.Z}) - cost(q)) / h,
+		(cost(Quaternion{q.W, q.X + h, q.Y, q.Z}) - cost(q)) / h,
+		(cost(Quaternion{q.W, q.X, q.Y + h, q.Z}) - cost(q)) / h,
+		(cost(Quaternion{q.W, q.X, q.Y, q.Z + h}) - cost(q)) / h,
+	}
+	analytic := [4]float64{gradient.W, gradient.X, gradient.Y, gradient.Z}
+	for i := range numeric {
+		if math.Abs(numeric[i]-analytic[i]) > 1e-5 {
+			t.Errorf("component %d: analytic %v, numeric %v", i, analytic[i], numeric[i])
+		}
+	}
+}
+
+func TestQuaternionEulerRoundTrip(t *testing.T) {
+	for _, attitude := range [][3]float64{{0, 0, 0}, {30, 0, 0}, {0, -45, 0}, {0, 0, 170}, {-60, 30, -120}} {
+		roll, pitch, yaw := FromEuler(attitude[0], attitude[1], attitude[2]).Euler()
+		if angleDiff(roll, attitude[0]) > 1e-9 || angleDiff(pitch, attitude[1]) > 1e-9 || angleDiff(yaw, attitude[2]) > 1e-9 {
+			t.Errorf("%v came back as %v %v %v", attitude, roll, pitch, yaw)
+		}
+	}
+}
+
+func TestFilterIsGravityEstimator(t *testing.T) {
+	a, _ := syntheticReadings(0, 30, 0)
+	f := NewMahony(2, 0)
+	f.UpdateAccelerometer(a, time.Unix(0, 0))
+
+	var estimator lsm303.GravityEstimator = f
+	if estimator.Gravity().Sub(a.Normalize()).Norm() > 1e-9 {
+		t.Fatalf("expected gravity %+v, got %+v", a.Normalize(), estimator.Gravity())
+	}
+}
@@ -0,0 +1,15 @@
+package orientation
+
+type (
+	// Option configures a Filter.
+	Option interface {
+		Apply(*Filter)
+	}
+	// OptionFunc is a function that configures a filter.
+	OptionFunc func(*Filter)
+)
+
+// Apply calls OptionFunc on filter instance
+func (f OptionFunc) Apply(filter *Filter) {
+	f(filter)
+}
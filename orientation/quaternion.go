@@ -0,0 +1,134 @@
+package orientation
+
+import (
+	"math"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+)
+
+// Quaternion is a rotation from the body frame to the earth frame.
+type Quaternion struct {
+	W float64
+	X float64
+	Y float64
+	Z float64
+}
+
+// Identity is the quaternion of no rotation.
+var Identity = Quaternion{W: 1}
+
+func (q Quaternion) Mul(r Quaternion) Quaternion {
+	return Quaternion{
+		q.W*r.W - q.X*r.X - q.Y*r.Y - q.Z*r.Z,
+		q.W*r.X + q.X*r.W + q.Y*r.Z - q.Z*r.Y,
+		q.W*r.Y - q.X*r.Z + q.Y*r.W + q.Z*r.X,
+		q.W*r.Z + q.X*r.Y - q.Y*r.X + q.Z*r.W,
+	}
+}
+
+func (q Quaternion) Conjugate() Quaternion {
+	return Quaternion{q.W, -q.X, -q.Y, -q.Z}
+}
+
+func (q Quaternion) Dot(r Quaternion) float64 {
+	return q.W*r.W + q.X*r.X + q.Y*r.Y + q.Z*r.Z
+}
+
+func (q Quaternion) Norm() float64 {
+	return math.Sqrt(q.Dot(q))
+}
+
+func (q Quaternion) Normalize() Quaternion {
+	n := q.Norm()
+	if n == 0 {
+		return Identity
+	}
+	return Quaternion{q.W / n, q.X / n, q.Y / n, q.Z / n}
+}
+
+// Rotate rotates v from the body frame into the earth frame.
+func (q Quaternion) Rotate(v lsm303.Vector) lsm303.Vector {
+	r := q.Mul(Quaternion{0, v.X, v.Y, v.Z}).Mul(q.Conjugate())
+	return lsm303.Vector{X: r.X, Y: r.Y, Z: r.Z}
+}
+
+// Euler returns roll, pitch and yaw in degrees, using the aerospace (Z-Y-X)
+// sequence. For quaternions produced by the filters yaw is the heading,
+// clockwise from magnetic north.
+func (q Quaternion) Euler() (float64, float64, float64) {
+	roll := math.Atan2(2*(q.W*q.X+q.Y*q.Z), 1-2*(q.X*q.X+q.Y*q.Y))
+	sinPitch := math.Max(-1, math.Min(1, 2*(q.W*q.Y-q.Z*q.X)))
+	pitch := math.Asin(sinPitch)
+	yaw := math.Atan2(2*(q.W*q.Z+q.X*q.Y), 1-2*(q.Y*q.Y+q.Z*q.Z))
+	return degrees(roll), degrees(pitch), degrees(yaw)
+}
+
+// FromEuler builds a quaternion from roll, pitch and yaw in degrees.
+func FromEuler(roll, pitch, yaw float64) Quaternion {
+	sr, cr := math.Sincos(radians(roll) / 2)
+	sp, cp := math.Sincos(radians(pitch) / 2)
+	sy, cy := math.Sincos(radians(yaw) / 2)
+	return Quaternion{
+		cr*cp*cy + sr*sp*sy,
+		sr*cp*cy - cr*sp*sy,
+		cr*sp*cy + sr*cp*sy,
+		cr*cp*sy - sr*sp*cy,
+	}
+}
+
+// Slerp interpolates between q and r, t = 0 gives q and t = 1 gives r.
+func (q Quaternion) Slerp(r Quaternion, t float64) Quaternion {
+	cosTheta := q.Dot(r)
+	// Take the short way around
+	if cosTheta < 0 {
+		r = Quaternion{-r.W, -r.X, -r.Y, -r.Z}
+		cosTheta = -cosTheta
+	}
+	if cosTheta > 0.9995 {
+		return Quaternion{
+			q.W + t*(r.W-q.W),
+			q.X + t*(r.X-q.X),
+			q.Y + t*(r.Y-q.Y),
+			q.Z + t*(r.Z-q.Z),
+		}.Normalize()
+	}
+	theta := math.Acos(cosTheta)
+	a := math.Sin((1-t)*theta) / math.Sin(theta)
+	b := math.Sin(t*theta) / math.Sin(theta)
+	return Quaternion{
+		a*q.W + b*r.W,
+		a*q.X + b*r.X,
+		a*q.Y + b*r.Y,
+		a*q.Z + b*r.Z,
+	}
+}
+
+// Builds the quaternion of a rotation matrix whose rows are the earth axes
+// expressed in the body frame.
+func fromMatrix(m [3][3]float64) Quaternion {
+	trace := m[0][0] + m[1][1] + m[2][2]
+	var q Quaternion
+	switch {
+	case trace > 0:
+		s := math.Sqrt(trace+1) * 2
+		q = Quaternion{s / 4, (m[2][1] - m[1][2]) / s, (m[0][2] - m[2][0]) / s, (m[1][0] - m[0][1]) / s}
+	case m[0][0] > m[1][1] && m[0][0] > m[2][2]:
+		s := math.Sqrt(1+m[0][0]-m[1][1]-m[2][2]) * 2
+		q = Quaternion{(m[2][1] - m[1][2]) / s, s / 4, (m[0][1] + m[1][0]) / s, (m[0][2] + m[2][0]) / s}
+	case m[1][1] > m[2][2]:
+		s := math.Sqrt(1+m[1][1]-m[0][0]-m[2][2]) * 2
+		q = Quaternion{(m[0][2] - m[2][0]) / s, (m[0][1] + m[1][0]) / s, s / 4, (m[1][2] + m[2][1]) / s}
+	default:
+		s := math.Sqrt(1+m[2][2]-m[0][0]-m[1][1]) * 2
+		q = Quaternion{(m[1][0] - m[0][1]) / s, (m[0][2] + m[2][0]) / s, (m[1][2] + m[2][1]) / s, s / 4}
+	}
+	return q.Normalize()
+}
+
+func degrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
@@ -0,0 +1,26 @@
+package lsm303
+
+import (
+	"fmt"
+
+	"periph.io/x/periph/conn"
+	"periph.io/x/periph/conn/i2c"
+)
+
+// The handles are periph resources, so they can be used wherever periph based
+// tooling takes one.
+var (
+	_ conn.Resource = &Accelerometer{}
+	_ conn.Resource = &Magnetometer{}
+	_ conn.Resource = &Device{}
+)
+
+// describe names a sensor after its variant, bus and address. periph writes
+// addresses in decimal, hex is what the datasheets use.
+func describe(sensorType SensorType, what string, c conn.Conn) string {
+	dev, ok := c.(*i2c.Dev)
+	if !ok || dev.Bus == nil {
+		return fmt.Sprintf("%s %s", sensorType, what)
+	}
+	return fmt.Sprintf("%s %s on %s at 0x%02X", sensorType, what, dev.Bus, dev.Addr)
+}
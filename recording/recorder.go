@@ -0,0 +1,137 @@
+package recording
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/physic"
+)
+
+// Recorder is an i2c.Bus that passes every transaction on to another bus and
+// logs it. Pass it to NewAccelerometer and NewMagnetometer instead of the
+// real bus.
+type Recorder struct {
+	mu      sync.Mutex
+	bus     i2c.Bus
+	encoder *json.Encoder
+	closer  io.Closer
+	now     func() time.Time
+	started time.Time
+	err     error
+}
+
+// NewRecorder starts a recording of bus to w.
+func NewRecorder(bus i2c.Bus, w io.Writer, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		bus:     bus,
+		encoder: json.NewEncoder(w),
+		now:     time.Now,
+	}
+	for i := range opts {
+		opts[i].Apply(r)
+	}
+
+	r.started = r.now()
+	header := Header{Format: format, Version: version, Bus: bus.String(), Started: r.started.UTC()}
+	if err := r.encoder.Encode(header); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Create starts a recording of bus to a new file at path. Close the recorder
+// to close the file.
+func Create(bus i2c.Bus, path string, opts ...RecorderOption) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewRecorder(bus, file, opts...)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	r.closer = file
+	return r, nil
+}
+
+func (r *Recorder) String() string {
+	return fmt.Sprintf("recording of %s", r.bus)
+}
+
+// Tx runs the transaction on the recorded bus and logs it. Failing to write
+// the log doesn't fail the transaction, it is reported by Err and Close.
+func (r *Recorder) Tx(addr uint16, w, read []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.bus.Tx(addr, w, read)
+	transaction := Transaction{
+		Time:  r.now().Sub(r.started),
+		Addr:  addr,
+		Write: append(Bytes(nil), w...),
+		Read:  append(Bytes(nil), read...),
+	}
+	if err != nil {
+		transaction.Err = err.Error()
+	}
+	if encodeErr := r.encoder.Encode(transaction); encodeErr != nil && r.err == nil {
+		r.err = encodeErr
+	}
+	return err
+}
+
+// SetSpeed is passed on to the recorded bus, it isn't recorded.
+func (r *Recorder) SetSpeed(f physic.Frequency) error {
+	return r.bus.SetSpeed(f)
+}
+
+// Err returns the first error writing the log.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close stops the recording and closes the file if Create opened it. The
+// recorded bus is left open, it belongs to the caller.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.err
+	if r.closer != nil {
+		if closeErr := r.closer.Close(); err == nil {
+			err = closeErr
+		}
+		r.closer = nil
+	}
+	return err
+}
+
+type (
+	// RecorderOption configures a Recorder.
+	RecorderOption interface {
+		Apply(*Recorder)
+	}
+	// RecorderOptionFunc is a function that configures a recorder.
+	RecorderOptionFunc func(*Recorder)
+)
+
+// Apply calls RecorderOptionFunc on recorder instance
+func (f RecorderOptionFunc) Apply(r *Recorder) {
+	f(r)
+}
+
+// WithClock can be used to timestamp the transactions with a fake clock.
+// Default is time.Now.
+func WithClock(now func() time.Time) RecorderOption {
+	return RecorderOptionFunc(func(r *Recorder) {
+		r.now = now
+	})
+}
@@ -0,0 +1,67 @@
+// Package recording captures the I²C traffic of a sensor session to a file and
+// plays it back, so a problem seen in the field can be reproduced offline
+// through the same driver calls.
+//
+// A recording is JSON lines: a header, then one transaction per line with its
+// time since the start of the session and the bytes in hex.
+//
+//	{"format":"lsm303-i2c","version":1,"bus":"I2C1","started":"2024-05-01T10:00:00Z"}
+//	{"t":1042000,"addr":25,"w":"20","r":"57"}
+//
+// Replay relies on the driver making the same transactions in the same order,
+// which holds for everything but the calls timed by the wall clock, see
+// Replayer.
+package recording
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+const (
+	format  = "lsm303-i2c"
+	version = 1
+)
+
+// Header is the first line of a recording.
+type Header struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Bus     string    `json:"bus"`
+	Started time.Time `json:"started"`
+}
+
+// Transaction is one Tx call on the recorded bus.
+type Transaction struct {
+	// Time since the recording started
+	Time  time.Duration `json:"t"`
+	Addr  uint16        `json:"addr"`
+	Write Bytes         `json:"w,omitempty"`
+	Read  Bytes         `json:"r,omitempty"`
+	// The error the bus returned, if any
+	Err string `json:"err,omitempty"`
+}
+
+// Bytes is written as a hex string, which is easier to read in a recording
+// than base64.
+type Bytes []byte
+
+// MarshalJSON encodes the bytes as hex.
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(b))
+}
+
+// UnmarshalJSON decodes a hex string.
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := hex.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
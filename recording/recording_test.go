@@ -0,0 +1,247 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+	"github.com/timoth-y/go-lsm303/simulator"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/physic"
+)
+
+var (
+	_ i2c.Bus = (*Recorder)(nil)
+	_ i2c.Bus = (*Replayer)(nil)
+)
+
+var moving = simulator.SourceFunc(func(elapsed time.Duration) simulator.Sample {
+	return simulator.Sample{Acceleration: lsm303.Vector{X: elapsed.Seconds(), Z: 1}}
+})
+
+func sense(t *testing.T, accelerometer *lsm303.Accelerometer) []physic.Force {
+	var readings []physic.Force
+	for i := 0; i < 5; i++ {
+		x, y, z, err := accelerometer.Sense()
+		if err != nil {
+			t.Fatal(err)
+		}
+		readings = append(readings, x, y, z)
+		time.Sleep(20 * time.Millisecond)
+	}
+	return readings
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	bus, err := simulator.New(lsm303.LSM303DLHC, moving)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	recorder, err := Create(bus, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accelerometer, err := lsm303.NewAccelerometer(recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := sense(t, accelerometer)
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replayer, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header := replayer.Header(); header.Bus != bus.String() {
+		t.Errorf("recorded bus is %q, want %q", header.Bus, bus.String())
+	}
+	accelerometer, err = lsm303.NewAccelerometer(replayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := sense(t, accelerometer)
+	for i := range recorded {
+		if replayed[i] != recorded[i] {
+			t.Fatalf("replayed %v, recorded %v", replayed, recorded)
+		}
+	}
+	if remaining := replayer.Remaining(); remaining != 0 {
+		t.Errorf("%d transactions left", remaining)
+	}
+	if err := replayer.Tx(0x19, []byte{0x28}, make([]byte, 1)); err != io.EOF {
+		t.Errorf("got %v after the end of the recording, want io.EOF", err)
+	}
+}
+
+// SenseAveraged polls the status register until data is ready, the replay
+// must poll as often as the recording did.
+func TestRecordAndReplayPolling(t *testing.T) {
+	bus, err := simulator.New(lsm303.LSM303DLHC, moving)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log bytes.Buffer
+	recorder, err := NewRecorder(bus, &log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accelerometer, err := lsm303.NewAccelerometer(recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := accelerometer.SenseAveraged(context.Background(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayer, err := NewReplayer(bytes.NewReader(log.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	accelerometer, err = lsm303.NewAccelerometer(replayer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := accelerometer.SenseAveraged(context.Background(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != recorded {
+		t.Errorf("replayed %+v, recorded %+v", replayed, recorded)
+	}
+	if remaining := replayer.Remaining(); remaining != 0 {
+		t.Errorf("%d transactions left", remaining)
+	}
+}
+
+type failingBus struct{}
+
+func (failingBus) String() string {
+	return "failing"
+}
+
+func (failingBus) Tx(addr uint16, w, r []byte) error {
+	return errors.New("nack")
+}
+
+func (failingBus) SetSpeed(physic.Frequency) error {
+	return nil
+}
+
+func TestReplayErrors(t *testing.T) {
+	var log bytes.Buffer
+	recorder, err := NewRecorder(failingBus{}, &log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Tx(0x1E, []byte{0x02, 0x00}, nil); err == nil || err.Error() != "nack" {
+		t.Fatalf("got %v, want the bus error", err)
+	}
+
+	replayer, err := NewReplayer(bytes.NewReader(log.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A different transaction than recorded
+	if err := replayer.Tx(0x1E, []byte{0x02, 0x03}, nil); err == nil || !strings.Contains(err.Error(), "the recording has 0200 to 0x1E") {
+		t.Errorf("got %v, want a mismatch", err)
+	}
+	// The recorded one gets the recorded error
+	if err := replayer.Tx(0x1E, []byte{0x02, 0x00}, nil); err == nil || err.Error() != "nack" {
+		t.Errorf("got %v, want the recorded error", err)
+	}
+}
+
+func TestReplayInRealTime(t *testing.T) {
+	recording := strings.Join([]string{
+		`{"format":"lsm303-i2c","version":1,"bus":"test","started":"2024-05-01T10:00:00Z"}`,
+		`{"t":1000000000,"addr":25,"w":"27","r":"00"}`,
+		`{"t":1050000000,"addr":25,"w":"27","r":"08"}`,
+		`{"t":1100000000,"addr":25,"w":"27","r":"08"}`,
+	}, "\n")
+
+	for _, realTime := range []bool{false, true} {
+		var opts []ReplayerOption
+		if realTime {
+			opts = append(opts, WithRealTime())
+		}
+		replayer, err := NewReplayer(strings.NewReader(recording), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		start := time.Now()
+		status := make([]byte, 1)
+		for replayer.Remaining() > 0 {
+			if err := replayer.Tx(25, []byte{0x27}, status); err != nil {
+				t.Fatal(err)
+			}
+		}
+		elapsed := time.Since(start)
+
+		// The first transaction plays right away, the rest 100ms later
+		if realTime && elapsed < 100*time.Millisecond {
+			t.Errorf("real-time replay took %s", elapsed)
+		}
+		if !realTime && elapsed > 50*time.Millisecond {
+			t.Errorf("replay took %s", elapsed)
+		}
+		if status[0] != 0x08 {
+			t.Errorf("status is 0x%02X", status[0])
+		}
+	}
+}
+
+func TestReplayInRealTimeDoesNotBlock(t *testing.T) {
+	recording := strings.Join([]string{
+		`{"format":"lsm303-i2c","version":1,"bus":"test","started":"2024-05-01T10:00:00Z"}`,
+		`{"t":0,"addr":25,"w":"27","r":"00"}`,
+		`{"t":200000000,"addr":25,"w":"27","r":"08"}`,
+	}, "\n")
+	replayer, err := NewReplayer(strings.NewReader(recording), WithRealTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := make([]byte, 1)
+	if err := replayer.Tx(25, []byte{0x27}, status); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- replayer.Tx(25, []byte{0x27}, make([]byte, 1))
+	}()
+
+	// The second transaction waits for its time, the replayer stays usable
+	time.Sleep(20 * time.Millisecond)
+	start := time.Now()
+	if remaining := replayer.Remaining(); remaining != 0 {
+		t.Errorf("%d transactions left", remaining)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Remaining took %s", elapsed)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayRejectsOtherFiles(t *testing.T) {
+	for _, recording := range []string{
+		"",
+		`{"format":"something-else","version":1}`,
+		`{"format":"lsm303-i2c","version":1}` + "\n" + `{"t":1,"addr":25,"w":"zz"}`,
+	} {
+		if _, err := NewReplayer(strings.NewReader(recording)); err == nil {
+			t.Errorf("loaded %q", recording)
+		}
+	}
+}
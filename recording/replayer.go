@@ -0,0 +1,173 @@
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+)
+
+// Replayer is an i2c.Bus that answers from a recording. The driver has to
+// make the same calls it made while recording: every transaction must go to
+// the recorded address and write the recorded bytes, it then gets the
+// recorded reads and error.
+//
+// Waiting for data ready replays exactly, the driver polls until it reads
+// the recorded ready status. What depends on the wall clock doesn't: SenseFor
+// reads for as long as its duration, and a wait that timed out while
+// recording may not on replay. Those go out of step with the recording and
+// fail with a mismatch or io.EOF, WithRealTime makes them less likely to.
+type Replayer struct {
+	mu           sync.Mutex
+	header       Header
+	transactions []Transaction
+	next         int
+	realTime     bool
+	started      time.Time
+}
+
+// NewReplayer loads a recording.
+func NewReplayer(r io.Reader, opts ...ReplayerOption) (*Replayer, error) {
+	p := &Replayer{}
+	for i := range opts {
+		opts[i].Apply(p)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		if p.header.Format == "" {
+			if err := json.Unmarshal(scanner.Bytes(), &p.header); err != nil {
+				return nil, fmt.Errorf("recording header: %w", err)
+			}
+			if p.header.Format != format || p.header.Version != version {
+				return nil, fmt.Errorf("not a version %d %s recording", version, format)
+			}
+			continue
+		}
+		var transaction Transaction
+		if err := json.Unmarshal(scanner.Bytes(), &transaction); err != nil {
+			return nil, fmt.Errorf("recording line %d: %w", line, err)
+		}
+		p.transactions = append(p.transactions, transaction)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if p.header.Format == "" {
+		return nil, errors.New("empty recording")
+	}
+	return p, nil
+}
+
+// Open loads the recording at path.
+func Open(path string, opts ...ReplayerOption) (*Replayer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return NewReplayer(file, opts...)
+}
+
+// Header returns the header of the recording.
+func (p *Replayer) Header() Header {
+	return p.header
+}
+
+// Remaining returns the number of transactions not replayed yet.
+func (p *Replayer) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.transactions) - p.next
+}
+
+func (p *Replayer) String() string {
+	return fmt.Sprintf("replay of %s", p.header.Bus)
+}
+
+// Tx checks the transaction against the next one in the recording and
+// answers with what was read then. It returns io.EOF once the recording has
+// run out.
+func (p *Replayer) Tx(addr uint16, w, r []byte) error {
+	transaction, due, err := p.take(addr, w, len(r))
+	if err != nil {
+		return err
+	}
+	// Without the lock, so Remaining doesn't wait for the pace
+	if !due.IsZero() {
+		time.Sleep(time.Until(due))
+	}
+
+	copy(r, transaction.Read)
+	if transaction.Err != "" {
+		return errors.New(transaction.Err)
+	}
+	return nil
+}
+
+// Checks the transaction against the next one in the recording and moves on
+// to the one after. With WithRealTime, due is when it should be answered.
+func (p *Replayer) take(addr uint16, w []byte, n int) (transaction Transaction, due time.Time, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.next >= len(p.transactions) {
+		return Transaction{}, time.Time{}, io.EOF
+	}
+	transaction = p.transactions[p.next]
+	if addr != transaction.Addr || !bytes.Equal(w, transaction.Write) {
+		return Transaction{}, time.Time{}, fmt.Errorf("transaction %d: got write %X to 0x%02X, the recording has %X to 0x%02X",
+			p.next, w, addr, []byte(transaction.Write), transaction.Addr)
+	}
+	if n != len(transaction.Read) {
+		return Transaction{}, time.Time{}, fmt.Errorf("transaction %d: got a %d byte read, the recording has %d", p.next, n, len(transaction.Read))
+	}
+	p.next++
+
+	if p.realTime {
+		if p.started.IsZero() {
+			p.started = time.Now().Add(-transaction.Time)
+		}
+		due = p.started.Add(transaction.Time)
+	}
+	return transaction, due, nil
+}
+
+// SetSpeed does nothing.
+func (p *Replayer) SetSpeed(physic.Frequency) error {
+	return nil
+}
+
+type (
+	// ReplayerOption configures a Replayer.
+	ReplayerOption interface {
+		Apply(*Replayer)
+	}
+	// ReplayerOptionFunc is a function that configures a replayer.
+	ReplayerOptionFunc func(*Replayer)
+)
+
+// Apply calls ReplayerOptionFunc on replayer instance
+func (f ReplayerOptionFunc) Apply(p *Replayer) {
+	f(p)
+}
+
+// WithRealTime can be used to replay at the recorded pace, each transaction
+// waits until its time since the first one has passed. By default the
+// recording is replayed as fast as it is read.
+func WithRealTime() ReplayerOption {
+	return ReplayerOptionFunc(func(p *Replayer) {
+		p.realTime = true
+	})
+}
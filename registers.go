@@ -0,0 +1,132 @@
+package lsm303
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Register describes one register of a sensor.
+type Register struct {
+	Name     string
+	Address  uint8
+	Writable bool
+	Fields   []Field
+}
+
+// RegisterDump is a snapshot of every known register, by name.
+type RegisterDump struct {
+	SensorType SensorType       `json:"sensor_type"`
+	Device     string           `json:"device"`
+	Values     map[string]uint8 `json:"values"`
+}
+
+const (
+	accelerometerDevice = "accelerometer"
+	magnetometerDevice  = "magnetometer"
+)
+
+func registersFor(sensorType SensorType, device string) []Register {
+	if device == magnetometerDevice {
+		return magnetometerRegisters(sensorType)
+	}
+	return accelerometerRegisters(sensorType)
+}
+
+// String decodes the dump, one register per line with its bitfields.
+func (d RegisterDump) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s registers\n", d.SensorType, d.Device)
+	for _, register := range registersFor(d.SensorType, d.Device) {
+		value, ok := d.Values[register.Name]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, "%-16s 0x%02X = 0x%02X %08b", register.Name, register.Address, value, value)
+		for _, field := range register.Fields {
+			if field.Width == 1 {
+				fmt.Fprintf(&b, " %s=%d", field.Name, field.Get(value))
+			} else {
+				fmt.Fprintf(&b, " %s=%0*b", field.Name, int(field.Width), field.Get(value))
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Registers returns the register map of the accelerometer.
+func (a *Accelerometer) Registers() []Register {
+	return accelerometerRegisters(a.sensorType)
+}
+
+// DumpRegisters reads every known register of the accelerometer. Registers
+// that clear on read (interrupt sources) are left out.
+func (a *Accelerometer) DumpRegisters() (RegisterDump, error) {
+	return dumpRegisters(a.sensorType, accelerometerDevice, a.Registers(), a.mmr.ReadUint8)
+}
+
+// RestoreRegisters writes the writable registers of a dump back, e.g. one
+// taken with DumpRegisters on a unit that worked. The range, mode and data
+// rate are read back afterwards, so Sense scales by the restored settings and
+// Reset returns to them.
+func (a *Accelerometer) RestoreRegisters(dump RegisterDump) error {
+	if err := restoreRegisters(a.sensorType, accelerometerDevice, a.Registers(), dump, a.mmr.WriteUint8); err != nil {
+		return err
+	}
+	return a.readConfiguration()
+}
+
+// Registers returns the register map of the magnetometer.
+func (m *Magnetometer) Registers() []Register {
+	return magnetometerRegisters(m.sensorType)
+}
+
+// DumpRegisters reads every known register of the magnetometer.
+func (m *Magnetometer) DumpRegisters() (RegisterDump, error) {
+	return dumpRegisters(m.sensorType, magnetometerDevice, m.Registers(), m.mmr.ReadUint8)
+}
+
+// RestoreRegisters writes the writable registers of a dump back, see
+// Accelerometer.RestoreRegisters. The gain and data rate are read back.
+func (m *Magnetometer) RestoreRegisters(dump RegisterDump) error {
+	if err := restoreRegisters(m.sensorType, magnetometerDevice, m.Registers(), dump, m.mmr.WriteUint8); err != nil {
+		return err
+	}
+	return m.readConfiguration()
+}
+
+func dumpRegisters(sensorType SensorType, device string, registers []Register, read func(uint8) (uint8, error)) (RegisterDump, error) {
+	dump := RegisterDump{SensorType: sensorType, Device: device, Values: make(map[string]uint8, len(registers))}
+	for _, register := range registers {
+		value, err := read(register.Address)
+		if err != nil {
+			return RegisterDump{}, fmt.Errorf("reading %s: %w", register.Name, err)
+		}
+		dump.Values[register.Name] = value
+	}
+	return dump, nil
+}
+
+func restoreRegisters(sensorType SensorType, device string, registers []Register, dump RegisterDump, write func(uint8, uint8) error) error {
+	if dump.SensorType != sensorType || dump.Device != device {
+		return fmt.Errorf("dump is from a %s %s, not a %s %s", dump.SensorType, dump.Device, sensorType, device)
+	}
+
+	for _, register := range registers {
+		value, ok := dump.Values[register.Name]
+		if !register.Writable || !ok {
+			continue
+		}
+		var selfClearing uint8
+		for _, field := range register.Fields {
+			if field.SelfClearing {
+				selfClearing |= field.Mask()
+			}
+		}
+		value &^= selfClearing
+		if err := write(register.Address, value); err != nil {
+			return fmt.Errorf("writing %s: %w", register.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package lsm303
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/mmr"
+)
+
+func TestRegisterMapsAreConsistent(t *testing.T) {
+	for _, sensorType := range []SensorType{LSM303DLHC, LSM303AGR, LSM303C} {
+		for _, device := range []string{accelerometerDevice, magnetometerDevice} {
+			names := map[string]bool{}
+			addresses := map[uint8]bool{}
+			var previous uint8
+			for i, register := range registersFor(sensorType, device) {
+				if names[register.Name] || addresses[register.Address] {
+					t.Errorf("%s %s: %s (0x%02X) is listed twice", sensorType, device, register.Name, register.Address)
+				}
+				if i > 0 && register.Address < previous {
+					t.Errorf("%s %s: %s is out of address order", sensorType, device, register.Name)
+				}
+				names[register.Name], addresses[register.Address] = true, true
+				previous = register.Address
+
+				var used uint8
+				for _, field := range register.Fields {
+					if field.Width == 0 || int(field.Shift)+int(field.Width) > 8 {
+						t.Errorf("%s %s.%s doesn't fit in a byte", sensorType, register.Name, field.Name)
+					}
+					if field.Register != register.Address {
+						t.Errorf("%s %s.%s is at 0x%02X, not 0x%02X", sensorType, register.Name, field.Name, field.Register, register.Address)
+					}
+					if used&field.Mask() != 0 {
+						t.Errorf("%s %s.%s overlaps another field", sensorType, register.Name, field.Name)
+					}
+					used |= field.Mask()
+				}
+			}
+		}
+	}
+}
+
+func TestMagnetometerDumpAndRestore(t *testing.T) {
+	d := magnetometerDatasheet
+	values := []uint8{0x94, 0x80, 0x00, 0x01, 0x48, 0x34, 0x33}
+	var ops []i2ctest.IO
+	for i, register := range lsm303dlhcMagnetometerRegisters {
+		ops = append(ops, i2ctest.IO{Addr: d.ADDRESS, W: []byte{register.Address}, R: []byte{values[i]}})
+	}
+	// Only the control registers are written back
+	ops = append(ops,
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x00, 0x94}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x01, 0x80}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x02, 0x00}},
+		// Then the gain and rate are read back
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x01}, R: []byte{0x80}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x00}, R: []byte{0x94}},
+	)
+	scenario := &i2ctest.Playback{Ops: ops}
+	magnetometer := &Magnetometer{
+		mmr:        mmr.Dev8{Conn: &i2c.Dev{Bus: scenario, Addr: d.ADDRESS}, Order: binary.BigEndian},
+		sensorType: LSM303DLHC,
+		datasheet:  d,
+	}
+
+	dump, err := magnetometer.DumpRegisters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dump.Values["CRB_REG_M"] != 0x80 || dump.Values["SR_REG_M"] != 0x01 {
+		t.Fatalf("unexpected dump %v", dump.Values)
+	}
+
+	decoded := dump.String()
+	for _, expected := range []string{"CRA_REG_M", "TEMP_EN=1 DO=101", "GN=100", "MD=00", "DRDY=1"} {
+		if !strings.Contains(decoded, expected) {
+			t.Errorf("expected %q in\n%s", expected, decoded)
+		}
+	}
+
+	// A dump survives being saved as JSON
+	encoded, err := json.Marshal(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored RegisterDump
+	if err := json.Unmarshal(encoded, &restored); err != nil {
+		t.Fatal(err)
+	}
+	if err := magnetometer.RestoreRegisters(restored); err != nil {
+		t.Fatal(err)
+	}
+	// GN=100 and DO=101
+	if magnetometer.gain != 0b100 || magnetometer.rate != 0b101 {
+		t.Errorf("gain %d and rate %d after the restore, want 4 and 5", magnetometer.gain, magnetometer.rate)
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccelerometerRestoreUpdatesScaling(t *testing.T) {
+	// The handle runs at ±2G, the dump has ±8G in normal mode at 100 Hz
+	d := datasheetForAccelerometer(LSM303DLHC)
+	const (
+		ctrlReg1 = 0b01010111
+		ctrlReg4 = 0b00100000
+	)
+	dump := RegisterDump{SensorType: LSM303DLHC, Device: accelerometerDevice, Values: map[string]uint8{
+		"CTRL_REG1_A": ctrlReg1,
+		"CTRL_REG4_A": ctrlReg4,
+	}}
+	accelerometer, scenario := scriptedAccelerometer(LSM303DLHC,
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x20, ctrlReg1}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x23, ctrlReg4}},
+		// Range, mode (LPen, HR) and rate are read back
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x23}, R: []byte{ctrlReg4}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x20}, R: []byte{ctrlReg1}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x23}, R: []byte{ctrlReg4}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{0x20}, R: []byte{ctrlReg1}},
+		// 1G on Z at ±8G in normal mode, 10-bit counts of 16 mg left justified
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{d.OUT_X_L_A}, R: []byte{0}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{d.OUT_X_H_A}, R: []byte{0}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{d.OUT_Y_L_A}, R: []byte{0}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{d.OUT_Y_H_A}, R: []byte{0}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{d.OUT_Z_L_A}, R: []byte{0x00}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{d.OUT_Z_H_A}, R: []byte{0x10}},
+	)
+	accelerometer.range_, accelerometer.mode = ACCELEROMETER_RANGE_2G, ACCELEROMETER_MODE_NORMAL
+
+	if err := accelerometer.RestoreRegisters(dump); err != nil {
+		t.Fatal(err)
+	}
+	if accelerometer.range_ != ACCELEROMETER_RANGE_8G || accelerometer.mode != ACCELEROMETER_MODE_NORMAL || accelerometer.dataRate != 0b0101 {
+		t.Errorf("range %s, mode %d and rate %04b after the restore", accelerometer.range_, accelerometer.mode, accelerometer.dataRate)
+	}
+	_, _, z, err := accelerometer.Sense()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := forceToG(z); math.Abs(g-1) > 0.01 {
+		t.Errorf("Z is %.3f g, want 1 g at the restored ±8G", g)
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestoreSkipsSelfClearingBits(t *testing.T) {
+	var written []uint8
+	dump := RegisterDump{SensorType: LSM303DLHC, Device: accelerometerDevice, Values: map[string]uint8{"CTRL_REG5_A": 0xC8}}
+	err := restoreRegisters(LSM303DLHC, accelerometerDevice, accelerometerRegisters(LSM303DLHC), dump, func(register, value uint8) error {
+		written = append(written, register, value)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 2 || written[0] != 0x24 || written[1] != 0x48 {
+		t.Fatalf("expected BOOT to be cleared, wrote %v", written)
+	}
+}
+
+func TestRestoreRejectsOtherSensors(t *testing.T) {
+	accelerometer := &Accelerometer{sensorType: LSM303DLHC, datasheet: accelerometerDatasheet}
+	if err := accelerometer.RestoreRegisters(RegisterDump{SensorType: LSM303C, Device: accelerometerDevice}); err == nil {
+		t.Error("expected an error for a dump from another variant")
+	}
+	if err := accelerometer.RestoreRegisters(RegisterDump{SensorType: LSM303DLHC, Device: magnetometerDevice}); err == nil {
+		t.Error("expected an error for a magnetometer dump")
+	}
+}
@@ -0,0 +1,34 @@
+package lsm303
+
+import "time"
+
+// AccelSample is an accelerometer reading in units of standard gravity, with
+// the time it was taken.
+type AccelSample struct {
+	Time         time.Time `json:"time"`
+	Acceleration Vector    `json:"acceleration"`
+}
+
+// MagSample is a magnetometer reading in gauss, with the time it was taken.
+type MagSample struct {
+	Time          time.Time `json:"time"`
+	MagneticField Vector    `json:"magnetic_field"`
+}
+
+// SenseSample reads the acceleration like Sense and timestamps it.
+func (a *Accelerometer) SenseSample() (AccelSample, error) {
+	acceleration, err := a.senseCalibratedVector()
+	if err != nil {
+		return AccelSample{}, err
+	}
+	return AccelSample{Time: time.Now(), Acceleration: acceleration}, nil
+}
+
+// SenseSample reads the magnetic field like Sense and timestamps it.
+func (m *Magnetometer) SenseSample() (MagSample, error) {
+	field, err := m.senseCalibratedField()
+	if err != nil {
+		return MagSample{}, err
+	}
+	return MagSample{Time: time.Now(), MagneticField: field}, nil
+}
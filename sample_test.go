@@ -0,0 +1,58 @@
+package lsm303
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/mmr"
+)
+
+func TestSenseSample(t *testing.T) {
+	before := time.Now()
+
+	// About 0.5 g on X at ±2G
+	accelerometer := playbackAccelerometer(Vector{X: 8192})
+	accelerometer.calibration = &AccelerometerCalibration{
+		Bias:  Vector{0.1, 0, 0},
+		Scale: [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}},
+	}
+	acceleration, err := accelerometer.SenseSample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(acceleration.Acceleration.X-0.4) > 0.01 || acceleration.Time.Before(before) {
+		t.Errorf("unexpected sample %+v", acceleration)
+	}
+
+	scenario := &i2ctest.Playback{
+		Ops: []i2ctest.IO{
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_X_L_M}, R: []byte{0xC2}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_X_H_M}, R: []byte{0x01}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_Y_L_M}, R: []byte{0}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_Y_H_M}, R: []byte{0}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_Z_L_M}, R: []byte{0x38}},
+			{Addr: magnetometerDatasheet.ADDRESS, W: []byte{magnetometerDatasheet.OUT_Z_H_M}, R: []byte{0xFF}},
+		},
+	}
+	magnetometer := &Magnetometer{
+		mmr: mmr.Dev8{
+			Conn:  &i2c.Dev{Bus: scenario, Addr: magnetometerDatasheet.ADDRESS},
+			Order: binary.BigEndian,
+		},
+		sensorType: LSM303DLHC,
+		datasheet:  magnetometerDatasheet,
+		gain:       MAGNETOMETER_GAIN_4_0,
+	}
+	field, err := magnetometer.SenseSample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Raw 450 on X is 1 gauss at this gain, -200 on Z is -0.5 gauss
+	if field.MagneticField != (Vector{1, 0, -0.5}) || field.Time.Before(acceleration.Time) {
+		t.Errorf("unexpected sample %+v", field)
+	}
+}
@@ -0,0 +1,180 @@
+package lsm303
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"periph.io/x/periph/conn/physic"
+)
+
+// SelfTestReport is the outcome of SelfTest. Accelerometer values are in units
+// of standard gravity, magnetometer values in gauss, all in the sensor frame
+// without calibration.
+type SelfTestReport struct {
+	// Averaged output with self-test off and on
+	Baseline Vector
+	SelfTest Vector
+	// Absolute change per axis, and the range the datasheet allows for it
+	Delta Vector
+	Min   Vector
+	Max   Vector
+	// Whether each axis passed, and all of them
+	AxisPassed [3]bool
+	Passed     bool
+}
+
+func (r SelfTestReport) String() string {
+	deltas := [3]float64{r.Delta.X, r.Delta.Y, r.Delta.Z}
+	mins := [3]float64{r.Min.X, r.Min.Y, r.Min.Z}
+	maxs := [3]float64{r.Max.X, r.Max.Y, r.Max.Z}
+
+	var b strings.Builder
+	if r.Passed {
+		b.WriteString("self-test passed:")
+	} else {
+		b.WriteString("self-test FAILED:")
+	}
+	for i, axis := range [...]string{"X", "Y", "Z"} {
+		verdict := "ok"
+		if !r.AxisPassed[i] {
+			verdict = "out of range"
+		}
+		fmt.Fprintf(&b, " %s %.4f (%.4f..%.4f) %s;", axis, deltas[i], mins[i], maxs[i], verdict)
+	}
+	return strings.TrimSuffix(b.String(), ";")
+}
+
+// How a self-test is run on one variant: which field turns it on and the
+// value to set, how long the output takes to settle, how many samples to
+// average and the datasheet limits for the change.
+type selfTestProcedure struct {
+	field    Field
+	enable   uint8
+	settle   time.Duration
+	samples  int
+	min, max Vector
+}
+
+// SelfTest runs the datasheet self-test: the output is averaged with the
+// self-test actuation off and on, and the change on each axis is compared to
+// the limits for the sensor. As the datasheet procedure has it, the test runs
+// in normal mode at ±2G and 100 Hz with block data update. A failed test is
+// reported in the report, the error is only for I/O problems. The
+// configuration is restored afterwards.
+func (a *Accelerometer) SelfTest(ctx context.Context) (report SelfTestReport, err error) {
+	fields := a.fields()
+	fullScale2G, _ := accelerometerFullScale(a.sensorType, ACCELEROMETER_RANGE_2G)
+	original, err := writeFields(&a.mmr, []fieldValue{
+		{fields.dataRate, accelerometerDriverFor(a.sensorType).rate100Hz},
+		{fields.lowPower, 0},
+		{fields.highResolution, 0},
+		{fields.fullScale, fullScale2G},
+		{fields.blockDataUpdate, 1},
+		{fields.axisEnable[0], 1},
+		{fields.axisEnable[1], 1},
+		{fields.axisEnable[2], 1},
+	})
+	if err != nil {
+		return SelfTestReport{}, err
+	}
+	defer func() {
+		if restoreErr := writeRegisterValues(&a.mmr, original); restoreErr != nil && err == nil {
+			err = restoreErr
+		}
+	}()
+
+	read := func() (Vector, error) {
+		xValue, yValue, zValue, err := a.readRaw()
+		if err != nil {
+			return Vector{}, err
+		}
+		multiplier := getMultiplier(ACCELEROMETER_MODE_NORMAL, ACCELEROMETER_RANGE_2G)
+		return Vector{
+			forceToG(physic.Force(int64(xValue) * multiplier)),
+			forceToG(physic.Force(int64(yValue) * multiplier)),
+			forceToG(physic.Force(int64(zValue) * multiplier)),
+		}, nil
+	}
+	procedure := accelerometerDriverFor(a.sensorType).selfTest
+	procedure.field = fields.selfTest
+	return runSelfTest(ctx, a.mmr.ReadUint8, a.mmr.WriteUint8, procedure, a.waitDataReady, read)
+}
+
+// SelfTest runs the datasheet self-test on the AGR and C magnetometers, see
+// Accelerometer.SelfTest. The DLHC has no magnetometer self-test and returns
+// an error.
+func (m *Magnetometer) SelfTest(ctx context.Context) (SelfTestReport, error) {
+	selfTest := magnetometerDriverFor(m.sensorType).selfTest
+	if selfTest == nil {
+		return SelfTestReport{}, fmt.Errorf("%s magnetometer has no self-test", m.sensorType)
+	}
+	procedure := *selfTest
+	procedure.field = m.fields().selfTest
+	read := func() (Vector, error) {
+		xValue, yValue, zValue, err := m.readRaw()
+		if err != nil {
+			return Vector{}, err
+		}
+		xyLsb, zLsb := getMagnetometerLsb(m.sensorType, m.gain)
+		return Vector{float64(xValue) / xyLsb, float64(yValue) / xyLsb, float64(zValue) / zLsb}, nil
+	}
+	return runSelfTest(ctx, m.mmr.ReadUint8, m.mmr.WriteUint8, procedure, m.waitDataReady, read)
+}
+
+func runSelfTest(ctx context.Context, readRegister func(uint8) (uint8, error), writeRegister func(uint8, uint8) error, procedure selfTestProcedure, wait func(context.Context) error, read func() (Vector, error)) (report SelfTestReport, err error) {
+	original, err := readRegister(procedure.field.Register)
+	if err != nil {
+		return SelfTestReport{}, err
+	}
+
+	// Settle, drop the first sample and average the next ones
+	measure := func() (Vector, error) {
+		select {
+		case <-ctx.Done():
+			return Vector{}, ctx.Err()
+		case <-time.After(procedure.settle):
+		}
+		if err := wait(ctx); err != nil {
+			return Vector{}, err
+		}
+		if _, err := read(); err != nil {
+			return Vector{}, err
+		}
+		average, err := senseAveraged(ctx, procedure.samples, nil, wait, read)
+		return average.Mean, err
+	}
+
+	if report.Baseline, err = measure(); err != nil {
+		return SelfTestReport{}, err
+	}
+
+	if err := writeRegister(procedure.field.Register, procedure.field.Set(original, procedure.enable)); err != nil {
+		return SelfTestReport{}, err
+	}
+	defer func() {
+		if restoreErr := writeRegister(procedure.field.Register, original); restoreErr != nil && err == nil {
+			err = restoreErr
+		}
+	}()
+
+	if report.SelfTest, err = measure(); err != nil {
+		return SelfTestReport{}, err
+	}
+
+	delta := report.SelfTest.Sub(report.Baseline)
+	report.Delta = Vector{math.Abs(delta.X), math.Abs(delta.Y), math.Abs(delta.Z)}
+	report.Min, report.Max = procedure.min, procedure.max
+
+	deltas := [3]float64{report.Delta.X, report.Delta.Y, report.Delta.Z}
+	mins := [3]float64{procedure.min.X, procedure.min.Y, procedure.min.Z}
+	maxs := [3]float64{procedure.max.X, procedure.max.Y, procedure.max.Z}
+	report.Passed = true
+	for i := range deltas {
+		report.AxisPassed[i] = deltas[i] >= mins[i] && deltas[i] <= maxs[i]
+		report.Passed = report.Passed && report.AxisPassed[i]
+	}
+	return report, nil
+}
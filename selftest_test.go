@@ -0,0 +1,129 @@
+package lsm303
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/i2c/i2ctest"
+	"periph.io/x/periph/conn/mmr"
+)
+
+// Scripts a self-test run: the control register read, then samples+1
+// readings with the self-test off, the enable write, samples+1 readings with
+// it on and the restoring write.
+func selfTestScript(addr uint16, status, control, enable uint8, data [6]uint8, samples int, baseline, selfTest [3]int16) []i2ctest.IO {
+	readings := func(values [3]int16) []i2ctest.IO {
+		var ops []i2ctest.IO
+		for n := 0; n <= samples; n++ {
+			ops = append(ops, i2ctest.IO{Addr: addr, W: []byte{status}, R: []byte{0xFF}})
+			for axis, value := range values {
+				ops = append(ops,
+					i2ctest.IO{Addr: addr, W: []byte{data[2*axis]}, R: []byte{uint8(value)}},
+					i2ctest.IO{Addr: addr, W: []byte{data[2*axis+1]}, R: []byte{uint8(uint16(value) >> 8)}})
+			}
+		}
+		return ops
+	}
+
+	ops := []i2ctest.IO{{Addr: addr, W: []byte{control}, R: []byte{0x80}}}
+	ops = append(ops, readings(baseline)...)
+	ops = append(ops, i2ctest.IO{Addr: addr, W: []byte{control, 0x80 | enable}})
+	ops = append(ops, readings(selfTest)...)
+	return append(ops, i2ctest.IO{Addr: addr, W: []byte{control, 0x80}})
+}
+
+func selfTestAccelerometer(selfTest [3]int16) (*Accelerometer, *i2ctest.Playback) {
+	d := accelerometerDatasheet
+	// Running at 10 Hz, ±4G in high resolution mode, switched to 100 Hz, ±2G
+	// in normal mode with block data update for the test and back after it
+	ops := []i2ctest.IO{
+		{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A}, R: []byte{0x27}},
+		{Addr: d.ADDRESS, W: []byte{d.CTRL_REG4_A}, R: []byte{0x18}},
+		{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A, 0x57}},
+		{Addr: d.ADDRESS, W: []byte{d.CTRL_REG4_A, 0x80}},
+	}
+	ops = append(ops, selfTestScript(d.ADDRESS, d.STATUS_REG_A, d.CTRL_REG4_A, 0b10,
+		[6]uint8{d.OUT_X_L_A, d.OUT_X_H_A, d.OUT_Y_L_A, d.OUT_Y_H_A, d.OUT_Z_L_A, d.OUT_Z_H_A},
+		5, [3]int16{0, 0, 16000}, selfTest)...)
+	ops = append(ops,
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{d.CTRL_REG1_A, 0x27}},
+		i2ctest.IO{Addr: d.ADDRESS, W: []byte{d.CTRL_REG4_A, 0x18}})
+	scenario := &i2ctest.Playback{Ops: ops}
+
+	return &Accelerometer{
+		mmr: mmr.Dev8{
+			Conn:  &i2c.Dev{Bus: scenario, Addr: d.ADDRESS},
+			Order: binary.BigEndian,
+		},
+		sensorType: LSM303DLHC,
+		datasheet:  d,
+		range_:     ACCELEROMETER_RANGE_4G,
+		mode:       ACCELEROMETER_MODE_HIGH_RESOLUTION,
+	}, scenario
+}
+
+func TestAccelerometerSelfTestPass(t *testing.T) {
+	// About 0.2 g of actuation on every axis
+	accelerometer, scenario := selfTestAccelerometer([3]int16{3200, 3200, 19200})
+	report, err := accelerometer.SelfTest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Passed {
+		t.Fatalf("expected a pass: %s", report)
+	}
+	if report.Delta.X < 0.15 || report.Delta.X > 0.25 {
+		t.Fatalf("unexpected delta %+v", report.Delta)
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccelerometerSelfTestFail(t *testing.T) {
+	// Y doesn't respond at all
+	accelerometer, _ := selfTestAccelerometer([3]int16{3200, 0, 19200})
+	report, err := accelerometer.SelfTest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Passed || !report.AxisPassed[0] || report.AxisPassed[1] || !report.AxisPassed[2] {
+		t.Fatalf("expected only Y to fail: %s", report)
+	}
+}
+
+func TestMagnetometerSelfTestAGR(t *testing.T) {
+	d := datasheetForMagnetometer(LSM303AGR)
+	scenario := &i2ctest.Playback{Ops: selfTestScript(d.ADDRESS, d.SR_REG_M, 0x62, 0b10,
+		[6]uint8{d.OUT_X_L_M, d.OUT_X_H_M, d.OUT_Y_L_M, d.OUT_Y_H_M, d.OUT_Z_L_M, d.OUT_Z_H_M},
+		50, [3]int16{100, -200, 300}, [3]int16{200, -100, 400})}
+
+	magnetometer := &Magnetometer{
+		mmr: mmr.Dev8{
+			Conn:  &i2c.Dev{Bus: scenario, Addr: d.ADDRESS},
+			Order: binary.BigEndian,
+		},
+		sensorType: LSM303AGR,
+		datasheet:  d,
+	}
+	report, err := magnetometer.SelfTest(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 100 LSb at 1.5 mgauss/LSb
+	if !report.Passed || report.Delta.Sub(Vector{0.15, 0.15, 0.15}).Norm() > 1e-9 {
+		t.Fatalf("unexpected report: %s", report)
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMagnetometerSelfTestUnsupported(t *testing.T) {
+	magnetometer := &Magnetometer{sensorType: LSM303DLHC, datasheet: magnetometerDatasheet}
+	if _, err := magnetometer.SelfTest(context.Background()); err == nil {
+		t.Fatal("expected an error on the DLHC")
+	}
+}
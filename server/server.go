@@ -0,0 +1,416 @@
+// Package server serves the readings and configuration of an LSM303 as JSON
+// over HTTP, and streams samples as server-sent events:
+//
+//	GET /readings             the current readings
+//	GET /config               the configuration
+//	GET /config/{setting}     one setting: range, mode, gain or rate
+//	PUT /config/{setting}     changes it, the body is the new value as JSON
+//	GET /stream?rate=10&n=100 samples at the rate in Hz, n of them or forever
+//
+// The settings are written as the command-line tool takes them: the range as
+// "8G", the mode as "high-resolution", the gain in gauss as "1.3" and the rate
+// as a number of Hz. The magnetometer gain is fixed on the AGR and C.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+	"periph.io/x/periph/conn/physic"
+)
+
+// Server is an http.Handler for the sensors. Requests are serialized, the
+// sensors aren't read concurrently.
+type Server struct {
+	mu            sync.Mutex
+	accelerometer *lsm303.Accelerometer
+	magnetometer  *lsm303.Magnetometer
+	compass       *lsm303.Compass
+	maxRate       float64
+	mux           *http.ServeMux
+}
+
+// Reading is what GET /readings returns, and the stream sends without the
+// temperature and heading. What a sensor doesn't measure is left out.
+type Reading struct {
+	Time time.Time `json:"time"`
+	// In units of standard gravity
+	Acceleration *lsm303.Vector `json:"acceleration,omitempty"`
+	// In gauss
+	MagneticField *lsm303.Vector `json:"magnetic_field,omitempty"`
+	// The uncalibrated die temperature in °C, about 20°C below the actual one
+	Temperature *float64 `json:"temperature,omitempty"`
+	// Tilt-compensated, in degrees clockwise from magnetic north
+	Heading *float64 `json:"heading,omitempty"`
+}
+
+// Config is what GET /config returns. Settings of a missing sensor, and the
+// gain where it is fixed, are left out.
+type Config struct {
+	Range *lsm303.AccelerometerRange `json:"range,omitempty"`
+	Mode  *string                    `json:"mode,omitempty"`
+	Gain  *lsm303.MagnetometerGain   `json:"gain,omitempty"`
+	Rate  *float64                   `json:"rate,omitempty"`
+}
+
+// New creates a server for the given sensors, either can be nil. When both
+// are given the readings include the heading, from a default compass unless
+// WithCompass is used.
+func New(accelerometer *lsm303.Accelerometer, magnetometer *lsm303.Magnetometer, opts ...Option) (*Server, error) {
+	if accelerometer == nil && magnetometer == nil {
+		return nil, errors.New("no sensor to serve")
+	}
+	s := &Server{
+		accelerometer: accelerometer,
+		magnetometer:  magnetometer,
+		maxRate:       100,
+		mux:           http.NewServeMux(),
+	}
+	for i := range opts {
+		opts[i].Apply(s)
+	}
+
+	if s.compass == nil && accelerometer != nil && magnetometer != nil {
+		compass, err := lsm303.NewCompass(accelerometer, magnetometer)
+		if err != nil {
+			return nil, err
+		}
+		s.compass = compass
+	}
+
+	s.mux.HandleFunc("GET /readings", s.getReadings)
+	s.mux.HandleFunc("GET /config", s.getConfig)
+	s.mux.HandleFunc("GET /config/{setting}", s.getSetting)
+	s.mux.HandleFunc("PUT /config/{setting}", s.putSetting)
+	s.mux.HandleFunc("GET /stream", s.stream)
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) getReadings(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reading, err := s.sense()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if s.magnetometer != nil && s.magnetometer.SensorType() != lsm303.LSM303AGR {
+		// The driver doesn't read the AGR temperature sensor
+		temperature, err := s.magnetometer.SenseRelativeTemperature()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		celsius := float64(temperature-physic.ZeroCelsius) / float64(physic.Celsius)
+		reading.Temperature = &celsius
+	}
+	if s.compass != nil {
+		heading, err := s.compass.Heading()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		reading.Heading = &heading
+	}
+	writeJSON(w, reading)
+}
+
+// sense reads a sample of each sensor, with the time of the first.
+func (s *Server) sense() (Reading, error) {
+	var reading Reading
+	if s.accelerometer != nil {
+		sample, err := s.accelerometer.SenseSample()
+		if err != nil {
+			return Reading{}, err
+		}
+		reading.Time, reading.Acceleration = sample.Time, &sample.Acceleration
+	}
+	if s.magnetometer != nil {
+		sample, err := s.magnetometer.SenseSample()
+		if err != nil {
+			return Reading{}, err
+		}
+		if reading.Time.IsZero() {
+			reading.Time = sample.Time
+		}
+		reading.MagneticField = &sample.MagneticField
+	}
+	return reading, nil
+}
+
+func (s *Server) getConfig(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var config Config
+	for _, setting := range []string{"range", "mode", "gain", "rate"} {
+		value, status, err := s.get(setting)
+		switch {
+		case status == http.StatusNotFound:
+			continue
+		case err != nil:
+			writeError(w, status, err)
+			return
+		}
+		switch value := value.(type) {
+		case lsm303.AccelerometerRange:
+			config.Range = &value
+		case string:
+			config.Mode = &value
+		case lsm303.MagnetometerGain:
+			config.Gain = &value
+		case float64:
+			config.Rate = &value
+		}
+	}
+	writeJSON(w, config)
+}
+
+func (s *Server) getSetting(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, status, err := s.get(r.PathValue("setting"))
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+	writeJSON(w, value)
+}
+
+// get reads a setting from the sensor, and the HTTP status to answer if it
+// can't.
+func (s *Server) get(setting string) (any, int, error) {
+	if status, err := s.check(setting); err != nil {
+		return nil, status, err
+	}
+
+	var value any
+	var err error
+	switch setting {
+	case "range":
+		value, err = s.accelerometer.GetRange()
+	case "mode":
+		var mode lsm303.AccelerometerMode
+		mode, err = s.accelerometer.GetMode()
+		value = modeName(mode)
+	case "gain":
+		value, err = s.magnetometer.GetGain()
+	case "rate":
+		var rate lsm303.MagnetometerRate
+		if rate, err = s.magnetometer.GetRate(); err == nil {
+			value, _ = rate.Hertz(s.magnetometer.SensorType())
+		}
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return value, http.StatusOK, nil
+}
+
+func (s *Server) putSetting(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	setting := r.PathValue("setting")
+	if status, err := s.check(setting); err != nil {
+		writeError(w, status, err)
+		return
+	}
+
+	// A value that doesn't decode is the client's fault, failing to set
+	// it the sensor's
+	decoder := json.NewDecoder(r.Body)
+	var set func() error
+	var err error
+	switch setting {
+	case "range":
+		var range_ lsm303.AccelerometerRange
+		err = decoder.Decode(&range_)
+		set = func() error { return s.accelerometer.SetRange(range_) }
+	case "mode":
+		var name string
+		var mode lsm303.AccelerometerMode
+		if err = decoder.Decode(&name); err == nil {
+			mode, err = parseMode(name)
+		}
+		set = func() error { return s.accelerometer.SetMode(mode) }
+	case "gain":
+		var gain lsm303.MagnetometerGain
+		err = decoder.Decode(&gain)
+		set = func() error { return s.magnetometer.SetGain(gain) }
+	case "rate":
+		var hz float64
+		var rate lsm303.MagnetometerRate
+		if err = decoder.Decode(&hz); err == nil {
+			rate, err = lsm303.MagnetometerRateOf(s.magnetometer.SensorType(), hz)
+		}
+		set = func() error { return s.magnetometer.SetRate(rate) }
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := set(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	value, status, err := s.get(setting)
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+	writeJSON(w, value)
+}
+
+// check returns an error if the setting doesn't exist on the sensors served.
+func (s *Server) check(setting string) (int, error) {
+	switch setting {
+	case "range", "mode":
+		if s.accelerometer == nil {
+			return http.StatusNotFound, errors.New("no accelerometer")
+		}
+	case "gain":
+		if s.magnetometer == nil {
+			return http.StatusNotFound, errors.New("no magnetometer")
+		}
+		if sensorType := s.magnetometer.SensorType(); sensorType != lsm303.LSM303DLHC {
+			return http.StatusNotFound, fmt.Errorf("the %s magnetometer gain is fixed", sensorType)
+		}
+	case "rate":
+		if s.magnetometer == nil {
+			return http.StatusNotFound, errors.New("no magnetometer")
+		}
+	default:
+		return http.StatusNotFound, fmt.Errorf("unknown setting %q", setting)
+	}
+	return http.StatusOK, nil
+}
+
+// stream sends samples as server-sent events until the client goes away, or
+// n of them if given.
+func (s *Server) stream(w http.ResponseWriter, r *http.Request) {
+	rate, n := 10.0, -1
+	var err error
+	if value := r.FormValue("rate"); value != "" {
+		if rate, err = strconv.ParseFloat(value, 64); err != nil || rate <= 0 || rate > s.maxRate {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("rate must be a number of Hz up to %g", s.maxRate))
+			return
+		}
+	}
+	if value := r.FormValue("n"); value != "" {
+		if n, err = strconv.Atoi(value); err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, errors.New("n must be a positive number of samples"))
+			return
+		}
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming isn't supported"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer ticker.Stop()
+	for sent := 0; n < 0 || sent < n; sent++ {
+		s.mu.Lock()
+		reading, err := s.sense()
+		s.mu.Unlock()
+
+		// A failed read ends the stream, the client can reconnect
+		if err != nil {
+			data, _ := json.Marshal(map[string]string{"error": err.Error()})
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+			flusher.Flush()
+			return
+		}
+		data, err := json.Marshal(reading)
+		if err != nil {
+			return
+		}
+		if _, err := fmt.Fprintf(w, "event: sample\ndata: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// The modes as the command-line tool names them.
+func modeName(mode lsm303.AccelerometerMode) string {
+	return strings.ReplaceAll(mode.String(), " ", "-")
+}
+
+func parseMode(name string) (lsm303.AccelerometerMode, error) {
+	for mode := lsm303.ACCELEROMETER_MODE_NORMAL; mode <= lsm303.ACCELEROMETER_MODE_LOW_POWER; mode++ {
+		if modeName(mode) == name {
+			return mode, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown accelerometer mode %q", name)
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+type (
+	// Option configures a Server.
+	Option interface {
+		Apply(*Server)
+	}
+	// OptionFunc is a function that configures a server.
+	OptionFunc func(*Server)
+)
+
+// Apply calls OptionFunc on server instance
+func (f OptionFunc) Apply(s *Server) {
+	f(s)
+}
+
+// WithCompass serves the heading of the given compass, e.g. the one the rest
+// of the program reads, so clients see the same heading. Readings carry the
+// magnetic heading whatever declination the compass is set up with.
+func WithCompass(compass *lsm303.Compass) Option {
+	return OptionFunc(func(s *Server) {
+		s.compass = compass
+	})
+}
+
+// WithMaxStreamRate limits the rate clients can stream samples at.
+// Default is 100 Hz.
+func WithMaxStreamRate(hz float64) Option {
+	return OptionFunc(func(s *Server) {
+		s.maxRate = hz
+	})
+}
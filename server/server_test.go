@@ -0,0 +1,204 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+	"github.com/timoth-y/go-lsm303/simulator"
+)
+
+func newServer(t *testing.T, sensorType lsm303.SensorType) *httptest.Server {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	bus, err := simulator.New(sensorType, simulator.Static(simulator.Level), simulator.WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	accelerometer, err := lsm303.NewAccelerometer(bus, lsm303.WithAccelerometerSensorType(sensorType))
+	if err != nil {
+		t.Fatal(err)
+	}
+	magnetometer, err := lsm303.NewMagnetometer(bus, lsm303.WithMagnetometerSensorType(sensorType))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Let the first samples be converted
+	now = now.Add(time.Second)
+
+	s, err := New(accelerometer, magnetometer, WithMaxStreamRate(200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// request sends body as JSON, if given, and decodes the response into v.
+func request(t *testing.T, method, url string, body any, v any) int {
+	var encoded strings.Builder
+	if body != nil {
+		json.NewEncoder(&encoded).Encode(body)
+	}
+	r, err := http.NewRequest(method, url, strings.NewReader(encoded.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+			t.Fatalf("%s %s: %v", method, url, err)
+		}
+	}
+	return response.StatusCode
+}
+
+func near(a, b lsm303.Vector) bool {
+	return math.Abs(a.X-b.X) < 0.01 && math.Abs(a.Y-b.Y) < 0.01 && math.Abs(a.Z-b.Z) < 0.01
+}
+
+func TestReadings(t *testing.T) {
+	server := newServer(t, lsm303.LSM303DLHC)
+	var reading Reading
+	if status := request(t, "GET", server.URL+"/readings", nil, &reading); status != http.StatusOK {
+		t.Fatalf("status %d", status)
+	}
+	if reading.Time.IsZero() || reading.Acceleration == nil || !near(*reading.Acceleration, simulator.Level.Acceleration) ||
+		reading.MagneticField == nil || !near(*reading.MagneticField, simulator.Level.MagneticField) {
+		t.Errorf("unexpected reading %+v", reading)
+	}
+	if reading.Temperature == nil || reading.Heading == nil || math.Abs(math.Mod(*reading.Heading+180, 360)-180) > 0.1 {
+		t.Errorf("unexpected temperature %v or heading %v", reading.Temperature, reading.Heading)
+	}
+
+	// The driver doesn't read the AGR temperature sensor
+	server = newServer(t, lsm303.LSM303AGR)
+	reading = Reading{}
+	request(t, "GET", server.URL+"/readings", nil, &reading)
+	if reading.Temperature != nil || reading.Heading == nil {
+		t.Errorf("unexpected AGR reading %+v", reading)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	server := newServer(t, lsm303.LSM303DLHC)
+	for setting, value := range map[string]any{"range": "16G", "mode": "low-power", "gain": "8.1", "rate": 75.0} {
+		var updated any
+		if status := request(t, "PUT", server.URL+"/config/"+setting, value, &updated); status != http.StatusOK || updated != value {
+			t.Errorf("PUT %s %v: status %d, %v", setting, value, status, updated)
+		}
+		var read any
+		if request(t, "GET", server.URL+"/config/"+setting, nil, &read); read != value {
+			t.Errorf("GET %s is %v, want %v", setting, read, value)
+		}
+	}
+
+	var config map[string]any
+	request(t, "GET", server.URL+"/config", nil, &config)
+	if config["range"] != "16G" || config["mode"] != "low-power" || config["gain"] != "8.1" || config["rate"] != 75.0 {
+		t.Errorf("unexpected config %v", config)
+	}
+
+	for _, test := range []struct {
+		method, setting string
+		value           any
+		status          int
+	}{
+		{"PUT", "range", "3G", http.StatusBadRequest},
+		{"PUT", "mode", "fast", http.StatusBadRequest},
+		{"PUT", "rate", 76, http.StatusBadRequest},
+		{"PUT", "rate", "75", http.StatusBadRequest},
+		{"PUT", "speed", 1, http.StatusNotFound},
+		{"GET", "speed", nil, http.StatusNotFound},
+		{"POST", "rate", 75, http.StatusMethodNotAllowed},
+	} {
+		if status := request(t, test.method, server.URL+"/config/"+test.setting, test.value, nil); status != test.status {
+			t.Errorf("%s %s %v: status %d, want %d", test.method, test.setting, test.value, status, test.status)
+		}
+	}
+}
+
+func TestConfigOfFixedGain(t *testing.T) {
+	server := newServer(t, lsm303.LSM303C)
+	var config map[string]any
+	request(t, "GET", server.URL+"/config", nil, &config)
+	if _, ok := config["gain"]; ok || config["rate"] != 20.0 {
+		t.Errorf("unexpected config %v", config)
+	}
+
+	var response map[string]string
+	if status := request(t, "PUT", server.URL+"/config/gain", "8.1", &response); status != http.StatusNotFound || response["error"] == "" {
+		t.Errorf("PUT gain: status %d, %v", status, response)
+	}
+	// The C rates are its own
+	var rate float64
+	if request(t, "PUT", server.URL+"/config/rate", 0.625, &rate); rate != 0.625 {
+		t.Errorf("rate is %v", rate)
+	}
+}
+
+func TestStream(t *testing.T) {
+	server := newServer(t, lsm303.LSM303C)
+	response, err := http.Get(server.URL + "/stream?rate=100&n=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if contentType := response.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Content-Type is %q", contentType)
+	}
+
+	var readings []Reading
+	scanner := bufio.NewScanner(response.Body)
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if event != "sample" {
+				t.Fatalf("%s event: %s", event, line)
+			}
+			var reading Reading
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &reading); err != nil {
+				t.Fatal(err)
+			}
+			readings = append(readings, reading)
+		}
+	}
+	if len(readings) != 3 {
+		t.Fatalf("streamed %d samples, want 3", len(readings))
+	}
+	for _, reading := range readings {
+		if reading.Acceleration == nil || !near(*reading.Acceleration, simulator.Level.Acceleration) ||
+			reading.MagneticField == nil || reading.Temperature != nil || reading.Heading != nil {
+			t.Errorf("unexpected sample %+v", reading)
+		}
+	}
+}
+
+func TestStreamRejectsRate(t *testing.T) {
+	server := newServer(t, lsm303.LSM303C)
+	for _, query := range []string{"rate=500", "rate=0", "rate=fast", "n=-1"} {
+		if status := request(t, "GET", server.URL+"/stream?"+query, nil, nil); status != http.StatusBadRequest {
+			t.Errorf("%s: status %d", query, status)
+		}
+	}
+}
+
+func TestNewWithoutSensors(t *testing.T) {
+	if _, err := New(nil, nil); err == nil {
+		t.Error("created a server without sensors")
+	}
+}
@@ -0,0 +1,135 @@
+package simulator
+
+import (
+	"math"
+	"time"
+)
+
+// A register-level model of one half of a variant. The variant specific parts
+// are in the model, the register file, conversion timing and data-ready
+// handling are shared.
+type device struct {
+	name    string
+	address uint16
+	model   model
+
+	registers [256]uint8
+	// Time of the last conversion, negative while powered down
+	converted time.Duration
+	dataReady bool
+}
+
+// The period of a device in single conversion mode. It converts on the next
+// access, then powers down.
+const singleShot time.Duration = -1
+
+type model struct {
+	// Register values after power-up or a soft reset
+	defaults map[uint8]uint8
+	// Registers the host can write, writes to others are ignored
+	writable map[uint8]bool
+	// Bits that clear themselves once the action they trigger is done, and
+	// those of them that reset the registers to their defaults
+	selfClearing map[uint8]uint8
+	resets       map[uint8]uint8
+	// The register a transfer starting at sub-address begins with, and
+	// whether it moves on to the next register for every byte
+	autoIncrement func(registers *[256]uint8, sub uint8) (uint8, bool)
+	// Conversion period, zero when powered down or singleShot
+	period func(registers *[256]uint8) time.Duration
+	// Powers down after a single conversion
+	powerDown func(registers *[256]uint8)
+	// Writes a conversion to the output registers
+	latch func(registers *[256]uint8, sample Sample)
+	// Status register and its data-ready and overrun bits. Reading any of the
+	// outputs clears them.
+	status        uint8
+	dataReadyBits uint8
+	overrunBits   uint8
+	outputs       map[uint8]bool
+}
+
+func newDevice(name string, address uint16, m model) *device {
+	d := &device{name: name, address: address, model: m}
+	d.reset()
+	return d
+}
+
+func (d *device) reset() {
+	d.registers = [256]uint8{}
+	for register, value := range d.model.defaults {
+		d.registers[register] = value
+	}
+	d.converted = -1
+	d.dataReady = false
+}
+
+// Brings the outputs up to date with the time now.
+func (d *device) update(now time.Duration, source Source) {
+	switch period := d.model.period(&d.registers); {
+	case period == 0:
+		d.converted = -1
+	case period == singleShot:
+		d.convert(now, source)
+		d.model.powerDown(&d.registers)
+		d.converted = -1
+	case d.converted < 0:
+		// Powering up, the first conversion takes a period
+		d.converted = now
+	case now-d.converted >= period:
+		d.convert(now-(now-d.converted)%period, source)
+	}
+}
+
+func (d *device) convert(at time.Duration, source Source) {
+	// A conversion that was never read is lost
+	if d.dataReady {
+		d.registers[d.model.status] |= d.model.overrunBits
+	}
+	d.converted = at
+	d.model.latch(&d.registers, source.Sample(at))
+	d.registers[d.model.status] |= d.model.dataReadyBits
+	d.dataReady = true
+}
+
+func (d *device) read(sub uint8, r []byte) {
+	register, increment := d.model.autoIncrement(&d.registers, sub)
+	for i := range r {
+		r[i] = d.registers[register]
+		if d.model.outputs[register] {
+			d.registers[d.model.status] &^= d.model.dataReadyBits | d.model.overrunBits
+			d.dataReady = false
+		}
+		if increment {
+			register++
+		}
+	}
+}
+
+func (d *device) write(sub uint8, w []byte) {
+	register, increment := d.model.autoIncrement(&d.registers, sub)
+	for _, value := range w {
+		if d.model.writable[register] {
+			if value&d.model.resets[register] != 0 {
+				d.reset()
+			} else {
+				d.registers[register] = value &^ d.model.selfClearing[register]
+			}
+		}
+		if increment {
+			register++
+		}
+	}
+}
+
+// Stores a count at the low and high byte registers.
+func putInt16(registers *[256]uint8, low, high uint8, value int16) {
+	registers[low] = uint8(value)
+	registers[high] = uint8(uint16(value) >> 8)
+}
+
+// Converts to counts with the given scale, saturating at the int16 limits.
+func counts(value, perUnit float64) int16 {
+	count := math.Round(value * perUnit)
+	return int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, count)))
+}
@@ -0,0 +1,182 @@
+// Package simulator is an in-memory LSM303 on an I²C bus, for testing code
+// that uses the driver without hardware.
+//
+// The simulated chip has the register map of the chosen variant: WHO_AM_I,
+// control registers with their reset values, auto-increment, data-ready and
+// overrun flags, self-test and output registers converted at the configured
+// data rate from a Source. Conversions follow the clock, so reads faster than
+// the data rate see the same sample.
+//
+//	bus, _ := simulator.New(lsm303.LSM303DLHC, simulator.Static(simulator.Level))
+//	accelerometer, _ := lsm303.NewAccelerometer(bus)
+package simulator
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+	"periph.io/x/periph/conn/physic"
+)
+
+// Bus is a simulated I²C bus with an accelerometer and a magnetometer on it.
+// It implements i2c.Bus and is safe for concurrent use.
+type Bus struct {
+	mu         sync.Mutex
+	sensorType lsm303.SensorType
+	source     Source
+	now        func() time.Time
+	start      time.Time
+	noise      struct{ accelerometer, magnetometer float64 }
+	random     *rand.Rand
+	devices    []*device
+}
+
+// New creates a bus with the given variant on it, at its default addresses.
+func New(sensorType lsm303.SensorType, source Source, opts ...Option) (*Bus, error) {
+	b := &Bus{
+		sensorType: sensorType,
+		source:     source,
+		now:        time.Now,
+		random:     rand.New(rand.NewSource(1)),
+	}
+
+	switch sensorType {
+	case lsm303.LSM303DLHC, lsm303.LSM303AGR:
+		magnetometer := dlhcMagnetometer()
+		if sensorType == lsm303.LSM303AGR {
+			magnetometer = agrMagnetometer()
+		}
+		b.devices = []*device{
+			newDevice("accelerometer", 0x19, dlhcAccelerometer(sensorType)),
+			newDevice("magnetometer", 0x1E, magnetometer),
+		}
+	case lsm303.LSM303C:
+		b.devices = []*device{
+			newDevice("accelerometer", 0x1D, lsm303cAccelerometer()),
+			newDevice("magnetometer", 0x1E, lsm303cMagnetometer()),
+		}
+	default:
+		return nil, fmt.Errorf("can't simulate %s", sensorType)
+	}
+
+	for i := range opts {
+		opts[i].Apply(b)
+	}
+	b.start = b.now()
+	return b, nil
+}
+
+func (b *Bus) String() string {
+	return fmt.Sprintf("%s simulator", b.sensorType)
+}
+
+// Tx addresses a register with the first byte written, writes the rest from
+// there and then reads into r.
+func (b *Bus) Tx(addr uint16, w, r []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	d := b.device(addr)
+	if d == nil {
+		return fmt.Errorf("simulator: no device at address 0x%02X", addr)
+	}
+	if len(w) == 0 {
+		return fmt.Errorf("simulator: %s transaction without a register address", d.name)
+	}
+
+	// Again after the write, so configuration changes take effect from now
+	now := b.now().Sub(b.start)
+	d.update(now, SourceFunc(b.sample))
+	d.write(w[0], w[1:])
+	d.update(now, SourceFunc(b.sample))
+	d.read(w[0], r)
+	return nil
+}
+
+// SetSpeed accepts any speed.
+func (b *Bus) SetSpeed(physic.Frequency) error {
+	return nil
+}
+
+// SetSource changes what the sensor measures from the next conversion on.
+func (b *Bus) SetSource(source Source) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.source = source
+}
+
+// Peek returns a register of the device at addr without the side effects of
+// reading it over the bus, for checking what the driver configured.
+func (b *Bus) Peek(addr uint16, register uint8) (uint8, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	d := b.device(addr)
+	if d == nil {
+		return 0, fmt.Errorf("simulator: no device at address 0x%02X", addr)
+	}
+	d.update(b.now().Sub(b.start), SourceFunc(b.sample))
+	return d.registers[register], nil
+}
+
+func (b *Bus) device(addr uint16) *device {
+	for _, d := range b.devices {
+		if d.address == addr {
+			return d
+		}
+	}
+	return nil
+}
+
+func (b *Bus) sample(elapsed time.Duration) Sample {
+	sample := b.source.Sample(elapsed)
+	sample.Acceleration = sample.Acceleration.Add(b.gaussian(b.noise.accelerometer))
+	sample.MagneticField = sample.MagneticField.Add(b.gaussian(b.noise.magnetometer))
+	return sample
+}
+
+func (b *Bus) gaussian(sigma float64) lsm303.Vector {
+	if sigma == 0 {
+		return lsm303.Vector{}
+	}
+	return lsm303.Vector{
+		X: b.random.NormFloat64() * sigma,
+		Y: b.random.NormFloat64() * sigma,
+		Z: b.random.NormFloat64() * sigma,
+	}
+}
+
+type (
+	// Option configures a Bus.
+	Option interface {
+		Apply(*Bus)
+	}
+	// OptionFunc is a function that configures a bus.
+	OptionFunc func(*Bus)
+)
+
+// Apply calls OptionFunc on bus instance
+func (f OptionFunc) Apply(b *Bus) {
+	f(b)
+}
+
+// WithClock can be used to drive the conversions from a fake clock. Default is
+// time.Now.
+func WithClock(now func() time.Time) Option {
+	return OptionFunc(func(b *Bus) {
+		b.now = now
+	})
+}
+
+// WithNoise can be used to add gaussian noise to every conversion, with the
+// given standard deviations in units of standard gravity and gauss. The noise
+// is the same from run to run.
+func WithNoise(accelerometer, magnetometer float64) Option {
+	return OptionFunc(func(b *Bus) {
+		b.noise.accelerometer = accelerometer
+		b.noise.magnetometer = magnetometer
+	})
+}
@@ -0,0 +1,314 @@
+package simulator
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+	"periph.io/x/periph/conn/i2c"
+	"periph.io/x/periph/conn/physic"
+)
+
+var _ i2c.Bus = (*Bus)(nil)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+var tilted = Sample{
+	Acceleration:  lsm303.Vector{X: 0.5, Y: -0.25, Z: 0.8},
+	MagneticField: lsm303.Vector{X: 0.21, Y: -0.05, Z: -0.42},
+	Temperature:   12.5,
+}
+
+func near(a, b lsm303.Vector, tolerance float64) bool {
+	return math.Abs(a.X-b.X) <= tolerance && math.Abs(a.Y-b.Y) <= tolerance && math.Abs(a.Z-b.Z) <= tolerance
+}
+
+func TestSense(t *testing.T) {
+	for _, sensorType := range []lsm303.SensorType{lsm303.LSM303DLHC, lsm303.LSM303AGR, lsm303.LSM303C} {
+		clock := &fakeClock{now: time.Unix(0, 0)}
+		bus, err := New(sensorType, Static(tilted), WithClock(clock.Now))
+		if err != nil {
+			t.Fatal(err)
+		}
+		accelerometer, err := lsm303.NewAccelerometer(bus, lsm303.WithAccelerometerSensorType(sensorType))
+		if err != nil {
+			t.Fatalf("%s: %v", sensorType, err)
+		}
+		magnetometer, err := lsm303.NewMagnetometer(bus, lsm303.WithMagnetometerSensorType(sensorType))
+		if err != nil {
+			t.Fatalf("%s: %v", sensorType, err)
+		}
+		clock.Advance(time.Second)
+
+		x, y, z, err := accelerometer.Sense()
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := float64(physic.EarthGravity)
+		acceleration := lsm303.Vector{X: float64(x) / g, Y: float64(y) / g, Z: float64(z) / g}
+		// One count at ±4G in normal mode
+		if !near(acceleration, tilted.Acceleration, 0.008) {
+			t.Errorf("%s: sensed %v, want %v", sensorType, acceleration, tilted.Acceleration)
+		}
+
+		fx, fy, fz, err := magnetometer.Sense()
+		if err != nil {
+			t.Fatal(err)
+		}
+		gauss := float64(lsm303.Gauss)
+		field := lsm303.Vector{X: float64(fx) / gauss, Y: float64(fy) / gauss, Z: float64(fz) / gauss}
+		if !near(field, tilted.MagneticField, 0.002) {
+			t.Errorf("%s: sensed %v, want %v", sensorType, field, tilted.MagneticField)
+		}
+
+		if sensorType == lsm303.LSM303AGR {
+			continue
+		}
+		temperature, err := magnetometer.SenseRelativeTemperature()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if celsius := float64(temperature-physic.ZeroCelsius) / float64(physic.Celsius); celsius != tilted.Temperature {
+			t.Errorf("%s: temperature %g°C, want %g°C", sensorType, celsius, tilted.Temperature)
+		}
+	}
+}
+
+func TestWrongVariant(t *testing.T) {
+	bus, err := New(lsm303.LSM303AGR, Static(Level))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lsm303.NewMagnetometer(bus); err == nil {
+		t.Error("a LSM303DLHC magnetometer was found on a LSM303AGR")
+	}
+	if _, err := New("LSM303X", Static(Level)); err == nil {
+		t.Error("simulated an unknown variant")
+	}
+}
+
+func TestAutoIncrement(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	bus, err := New(lsm303.LSM303DLHC, Static(tilted), WithClock(clock.Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 100 Hz, then a conversion
+	if err := bus.Tx(0x19, []byte{0x20, 0x57}, nil); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(10 * time.Millisecond)
+
+	block := make([]byte, 6)
+	if err := bus.Tx(0x19, []byte{0x28 | 0x80}, block); err != nil {
+		t.Fatal(err)
+	}
+	// Without the MSB the same register is read over and over
+	repeated := make([]byte, 6)
+	if err := bus.Tx(0x19, []byte{0x28}, repeated); err != nil {
+		t.Fatal(err)
+	}
+	for i := range repeated {
+		if repeated[i] != block[0] {
+			t.Errorf("byte %d of a single register read is 0x%02X, want 0x%02X", i, repeated[i], block[0])
+		}
+	}
+	x := int16(uint16(block[1])<<8|uint16(block[0])) >> 6
+	if x != int16(math.Round(tilted.Acceleration.X*1000/3.9)) {
+		t.Errorf("X count is %d", x)
+	}
+
+	// The magnetometer always increments, its outputs are big endian X, Z, Y.
+	// Gain 4.0 gauss and continuous mode in one write.
+	if err := bus.Tx(0x1E, []byte{0x01, uint8(lsm303.MAGNETOMETER_GAIN_4_0) << 5, 0x00}, nil); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(100 * time.Millisecond)
+	if err := bus.Tx(0x1E, []byte{0x03}, block); err != nil {
+		t.Fatal(err)
+	}
+	if z := int16(uint16(block[2])<<8 | uint16(block[3])); z != int16(math.Round(tilted.MagneticField.Z*400)) {
+		t.Errorf("Z count is %d", z)
+	}
+}
+
+func TestDataReady(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	bus, err := New(lsm303.LSM303DLHC, Static(Level), WithClock(clock.Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := func() uint8 {
+		r := []byte{0}
+		if err := bus.Tx(0x19, []byte{0x27}, r); err != nil {
+			t.Fatal(err)
+		}
+		return r[0]
+	}
+
+	// Powered down after reset
+	clock.Advance(time.Second)
+	if s := status(); s != 0 {
+		t.Fatalf("status is %08b while powered down", s)
+	}
+
+	// 10 Hz
+	if err := bus.Tx(0x19, []byte{0x20, 0x27}, nil); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(50 * time.Millisecond)
+	if s := status(); s != 0 {
+		t.Errorf("status is %08b before the first conversion", s)
+	}
+	clock.Advance(50 * time.Millisecond)
+	if s := status(); s != 0x0F {
+		t.Errorf("status is %08b after a conversion, want 00001111", s)
+	}
+	clock.Advance(100 * time.Millisecond)
+	if s := status(); s != 0xFF {
+		t.Errorf("status is %08b after an unread conversion, want 11111111", s)
+	}
+
+	// Reading the outputs clears both
+	if err := bus.Tx(0x19, []byte{0x28 | 0x80}, make([]byte, 6)); err != nil {
+		t.Fatal(err)
+	}
+	if s := status(); s != 0 {
+		t.Errorf("status is %08b after reading the outputs", s)
+	}
+}
+
+func TestScript(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	script := Script(time.Second,
+		Sample{Acceleration: lsm303.Vector{Z: 1}},
+		Sample{Acceleration: lsm303.Vector{X: 1}},
+	)
+	bus, err := New(lsm303.LSM303DLHC, script, WithClock(clock.Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	accelerometer, err := lsm303.NewAccelerometer(bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []lsm303.Vector{{Z: 1}, {X: 1}, {X: 1}} {
+		clock.Advance(time.Second / 2)
+		x, y, z, err := accelerometer.Sense()
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := float64(physic.EarthGravity)
+		if sensed := (lsm303.Vector{X: float64(x) / g, Y: float64(y) / g, Z: float64(z) / g}); !near(sensed, expected, 0.01) {
+			t.Errorf("sensed %v, want %v", sensed, expected)
+		}
+		clock.Advance(time.Second / 2)
+	}
+}
+
+func TestSenseAveragedWithNoise(t *testing.T) {
+	bus, err := New(lsm303.LSM303DLHC, Static(tilted), WithNoise(0.02, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	accelerometer, err := lsm303.NewAccelerometer(bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	average, err := accelerometer.SenseAveraged(context.Background(), 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(average.Mean, tilted.Acceleration, 0.02) {
+		t.Errorf("mean is %v, want %v", average.Mean, tilted.Acceleration)
+	}
+	if average.Deviation.X < 0.01 || average.Deviation.X > 0.04 {
+		t.Errorf("deviation is %v, want about 0.02", average.Deviation)
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	bus, err := New(lsm303.LSM303C, Static(Level))
+	if err != nil {
+		t.Fatal(err)
+	}
+	accelerometer, err := lsm303.NewAccelerometer(bus, lsm303.WithAccelerometerSensorType(lsm303.LSM303C))
+	if err != nil {
+		t.Fatal(err)
+	}
+	magnetometer, err := lsm303.NewMagnetometer(bus, lsm303.WithMagnetometerSensorType(lsm303.LSM303C))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The self-test limits are for ±2G
+	if err := accelerometer.SetRange(lsm303.ACCELEROMETER_RANGE_2G); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []func(context.Context) (lsm303.SelfTestReport, error){accelerometer.SelfTest, magnetometer.SelfTest} {
+		report, err := test(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !report.Passed {
+			t.Error(report)
+		}
+	}
+
+	// The actuation is off again
+	if value, _ := bus.Peek(0x1D, 0x24); value&0x0C != 0 {
+		t.Errorf("CTRL_REG5_A is %08b after the self-test", value)
+	}
+}
+
+func TestHaltAndReset(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	bus, err := New(lsm303.LSM303C, Static(Level), WithClock(clock.Now))
+	if err != nil {
+		t.Fatal(err)
+	}
+	magnetometer, err := lsm303.NewMagnetometer(bus, lsm303.WithMagnetometerSensorType(lsm303.LSM303C))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := magnetometer.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	if status, _ := bus.Peek(0x1E, 0x27); status != 0 {
+		t.Errorf("status is %08b while halted", status)
+	}
+
+	// Soft reset puts it back in power-down, then the driver configures it
+	if err := magnetometer.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if mode, _ := bus.Peek(0x1E, 0x22); mode&0b11 != 0 {
+		t.Errorf("CTRL_REG3_M is %08b after a reset, want continuous mode", mode)
+	}
+	clock.Advance(time.Second)
+	if status, _ := bus.Peek(0x1E, 0x27); status&0x08 == 0 {
+		t.Errorf("status is %08b after a reset, want data", status)
+	}
+}
@@ -0,0 +1,62 @@
+package simulator
+
+import (
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+)
+
+// Sample is what the simulated sensor measures, in the sensor frame.
+type Sample struct {
+	// Acceleration in units of standard gravity, at rest this is the reaction
+	// to gravity, e.g. {0, 0, 1} lying flat
+	Acceleration lsm303.Vector
+	// Magnetic field in gauss
+	MagneticField lsm303.Vector
+	// Temperature as SenseRelativeTemperature reports it, in °C
+	Temperature float64
+}
+
+// Source drives the simulated sensor. Sample is called on every conversion
+// with the time since the bus was created.
+type Source interface {
+	Sample(elapsed time.Duration) Sample
+}
+
+// SourceFunc is a function that drives the simulated sensor.
+type SourceFunc func(elapsed time.Duration) Sample
+
+// Sample calls the function.
+func (f SourceFunc) Sample(elapsed time.Duration) Sample {
+	return f(elapsed)
+}
+
+// Static is a sensor that doesn't move.
+func Static(sample Sample) Source {
+	return SourceFunc(func(time.Duration) Sample {
+		return sample
+	})
+}
+
+// Script plays the samples one after the other, each for the given interval.
+// The last one is held once the script runs out.
+func Script(interval time.Duration, samples ...Sample) Source {
+	return SourceFunc(func(elapsed time.Duration) Sample {
+		if len(samples) == 0 {
+			return Sample{}
+		}
+		i := int(elapsed / interval)
+		if i >= len(samples) {
+			i = len(samples) - 1
+		}
+		return samples[i]
+	})
+}
+
+// Level is a sensor lying flat and still in a typical mid-latitude field,
+// pointing north.
+var Level = Sample{
+	Acceleration:  lsm303.Vector{X: 0, Y: 0, Z: 1},
+	MagneticField: lsm303.Vector{X: 0.2, Y: 0, Z: -0.4},
+	Temperature:   20,
+}
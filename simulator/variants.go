@@ -0,0 +1,285 @@
+package simulator
+
+import (
+	"time"
+
+	lsm303 "github.com/timoth-y/go-lsm303"
+)
+
+// Accelerometer sensitivity in mg per digit, by the driver's mode and range
+// (FS) settings, and the resolution of each mode. These are the values the
+// driver converts with, so a reading comes back as the sample that produced
+// it.
+var (
+	accelerometerSensitivity = [3][4]float64{
+		lsm303.ACCELEROMETER_MODE_NORMAL:          {3.9, 7.82, 15.63, 46.9},
+		lsm303.ACCELEROMETER_MODE_HIGH_RESOLUTION: {0.98, 1.95, 3.9, 11.72},
+		lsm303.ACCELEROMETER_MODE_LOW_POWER:       {15.63, 31.26, 62.52, 187.58},
+	}
+	accelerometerBits = [3]uint{
+		lsm303.ACCELEROMETER_MODE_NORMAL:          10,
+		lsm303.ACCELEROMETER_MODE_HIGH_RESOLUTION: 12,
+		lsm303.ACCELEROMETER_MODE_LOW_POWER:       8,
+	}
+)
+
+// What the self-test actuation adds to the output. Well inside the datasheet
+// limits, so SelfTest passes.
+var (
+	accelerometerSelfTest = lsm303.Vector{X: 0.3, Y: 0.3, Z: 0.3}
+	agrSelfTest           = lsm303.Vector{X: 0.1, Y: 0.1, Z: 0.1}
+	lsm303cSelfTest       = lsm303.Vector{X: 1.5, Y: 1.5, Z: 0.5}
+)
+
+// DLHC magnetometer counts per gauss by the driver's gain setting, X/Y and Z.
+var dlhcMagnetometerGain = [8][2]float64{
+	{1100, 980}, {855, 760}, {670, 600}, {450, 400}, {400, 355}, {330, 295}, {230, 205}, {230, 205},
+}
+
+func hertz(rate float64) time.Duration {
+	return time.Duration(float64(time.Second) / rate)
+}
+
+func registerSet(registers ...uint8) map[uint8]bool {
+	set := make(map[uint8]bool, len(registers))
+	for _, register := range registers {
+		set[register] = true
+	}
+	return set
+}
+
+func registerRange(first, last uint8) []uint8 {
+	var registers []uint8
+	for register := first; register <= last; register++ {
+		registers = append(registers, register)
+	}
+	return registers
+}
+
+// Both accelerometers put the output in OUT_X_L_A to OUT_Z_H_A, little endian
+// and left justified.
+func latchAcceleration(registers *[256]uint8, mode lsm303.AccelerometerMode, range_ uint8, acceleration lsm303.Vector) {
+	bits := accelerometerBits[mode]
+	perG := 1000 / accelerometerSensitivity[mode][range_]
+	limit := float64(int(1)<<(bits-1) - 1)
+	for i, g := range [3]float64{acceleration.X, acceleration.Y, acceleration.Z} {
+		count := float64(counts(g, perG))
+		if count > limit {
+			count = limit
+		} else if count < -limit-1 {
+			count = -limit - 1
+		}
+		putInt16(registers, 0x28+2*uint8(i), 0x29+2*uint8(i), int16(count)<<(16-bits))
+	}
+}
+
+func selfTestOffset(st uint8, offset lsm303.Vector) lsm303.Vector {
+	switch st {
+	case 0b01:
+		return offset
+	case 0b10:
+		return offset.Scale(-1)
+	default:
+		return lsm303.Vector{}
+	}
+}
+
+// The temperature format the driver reads, 12 bits left justified at 8 per
+// °C.
+func latchTemperature(registers *[256]uint8, low, high uint8, temperature float64) {
+	putInt16(registers, low, high, counts(temperature, 8)<<4)
+}
+
+func dlhcAccelerometer(sensorType lsm303.SensorType) model {
+	writable := registerSet(append(registerRange(0x20, 0x26), 0x2E, 0x30, 0x32, 0x33, 0x34, 0x36, 0x37, 0x38, 0x3A, 0x3B, 0x3C, 0x3D)...)
+	if sensorType == lsm303.LSM303AGR {
+		writable[0x1F], writable[0x3E], writable[0x3F] = true, true, true
+	}
+	rates := [...]float64{0, 1, 10, 25, 50, 100, 200, 400, 1620, 1344}
+
+	return model{
+		defaults:     map[uint8]uint8{0x0F: 0x33, 0x20: 0x07},
+		writable:     writable,
+		selfClearing: map[uint8]uint8{0x24: 0x80},
+		// The MSB of the sub-address turns auto-increment on
+		autoIncrement: func(_ *[256]uint8, sub uint8) (uint8, bool) {
+			return sub &^ 0x80, sub&0x80 != 0
+		},
+		period: func(registers *[256]uint8) time.Duration {
+			odr := int(registers[0x20] >> 4)
+			if odr >= len(rates) || rates[odr] == 0 {
+				return 0
+			}
+			return hertz(rates[odr])
+		},
+		latch: func(registers *[256]uint8, sample Sample) {
+			mode := lsm303.ACCELEROMETER_MODE_NORMAL
+			if registers[0x20]&0x08 != 0 {
+				mode = lsm303.ACCELEROMETER_MODE_LOW_POWER
+			} else if registers[0x23]&0x08 != 0 {
+				mode = lsm303.ACCELEROMETER_MODE_HIGH_RESOLUTION
+			}
+			offset := selfTestOffset(registers[0x23]>>1&0b11, accelerometerSelfTest)
+			latchAcceleration(registers, mode, registers[0x23]>>4&0b11, sample.Acceleration.Add(offset))
+		},
+		status:        0x27,
+		dataReadyBits: 0x0F,
+		overrunBits:   0xF0,
+		outputs:       registerSet(registerRange(0x28, 0x2D)...),
+	}
+}
+
+func lsm303cAccelerometer() model {
+	rates := [...]float64{0, 10, 50, 100, 200, 400, 800}
+	// The driver's range setting by FS: 00 = 2G, 10 = 4G, 11 = 8G. 01 is
+	// reserved, taken as 2G here.
+	ranges := [4]uint8{0, 0, 1, 2}
+
+	return model{
+		defaults:     map[uint8]uint8{0x0F: 0x41, 0x20: 0x07, 0x23: 0x04},
+		writable:     registerSet(append(registerRange(0x1E, 0x26), 0x2E, 0x30, 0x32, 0x33, 0x34, 0x35, 0x36, 0x37, 0x38)...),
+		selfClearing: map[uint8]uint8{0x24: 0x40, 0x25: 0x80},
+		resets:       map[uint8]uint8{0x24: 0x40},
+		// IF_ADD_INC in CTRL_REG4_A turns auto-increment on
+		autoIncrement: func(registers *[256]uint8, sub uint8) (uint8, bool) {
+			return sub, registers[0x23]&0x04 != 0
+		},
+		period: func(registers *[256]uint8) time.Duration {
+			odr := registers[0x20] >> 4 & 0b111
+			if int(odr) >= len(rates) || rates[odr] == 0 {
+				return 0
+			}
+			return hertz(rates[odr])
+		},
+		latch: func(registers *[256]uint8, sample Sample) {
+			// No low power mode, HR is CTRL_REG1_A bit 7
+			mode := lsm303.ACCELEROMETER_MODE_NORMAL
+			if registers[0x20]&0x80 != 0 {
+				mode = lsm303.ACCELEROMETER_MODE_HIGH_RESOLUTION
+			}
+			offset := selfTestOffset(registers[0x24]>>2&0b11, accelerometerSelfTest)
+			latchAcceleration(registers, mode, ranges[registers[0x23]>>4&0b11], sample.Acceleration.Add(offset))
+		},
+		status:        0x27,
+		dataReadyBits: 0x0F,
+		overrunBits:   0xF0,
+		outputs:       registerSet(registerRange(0x28, 0x2D)...),
+	}
+}
+
+// MD in the mode register: continuous, single conversion or powered down.
+func magnetometerPeriod(mode uint8, rate float64) time.Duration {
+	switch mode & 0b11 {
+	case 0b00:
+		return hertz(rate)
+	case 0b01:
+		return singleShot
+	default:
+		return 0
+	}
+}
+
+func dlhcMagnetometer() model {
+	rates := [...]float64{0.75, 1.5, 3, 7.5, 15, 30, 75, 220}
+
+	return model{
+		// IRA_REG_M to IRC_REG_M read "H43"
+		defaults: map[uint8]uint8{0x00: 0x10, 0x01: 0x20, 0x02: 0x03, 0x0A: 0x48, 0x0B: 0x34, 0x0C: 0x33},
+		writable: registerSet(0x00, 0x01, 0x02),
+		autoIncrement: func(_ *[256]uint8, sub uint8) (uint8, bool) {
+			return sub, true
+		},
+		period: func(registers *[256]uint8) time.Duration {
+			return magnetometerPeriod(registers[0x02], rates[registers[0x00]>>2&0b111])
+		},
+		powerDown: func(registers *[256]uint8) {
+			registers[0x02] |= 0b11
+		},
+		latch: func(registers *[256]uint8, sample Sample) {
+			gain := dlhcMagnetometerGain[registers[0x01]>>5]
+			// Big endian, in X Z Y order
+			field := sample.MagneticField
+			putInt16(registers, 0x04, 0x03, counts(field.X, gain[0]))
+			putInt16(registers, 0x06, 0x05, counts(field.Z, gain[1]))
+			putInt16(registers, 0x08, 0x07, counts(field.Y, gain[0]))
+			if registers[0x00]&0x80 != 0 {
+				latchTemperature(registers, 0x32, 0x31, sample.Temperature)
+			}
+		},
+		status:        0x09,
+		dataReadyBits: 0x01,
+		outputs:       registerSet(registerRange(0x03, 0x08)...),
+	}
+}
+
+func agrMagnetometer() model {
+	rates := [...]float64{10, 20, 50, 100}
+
+	return model{
+		defaults:     map[uint8]uint8{0x4F: 0x40, 0x60: 0x03},
+		writable:     registerSet(append(registerRange(0x45, 0x4A), 0x60, 0x61, 0x62, 0x63, 0x65, 0x66)...),
+		selfClearing: map[uint8]uint8{0x60: 0x60},
+		resets:       map[uint8]uint8{0x60: 0x20},
+		autoIncrement: func(_ *[256]uint8, sub uint8) (uint8, bool) {
+			return sub, true
+		},
+		period: func(registers *[256]uint8) time.Duration {
+			return magnetometerPeriod(registers[0x60], rates[registers[0x60]>>2&0b11])
+		},
+		powerDown: func(registers *[256]uint8) {
+			registers[0x60] |= 0b11
+		},
+		// The hard-iron offset registers are subtracted from the output. The
+		// magnetometer has no temperature output.
+		latch: func(registers *[256]uint8, sample Sample) {
+			field := sample.MagneticField
+			if registers[0x62]&0x02 != 0 {
+				field = field.Add(agrSelfTest)
+			}
+			for i, gauss := range [3]float64{field.X, field.Y, field.Z} {
+				offset := int16(uint16(registers[0x46+2*i])<<8 | uint16(registers[0x45+2*i]))
+				putInt16(registers, 0x68+2*uint8(i), 0x69+2*uint8(i), counts(gauss, 1000/1.5)-offset)
+			}
+		},
+		status:        0x67,
+		dataReadyBits: 0x0F,
+		overrunBits:   0xF0,
+		outputs:       registerSet(registerRange(0x68, 0x6D)...),
+	}
+}
+
+func lsm303cMagnetometer() model {
+	rates := [...]float64{0.625, 1.25, 2.5, 5, 10, 20, 40, 80}
+
+	return model{
+		defaults:     map[uint8]uint8{0x0F: 0x3D, 0x20: 0x10, 0x21: 0x60, 0x22: 0x03},
+		writable:     registerSet(append(registerRange(0x20, 0x24), 0x30, 0x32, 0x33)...),
+		selfClearing: map[uint8]uint8{0x21: 0x0C},
+		resets:       map[uint8]uint8{0x21: 0x04},
+		autoIncrement: func(_ *[256]uint8, sub uint8) (uint8, bool) {
+			return sub, true
+		},
+		period: func(registers *[256]uint8) time.Duration {
+			return magnetometerPeriod(registers[0x22], rates[registers[0x20]>>2&0b111])
+		},
+		powerDown: func(registers *[256]uint8) {
+			registers[0x22] |= 0b11
+		},
+		latch: func(registers *[256]uint8, sample Sample) {
+			field := sample.MagneticField
+			if registers[0x20]&0x01 != 0 {
+				field = field.Add(lsm303cSelfTest)
+			}
+			for i, gauss := range [3]float64{field.X, field.Y, field.Z} {
+				putInt16(registers, 0x28+2*uint8(i), 0x29+2*uint8(i), counts(gauss, 1000/0.58))
+			}
+			if registers[0x20]&0x80 != 0 {
+				latchTemperature(registers, 0x2E, 0x2F, sample.Temperature)
+			}
+		},
+		status:        0x27,
+		dataReadyBits: 0x0F,
+		overrunBits:   0xF0,
+		outputs:       registerSet(registerRange(0x28, 0x2D)...),
+	}
+}
@@ -0,0 +1,35 @@
+package lsm303
+
+// DataStatus is what the status register says about the output registers.
+type DataStatus struct {
+	// A new sample has been converted since the outputs were last read
+	Ready bool
+	// A sample was overwritten before it was read, readings are being missed.
+	// Reading the outputs clears it.
+	Overrun bool
+}
+
+// Status reads the data-ready and overrun flags of the accelerometer.
+func (a *Accelerometer) Status() (DataStatus, error) {
+	fields := a.fields()
+	return readStatus(a.mmr.ReadUint8, fields.dataReady, fields.overrun)
+}
+
+// Status reads the data-ready and overrun flags of the magnetometer. The DLHC
+// has no overrun flag, Overrun is always false on it.
+func (m *Magnetometer) Status() (DataStatus, error) {
+	fields := m.fields()
+	return readStatus(m.mmr.ReadUint8, fields.dataReady, fields.overrun)
+}
+
+func readStatus(read func(uint8) (uint8, error), dataReady, overrun Field) (DataStatus, error) {
+	value, err := read(dataReady.Register)
+	if err != nil {
+		return DataStatus{}, err
+	}
+	status := DataStatus{Ready: dataReady.Get(value) != 0}
+	if overrun.Width != 0 {
+		status.Overrun = overrun.Get(value) != 0
+	}
+	return status, nil
+}
@@ -0,0 +1,36 @@
+package lsm303
+
+import (
+	"testing"
+
+	"periph.io/x/periph/conn/i2c/i2ctest"
+)
+
+func TestStatus(t *testing.T) {
+	accelerometer, scenario := scriptedAccelerometer(LSM303C,
+		i2ctest.IO{Addr: 0x1D, W: []byte{0x27}, R: []byte{0b11111111}},
+		i2ctest.IO{Addr: 0x1D, W: []byte{0x27}, R: []byte{0b00001111}},
+	)
+	for _, expected := range []DataStatus{{Ready: true, Overrun: true}, {Ready: true}} {
+		if status, err := accelerometer.Status(); err != nil || status != expected {
+			t.Errorf("status is %+v, %v, want %+v", status, err, expected)
+		}
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The DLHC magnetometer only has DRDY
+	magnetometer, scenario := scriptedMagnetometer(LSM303DLHC,
+		i2ctest.IO{Addr: 0x1E, W: []byte{0x09}, R: []byte{0b11111111}},
+		i2ctest.IO{Addr: 0x1E, W: []byte{0x09}, R: []byte{0b00000010}},
+	)
+	for _, expected := range []DataStatus{{Ready: true}, {}} {
+		if status, err := magnetometer.Status(); err != nil || status != expected {
+			t.Errorf("status is %+v, %v, want %+v", status, err, expected)
+		}
+	}
+	if err := scenario.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
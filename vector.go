@@ -0,0 +1,49 @@
+package lsm303
+
+import "math"
+
+// Vector is a three axis reading, used wherever the sensor values need
+// floating point math (headings, calibration, filtering).
+type Vector struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+func (v Vector) Add(u Vector) Vector {
+	return Vector{v.X + u.X, v.Y + u.Y, v.Z + u.Z}
+}
+
+func (v Vector) Sub(u Vector) Vector {
+	return Vector{v.X - u.X, v.Y - u.Y, v.Z - u.Z}
+}
+
+func (v Vector) Scale(k float64) Vector {
+	return Vector{v.X * k, v.Y * k, v.Z * k}
+}
+
+func (v Vector) Dot(u Vector) float64 {
+	return v.X*u.X + v.Y*u.Y + v.Z*u.Z
+}
+
+func (v Vector) Cross(u Vector) Vector {
+	return Vector{
+		v.Y*u.Z - v.Z*u.Y,
+		v.Z*u.X - v.X*u.Z,
+		v.X*u.Y - v.Y*u.X,
+	}
+}
+
+func (v Vector) Norm() float64 {
+	return math.Sqrt(v.Dot(v))
+}
+
+// Normalize returns the unit vector pointing the same way as v, or the zero
+// vector if v has no length.
+func (v Vector) Normalize() Vector {
+	n := v.Norm()
+	if n == 0 {
+		return Vector{}
+	}
+	return v.Scale(1 / n)
+}